@@ -0,0 +1,447 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/paths"
+	"github.com/pelletier/go-toml"
+	"github.com/pkg/errors"
+)
+
+const auditShortHelp = `Check locked dependencies against a local advisory database`
+const auditLongHelp = `
+Audit matches every project in Gopkg.lock against the security advisories in a
+local database, and reports each locked version that an advisory affects. If any
+are found, audit exits 1. It never accesses the network.
+
+The database named by -db is either a single TOML file or a directory, in which
+every file ending in .toml is read. Each file holds any number of advisories:
+
+  [[advisory]]
+    id = "EXAMPLE-2019-0001"
+    project = "github.com/example/lib"
+    summary = "Unbounded allocation when decoding headers"
+    affected = [">=1.0.0, <1.4.2", "~2.0.0"]
+    revisions = ["2d7e1a1b9d96dd9c3a3c3a1c8a5a7b3c1bf2e9d0"]
+    fixed = ["1.4.2", "2.1.0"]
+
+A locked project is affected if its version matches any of the semver ranges in
+"affected", or if its revision is listed in "revisions". For each affected
+project, audit suggests the smallest change to Gopkg.toml that allows a version
+listed in "fixed".
+`
+
+type auditCommand struct {
+	db   string
+	json bool
+}
+
+func (cmd *auditCommand) Name() string      { return "audit" }
+func (cmd *auditCommand) Args() string      { return "-db <path> [-json]" }
+func (cmd *auditCommand) ShortHelp() string { return auditShortHelp }
+func (cmd *auditCommand) LongHelp() string  { return auditLongHelp }
+func (cmd *auditCommand) Hidden() bool      { return false }
+
+func (cmd *auditCommand) Register(fs *flag.FlagSet) {
+	fs.StringVar(&cmd.db, "db", "", "path to an advisory file or directory of advisory files")
+	fs.BoolVar(&cmd.json, "json", false, "output in JSON format")
+}
+
+func (cmd *auditCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) > 0 {
+		return errors.Errorf("audit takes no arguments")
+	}
+	if cmd.db == "" {
+		return errors.New("an advisory database must be provided with -db")
+	}
+
+	advs, err := loadAdvisories(cmd.db)
+	if err != nil {
+		return err
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+	if p.Lock == nil {
+		return errors.Errorf("no Gopkg.lock found. Run `dep ensure` to generate lock file")
+	}
+
+	findings := auditLock(p, advs)
+
+	var buf bytes.Buffer
+	if cmd.json {
+		raw := make([]rawAuditFinding, 0, len(findings))
+		for _, f := range findings {
+			raw = append(raw, f.marshalJSON())
+		}
+		if err := json.NewEncoder(&buf).Encode(raw); err != nil {
+			return err
+		}
+	} else {
+		for i, f := range findings {
+			if i > 0 {
+				buf.WriteString("\n")
+			}
+			f.print(&buf)
+		}
+	}
+	ctx.Out.Print(buf.String())
+
+	if len(findings) > 0 {
+		if !cmd.json {
+			ctx.Err.Printf("Found %d advisories affecting projects in %s\n", len(findings), dep.LockName)
+		}
+		return silentfail{}
+	}
+	return nil
+}
+
+// An advisory describes a vulnerability in a range of a project's versions.
+type advisory struct {
+	ID, Project, Summary string
+	// The original strings from the database, kept for output.
+	Ranges    []string
+	Affected  []gps.Constraint
+	Revisions []gps.Revision
+	Fixed     []gps.Version
+}
+
+type rawAdvisories struct {
+	Advisories []rawAdvisory `toml:"advisory"`
+}
+
+type rawAdvisory struct {
+	ID        string   `toml:"id"`
+	Project   string   `toml:"project"`
+	Summary   string   `toml:"summary"`
+	Affected  []string `toml:"affected"`
+	Revisions []string `toml:"revisions"`
+	Fixed     []string `toml:"fixed"`
+}
+
+// loadAdvisories reads all the advisories from the file at path or, if path is
+// a directory, from all the .toml files beneath it.
+func loadAdvisories(path string) ([]advisory, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read advisory database")
+	}
+
+	var files []string
+	if !fi.IsDir() {
+		files = []string{path}
+	} else {
+		err = filepath.Walk(path, func(fp string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !fi.IsDir() && filepath.Ext(fp) == ".toml" {
+				files = append(files, fp)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read advisory database")
+		}
+	}
+
+	var advs []advisory
+	for _, f := range files {
+		fadvs, err := readAdvisoryFile(f)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid advisory file %s", f)
+		}
+		advs = append(advs, fadvs...)
+	}
+	return advs, nil
+}
+
+func readAdvisoryFile(path string) ([]advisory, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw rawAdvisories
+	if err := toml.Unmarshal(data, &raw); err != nil {
+		return nil, errors.Wrap(err, "unable to parse the advisory file as TOML")
+	}
+
+	advs := make([]advisory, 0, len(raw.Advisories))
+	for _, ra := range raw.Advisories {
+		if ra.ID == "" || ra.Project == "" {
+			return nil, errors.New("every advisory must have an id and a project")
+		}
+
+		adv := advisory{
+			ID:      ra.ID,
+			Project: ra.Project,
+			Summary: ra.Summary,
+			Ranges:  ra.Affected,
+		}
+		for _, r := range ra.Affected {
+			c, err := gps.NewSemverConstraint(r)
+			if err != nil {
+				return nil, errors.Wrapf(err, "%s: invalid affected range %q", ra.ID, r)
+			}
+			adv.Affected = append(adv.Affected, c)
+		}
+		for _, r := range ra.Revisions {
+			adv.Revisions = append(adv.Revisions, gps.Revision(r))
+		}
+		for _, f := range ra.Fixed {
+			v := gps.NewVersion(f)
+			if v.Type() != gps.IsSemver {
+				return nil, errors.Errorf("%s: fixed version %q is not a semantic version", ra.ID, f)
+			}
+			adv.Fixed = append(adv.Fixed, v)
+		}
+		if len(adv.Affected) == 0 && len(adv.Revisions) == 0 {
+			return nil, errors.Errorf("%s: no affected ranges or revisions", ra.ID)
+		}
+
+		advs = append(advs, adv)
+	}
+	return advs, nil
+}
+
+// affects reports whether the advisory applies to the locked version v, which
+// may be any kind of gps.Version.
+func (a advisory) affects(v gps.Version) bool {
+	var rev gps.Revision
+	switch tv := v.(type) {
+	case gps.PairedVersion:
+		rev = tv.Revision()
+	case gps.Revision:
+		rev = tv
+	}
+
+	if rev != "" {
+		for _, r := range a.Revisions {
+			if r == rev {
+				return true
+			}
+		}
+	}
+
+	if v.Type() == gps.IsSemver {
+		for _, c := range a.Affected {
+			if c.Matches(v) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// An auditFinding is an advisory that affects a locked project.
+type auditFinding struct {
+	Project  gps.LockedProject
+	Advisory advisory
+	// Fixed is the smallest fixed version to move to, if one is known.
+	Fixed gps.Version
+	// Fix describes the change needed in Gopkg.toml to allow Fixed.
+	Fix string
+}
+
+type rawAuditFinding struct {
+	Project  string
+	Version  string `json:"Version,omitempty"`
+	Revision string `json:"Revision,omitempty"`
+	ID       string
+	Summary  string   `json:"Summary,omitempty"`
+	Affected []string `json:"Affected,omitempty"`
+	Fixed    string   `json:"Fixed,omitempty"`
+	Fix      string   `json:"Fix,omitempty"`
+}
+
+func (f auditFinding) marshalJSON() rawAuditFinding {
+	raw := rawAuditFinding{
+		Project:  string(f.Project.Ident().ProjectRoot),
+		ID:       f.Advisory.ID,
+		Summary:  f.Advisory.Summary,
+		Affected: f.Advisory.Ranges,
+		Fix:      f.Fix,
+	}
+	switch tv := f.Project.Version().(type) {
+	case gps.PairedVersion:
+		raw.Version = tv.Unpair().String()
+		raw.Revision = tv.Revision().String()
+	case gps.Revision:
+		raw.Revision = tv.String()
+	case gps.UnpairedVersion:
+		raw.Version = tv.String()
+	}
+	if f.Fixed != nil {
+		raw.Fixed = f.Fixed.String()
+	}
+	return raw
+}
+
+func (f auditFinding) print(buf *bytes.Buffer) {
+	id := f.Project.Ident()
+	fmt.Fprintf(buf, "%s@%s: %s", id.ProjectRoot, formatVersion(f.Project.Version()), f.Advisory.ID)
+	if f.Advisory.Summary != "" {
+		fmt.Fprintf(buf, " %s", f.Advisory.Summary)
+	}
+	buf.WriteString("\n")
+
+	if len(f.Advisory.Ranges) > 0 {
+		fmt.Fprintf(buf, "  affected: %s\n", strings.Join(f.Advisory.Ranges, "; "))
+	}
+	if f.Fixed != nil {
+		fmt.Fprintf(buf, "  fixed in: %s\n", f.Fixed)
+	}
+	fmt.Fprintf(buf, "  fix: %s\n", f.Fix)
+}
+
+// auditLock returns a finding for each pair of a project in p's lock and an
+// advisory that affects its locked version, ordered by project.
+func auditLock(p *dep.Project, advs []advisory) []auditFinding {
+	byProject := make(map[string][]advisory)
+	for _, a := range advs {
+		byProject[a.Project] = append(byProject[a.Project], a)
+	}
+
+	slp := p.Lock.Projects()
+	sort.Slice(slp, func(i, j int) bool {
+		return slp[i].Ident().Less(slp[j].Ident())
+	})
+
+	direct := directProjects(p)
+
+	var findings []auditFinding
+	for _, lp := range slp {
+		pr := lp.Ident().ProjectRoot
+		for _, a := range byProject[string(pr)] {
+			if !a.affects(lp.Version()) {
+				continue
+			}
+
+			f := auditFinding{
+				Project:  lp,
+				Advisory: a,
+				Fixed:    smallestFix(lp.Version(), a.Fixed),
+			}
+			f.Fix = suggestFix(p.Manifest, pr, direct[pr], f.Fixed, len(a.Fixed) > 0)
+			findings = append(findings, f)
+		}
+	}
+	return findings
+}
+
+// directProjects returns the set of locked projects that the current project
+// imports or requires directly, judged only from the lock and the local code.
+func directProjects(p *dep.Project) map[gps.ProjectRoot]bool {
+	rm, _ := p.RootPackageTree.ToReachMap(true, true, false, p.Manifest.IgnoredPackages())
+	imports := rm.FlattenFn(paths.IsStandardImportPath)
+	for req := range p.Manifest.RequiredPackages() {
+		imports = append(imports, req)
+	}
+
+	direct := make(map[gps.ProjectRoot]bool)
+	for _, lp := range p.Lock.Projects() {
+		pr := lp.Ident().ProjectRoot
+		for _, imp := range imports {
+			if imp == string(pr) || strings.HasPrefix(imp, string(pr)+"/") {
+				direct[pr] = true
+				break
+			}
+		}
+	}
+	return direct
+}
+
+// smallestFix picks the fixed version to suggest for a project locked at v:
+// the smallest one newer than v that keeps v's major version if there is one,
+// otherwise the smallest one newer than v. When v is not a semantic version,
+// it is the smallest fixed version overall.
+func smallestFix(v gps.Version, fixed []gps.Version) gps.Version {
+	if len(fixed) == 0 {
+		return nil
+	}
+
+	sorted := make([]gps.Version, len(fixed))
+	copy(sorted, fixed)
+	gps.SortForDowngrade(sorted)
+
+	if v.Type() != gps.IsSemver {
+		return sorted[0]
+	}
+
+	cur, err := semver.NewVersion(v.String())
+	if err != nil {
+		return sorted[0]
+	}
+
+	var newer gps.Version
+	for _, f := range sorted {
+		sv, err := semver.NewVersion(f.String())
+		if err != nil || !sv.GreaterThan(cur) {
+			continue
+		}
+		if sv.Major() == cur.Major() {
+			return f
+		}
+		if newer == nil {
+			newer = f
+		}
+	}
+	return newer
+}
+
+// suggestFix describes the smallest change to the root manifest m that allows
+// the project at pr to move to the fixed version. Transitive dependencies can
+// only be constrained with an override, so one is suggested for them. If fixed
+// is nil, known says whether the advisory names fixed versions at all, which
+// are then all older than the locked one.
+func suggestFix(m *dep.Manifest, pr gps.ProjectRoot, direct bool, fixed gps.Version, known bool) string {
+	if fixed == nil {
+		if known {
+			return "no fixed version newer than the current one is known; consider removing or replacing the dependency"
+		}
+		return "no fixed version is known; consider removing or replacing the dependency"
+	}
+
+	var c gps.Constraint
+	var isOverride bool
+	if pp, has := m.Ovr[pr]; has && pp.Constraint != nil {
+		c, isOverride = pp.Constraint, true
+	} else if pp, has := m.Constraints[pr]; has && pp.Constraint != nil && direct {
+		c = pp.Constraint
+	}
+
+	if c != nil && c.Matches(fixed) {
+		return fmt.Sprintf("allowed by the current constraint; run `dep ensure -update %s`", pr)
+	}
+
+	suggested, _ := gps.NewSemverConstraintIC(fixed.String())
+	stanza := "[[constraint]]"
+	if isOverride || !direct {
+		stanza = "[[override]]"
+	}
+
+	verb := "add"
+	if c != nil {
+		verb = "change"
+	}
+	return fmt.Sprintf("%s %s name = %q version = %q in %s, then run `dep ensure -update %s`",
+		verb, stanza, pr, suggested, dep.ManifestName, pr)
+}
@@ -0,0 +1,175 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/pkgtree"
+)
+
+func TestLoadAdvisories(t *testing.T) {
+	advs, err := loadAdvisories(filepath.Join("testdata", "audit", "db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	for _, a := range advs {
+		ids = append(ids, a.ID)
+	}
+	want := []string{"TEST-2019-0001", "TEST-2019-0002", "TEST-2019-0003"}
+	if !reflect.DeepEqual(ids, want) {
+		t.Fatalf("unexpected advisories:\n\t(GOT): %v\n\t(WNT): %v", ids, want)
+	}
+
+	if len(advs[0].Affected) != 2 || len(advs[0].Fixed) != 2 {
+		t.Errorf("expected two affected ranges and two fixed versions, got %v and %v", advs[0].Affected, advs[0].Fixed)
+	}
+	if len(advs[2].Revisions) != 1 || len(advs[2].Affected) != 0 {
+		t.Errorf("expected a single affected revision, got %v and %v", advs[2].Revisions, advs[2].Affected)
+	}
+}
+
+func TestAdvisoryAffects(t *testing.T) {
+	advs, err := loadAdvisories(filepath.Join("testdata", "audit", "db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	foo, bar := advs[0], advs[2]
+
+	tests := []struct {
+		name string
+		adv  advisory
+		v    gps.Version
+		want bool
+	}{
+		{"in first range", foo, gps.NewVersion("v1.2.0").Pair("abc"), true},
+		{"in second range", foo, gps.NewVersion("v2.0.3").Pair("abc"), true},
+		{"fixed", foo, gps.NewVersion("v1.4.2").Pair("abc"), false},
+		{"branch", foo, gps.NewBranch("master").Pair("abc"), false},
+		{"revision", bar, gps.Revision("6e84a7f4cb5c6e2acfc1a6b4b1a3cbe1e2cdf0f7"), true},
+		{"paired revision", bar, gps.NewBranch("master").Pair("6e84a7f4cb5c6e2acfc1a6b4b1a3cbe1e2cdf0f7"), true},
+		{"other revision", bar, gps.Revision("abc"), false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.adv.affects(tc.v); got != tc.want {
+				t.Errorf("expected affects(%s) to be %t", tc.v, tc.want)
+			}
+		})
+	}
+}
+
+func TestSmallestFix(t *testing.T) {
+	fixed := []gps.Version{gps.NewVersion("2.1.0"), gps.NewVersion("1.4.2"), gps.NewVersion("3.0.0")}
+
+	tests := []struct {
+		v    gps.Version
+		want gps.Version
+	}{
+		{gps.NewVersion("v1.2.0"), gps.NewVersion("1.4.2")},
+		{gps.NewVersion("v2.0.0"), gps.NewVersion("2.1.0")},
+		{gps.NewVersion("v2.5.0"), gps.NewVersion("3.0.0")},
+		{gps.NewVersion("v3.1.0"), nil},
+		{gps.NewBranch("master"), gps.NewVersion("1.4.2")},
+	}
+
+	for _, tc := range tests {
+		got := smallestFix(tc.v, fixed)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("smallestFix(%s): expected %v, got %v", tc.v, tc.want, got)
+		}
+	}
+}
+
+func TestAuditLock(t *testing.T) {
+	advs, err := loadAdvisories(filepath.Join("testdata", "audit", "db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tilde, _ := gps.NewSemverConstraint("~1.2.0")
+	m := dep.NewManifest()
+	m.Constraints["github.com/example/foo"] = gps.ProjectProperties{Constraint: tilde}
+
+	p := &dep.Project{
+		ImportRoot: "github.com/golang/notexist",
+		Manifest:   m,
+		Lock: &dep.Lock{
+			P: []gps.LockedProject{
+				gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/example/foo"}, gps.NewVersion("v1.2.0").Pair("abc"), []string{"."}),
+				gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/example/bar"}, gps.NewBranch("master").Pair("6e84a7f4cb5c6e2acfc1a6b4b1a3cbe1e2cdf0f7"), []string{"."}),
+				gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/example/baz"}, gps.NewVersion("v1.2.0").Pair("def"), []string{"."}),
+			},
+		},
+		RootPackageTree: pkgtree.PackageTree{
+			ImportRoot: "github.com/golang/notexist",
+			Packages: map[string]pkgtree.PackageOrErr{
+				"github.com/golang/notexist": {
+					P: pkgtree.Package{
+						ImportPath: "github.com/golang/notexist",
+						Name:       "main",
+						Imports:    []string{"github.com/example/foo", "github.com/example/baz"},
+					},
+				},
+			},
+		},
+	}
+
+	findings := auditLock(p, advs)
+	if len(findings) != 2 {
+		t.Fatalf("expected 2 findings, got %d", len(findings))
+	}
+
+	// Findings are ordered by project.
+	got := []rawAuditFinding{findings[0].marshalJSON(), findings[1].marshalJSON()}
+	want := []rawAuditFinding{
+		{
+			Project:  "github.com/example/bar",
+			Version:  "master",
+			Revision: "6e84a7f4cb5c6e2acfc1a6b4b1a3cbe1e2cdf0f7",
+			ID:       "TEST-2019-0003",
+			Fixed:    "0.3.0",
+			Fix:      "add [[override]] name = \"github.com/example/bar\" version = \"^0.3.0\" in Gopkg.toml, then run `dep ensure -update github.com/example/bar`",
+		},
+		{
+			Project:  "github.com/example/foo",
+			Version:  "v1.2.0",
+			Revision: "abc",
+			ID:       "TEST-2019-0001",
+			Summary:  "Header parsing allocates without bound",
+			Affected: []string{">=1.0.0, <1.4.2", "~2.0.0"},
+			Fixed:    "1.4.2",
+			Fix:      "change [[constraint]] name = \"github.com/example/foo\" version = \"^1.4.2\" in Gopkg.toml, then run `dep ensure -update github.com/example/foo`",
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected findings:\n\t(GOT): %#v\n\t(WNT): %#v", got, want)
+	}
+}
+
+func TestSuggestFixNoneNewer(t *testing.T) {
+	m := dep.NewManifest()
+	pr := gps.ProjectRoot("github.com/example/foo")
+
+	tests := []struct {
+		known bool
+		want  string
+	}{
+		{false, "no fixed version is known; consider removing or replacing the dependency"},
+		{true, "no fixed version newer than the current one is known; consider removing or replacing the dependency"},
+	}
+	for _, tc := range tests {
+		if got := suggestFix(m, pr, true, nil, tc.known); got != tc.want {
+			t.Errorf("suggestFix(known=%v): expected %q, got %q", tc.known, tc.want, got)
+		}
+	}
+}
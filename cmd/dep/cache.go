@@ -0,0 +1,359 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+const cacheShortHelp = `Inspect and manage the source cache`
+const cacheLongHelp = `
+Cache manages the repositories that dep clones into its cache directory,
+$DEPCACHEDIR, or $GOPATH/pkg/dep if it is not set. It takes the same lock on
+the cache directory as other dep commands, so it is safe to run while they are.
+
+Subcommands:
+
+  list     List each cached source with its VCS type, size and when it was
+           last used, followed by the size of the metadata cache
+  gc       Remove sources that were last used longer ago than -max-age, then
+           the least recently used ones until the cache fits in -max-size
+  verify   Run the VCS's integrity checks on each source, and remove those that
+           fail, or are not repositories, so they are cloned again when next
+           needed; svn working copies and downloaded archives are skipped
+  export   Write a bundle of the sources needed for the current project's
+           Gopkg.lock, and their entries in the metadata cache, to -o;
+           partial clones are made full clones first
+  import   Add the sources and metadata in a bundle to the cache, replacing
+           any it already holds for the same sources
+
+Bundles let dep work without network access: after importing a bundle made
+for a project, "dep ensure -vendor-only" for that project does not need to
+reach any upstream source. A bundle is a tar archive, gzipped if the name given
+to -o ends in .gz or .tgz.
+
+Sizes are given in bytes, or with a K, M, G or T suffix for powers of 1024.
+
+Examples:
+
+  dep cache list -json             List cached sources as JSON
+  dep cache gc -max-age=720h       Remove sources unused for 30 days
+  dep cache gc -max-size=2G        Shrink the cache to at most 2 GiB
+  dep cache verify -dry-run        Report corrupt sources without removing them
+  dep cache export -o deps.tgz     Bundle the sources for the current project
+  dep cache import deps.tgz        Seed the cache from a bundle
+`
+
+type cacheCommand struct {
+	json    bool
+	maxAge  time.Duration
+	maxSize string
+	dryRun  bool
+	out     string
+}
+
+func (cmd *cacheCommand) Name() string { return "cache" }
+func (cmd *cacheCommand) Args() string {
+	return "list [-json] | gc [-max-age <duration>] [-max-size <size>] [-dry-run] | verify [-dry-run] | export -o <bundle> | import <bundle>"
+}
+func (cmd *cacheCommand) ShortHelp() string { return cacheShortHelp }
+func (cmd *cacheCommand) LongHelp() string  { return cacheLongHelp }
+func (cmd *cacheCommand) Hidden() bool      { return false }
+
+// Register uses the current values of the flags as their defaults, so that
+// Run can register them again to parse the flags given after the subcommand.
+func (cmd *cacheCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.json, "json", cmd.json, "list: output in JSON format")
+	fs.DurationVar(&cmd.maxAge, "max-age", cmd.maxAge, "gc: remove sources last used longer ago than this")
+	fs.StringVar(&cmd.maxSize, "max-size", cmd.maxSize, "gc: remove the least recently used sources until the cache is no larger than this")
+	fs.BoolVar(&cmd.dryRun, "dry-run", cmd.dryRun, "gc, verify: only report the sources that would be removed")
+	fs.StringVar(&cmd.out, "o", cmd.out, "export: file to write the bundle to")
+}
+
+func (cmd *cacheCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) == 0 {
+		return errors.New("must specify one of the list, gc, verify, export or import subcommands")
+	}
+
+	sub := args[0]
+	fs := flag.NewFlagSet("cache "+sub, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.BoolVar(&ctx.Verbose, "v", ctx.Verbose, "enable verbose logging")
+	cmd.Register(fs)
+	if err := fs.Parse(args[1:]); err != nil {
+		return errors.Wrapf(err, "dep cache %s", sub)
+	}
+	if sub == "import" {
+		if fs.NArg() != 1 {
+			return errors.New("dep cache import takes the path of a single bundle")
+		}
+	} else if fs.NArg() > 0 {
+		return errors.Errorf("dep cache %s takes no arguments", sub)
+	}
+
+	var maxSize int64
+	switch sub {
+	case "list":
+	case "gc":
+		if cmd.maxSize != "" {
+			var err error
+			if maxSize, err = parseByteSize(cmd.maxSize); err != nil {
+				return err
+			}
+		}
+		if cmd.maxAge <= 0 && maxSize <= 0 {
+			return errors.New("dep cache gc needs a positive -max-age or -max-size")
+		}
+	case "verify", "import":
+	case "export":
+		if cmd.out == "" {
+			return errors.New("dep cache export needs a bundle file to write to with -o")
+		}
+	default:
+		return errors.Errorf("unknown cache subcommand %q; must be one of list, gc, verify, export or import", sub)
+	}
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	switch sub {
+	case "export":
+		return cmd.runExport(ctx, sm)
+	case "import":
+		return cmd.runImport(ctx, sm, fs.Arg(0))
+	}
+
+	srcs, err := sm.CachedSources()
+	if err != nil {
+		return err
+	}
+
+	switch sub {
+	case "list":
+		return cmd.runList(ctx, sm, srcs)
+	case "gc":
+		return cmd.runGC(ctx, sm, selectEvictions(srcs, time.Now(), cmd.maxAge, maxSize))
+	default:
+		return cmd.runVerify(ctx, sm, srcs)
+	}
+}
+
+type rawCachedSource struct {
+	Source   string
+	Path     string
+	Type     string
+	Size     int64
+	LastUsed time.Time
+}
+
+func (cmd *cacheCommand) runList(ctx *dep.Ctx, sm *gps.SourceMgr, srcs []gps.CachedSource) error {
+	var buf bytes.Buffer
+	if cmd.json {
+		raw := make([]rawCachedSource, len(srcs))
+		for i, cs := range srcs {
+			raw[i] = rawCachedSource{
+				Source:   cachedSourceName(cs),
+				Path:     cs.Path,
+				Type:     cs.Type,
+				Size:     cs.Size,
+				LastUsed: cs.LastUsed,
+			}
+		}
+		if err := json.NewEncoder(&buf).Encode(raw); err != nil {
+			return err
+		}
+		ctx.Out.Print(buf.String())
+		return nil
+	}
+
+	var total int64
+	tw := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SOURCE\tTYPE\tSIZE\tLAST USED")
+	for _, cs := range srcs {
+		typ := cs.Type
+		if typ == "" {
+			typ = "?"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", cachedSourceName(cs), typ, formatByteSize(cs.Size), cs.LastUsed.Format("2006-01-02 15:04"))
+		total += cs.Size
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(&buf, "\n%d sources, %s\n", len(srcs), formatByteSize(total))
+	if fi, err := os.Stat(sm.PersistentCachePath()); err == nil {
+		fmt.Fprintf(&buf, "metadata cache %s, %s\n", filepath.Base(sm.PersistentCachePath()), formatByteSize(fi.Size()))
+	}
+	ctx.Out.Print(buf.String())
+	return nil
+}
+
+func (cmd *cacheCommand) runGC(ctx *dep.Ctx, sm *gps.SourceMgr, evict []gps.CachedSource) error {
+	var freed int64
+	for _, cs := range evict {
+		if cmd.dryRun {
+			ctx.Out.Printf("would remove %s (%s)\n", cachedSourceName(cs), formatByteSize(cs.Size))
+		} else {
+			if err := sm.RemoveCachedSource(cs); err != nil {
+				return err
+			}
+			if ctx.Verbose {
+				ctx.Err.Printf("removed %s (%s)\n", cachedSourceName(cs), formatByteSize(cs.Size))
+			}
+		}
+		freed += cs.Size
+	}
+
+	if !cmd.dryRun {
+		ctx.Out.Printf("Removed %d sources, freeing %s\n", len(evict), formatByteSize(freed))
+	}
+	return nil
+}
+
+func (cmd *cacheCommand) runVerify(ctx *dep.Ctx, sm *gps.SourceMgr, srcs []gps.CachedSource) error {
+	var corrupt, skipped int
+	for _, cs := range srcs {
+		if ctx.Verbose {
+			ctx.Err.Printf("verifying %s\n", cachedSourceName(cs))
+		}
+
+		err := sm.VerifyCachedSource(context.TODO(), cs)
+		if err == nil {
+			continue
+		}
+		if err == context.Canceled || err == gps.ErrSourceManagerIsReleased {
+			return err
+		}
+		if err == gps.ErrCachedSourceUnverifiable {
+			skipped++
+			if ctx.Verbose {
+				ctx.Err.Printf("  - %s: cannot be verified\n", cachedSourceName(cs))
+			}
+			continue
+		}
+
+		corrupt++
+		ctx.Err.Printf("  ✗ %s: %s\n", cachedSourceName(cs), err)
+		if ctx.Verbose {
+			if le, ok := err.(interface{ Out() string }); ok {
+				ctx.Err.Println(strings.TrimSpace(le.Out()))
+			}
+		}
+		if !cmd.dryRun {
+			if err := sm.RemoveCachedSource(cs); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch {
+	case corrupt == 0 && skipped > 0:
+		ctx.Out.Printf("%d sources verified; %d could not be verified\n", len(srcs)-skipped, skipped)
+	case corrupt == 0:
+		ctx.Out.Printf("All %d sources verified\n", len(srcs))
+	case cmd.dryRun:
+		ctx.Out.Printf("%d of %d sources failed verification\n", corrupt, len(srcs))
+		return silentfail{}
+	default:
+		ctx.Out.Printf("Removed %d of %d sources that failed verification; they will be cloned again when next needed\n", corrupt, len(srcs))
+	}
+	return nil
+}
+
+// selectEvictions returns the sources that gc should remove: those last used
+// longer than maxAge before now, then the least recently used of the rest
+// until their total size is no more than maxSize. A zero maxAge or maxSize
+// disables that limit.
+func selectEvictions(srcs []gps.CachedSource, now time.Time, maxAge time.Duration, maxSize int64) []gps.CachedSource {
+	byAge := make([]gps.CachedSource, len(srcs))
+	copy(byAge, srcs)
+	sort.SliceStable(byAge, func(i, j int) bool {
+		return byAge[i].LastUsed.Before(byAge[j].LastUsed)
+	})
+
+	var total int64
+	for _, cs := range byAge {
+		total += cs.Size
+	}
+
+	var evict []gps.CachedSource
+	for _, cs := range byAge {
+		expired := maxAge > 0 && now.Sub(cs.LastUsed) > maxAge
+		oversize := maxSize > 0 && total > maxSize
+		if !expired && !oversize {
+			break
+		}
+		evict = append(evict, cs)
+		total -= cs.Size
+	}
+	return evict
+}
+
+// cachedSourceName returns the name to show for cs: its remote URL, or the
+// name of its directory if that is not known.
+func cachedSourceName(cs gps.CachedSource) string {
+	if cs.URL != "" {
+		return cs.URL
+	}
+	return filepath.Base(cs.Path)
+}
+
+var byteSizeUnits = []string{"B", "K", "M", "G", "T"}
+
+// parseByteSize parses a size in bytes, with an optional K, M, G or T suffix
+// for powers of 1024. The suffix may be followed by "B" or "iB".
+func parseByteSize(s string) (int64, error) {
+	num := strings.TrimSuffix(strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "B"), "I")
+	mult := int64(1)
+	for i := len(byteSizeUnits) - 1; i > 0; i-- {
+		if strings.HasSuffix(num, byteSizeUnits[i]) {
+			num = strings.TrimSuffix(num, byteSizeUnits[i])
+			mult = 1 << (10 * uint(i))
+			break
+		}
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(num), 64)
+	if err != nil || n < 0 {
+		return 0, errors.Errorf("invalid size %q", s)
+	}
+	return int64(n * float64(mult)), nil
+}
+
+// formatByteSize formats n bytes using the largest power-of-1024 unit that
+// keeps the number at least 1.
+func formatByteSize(n int64) string {
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	f := float64(n)
+	i := 0
+	for f >= 1024 && i < len(byteSizeUnits)-1 {
+		f /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f %siB", f, byteSizeUnits[i])
+}
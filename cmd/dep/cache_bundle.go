@@ -0,0 +1,338 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/pkg/errors"
+)
+
+// A cache bundle is a tar archive, optionally gzipped, that holds a copy of
+// some of the cache directory:
+//
+//	sources/<dir>/...   the local repositories of sources, as in the cache
+//	<persistent cache>  a persistent cache file holding only their entries
+//
+// Paths are relative to the cache directory, and always slash-separated.
+const bundleSourcesDir = "sources"
+
+func (cmd *cacheCommand) runExport(ctx *dep.Ctx, sm *gps.SourceMgr) error {
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+	if p.Lock == nil {
+		return errors.Errorf("no %s found to export sources for; run `dep ensure` first", dep.LockName)
+	}
+
+	var srcs []gps.CachedSource
+	var ids []gps.ProjectIdentifier
+	for _, lp := range p.Lock.Projects() {
+		var rev gps.Revision
+		switch v := lp.Version().(type) {
+		case gps.PairedVersion:
+			rev = v.Revision()
+		case gps.Revision:
+			rev = v
+		}
+
+		if ctx.Verbose {
+			ctx.Err.Printf("adding %s\n", lp.Ident())
+		}
+		cs, err := sm.CachedSourceFor(context.TODO(), lp.Ident(), rev)
+		if err != nil {
+			return errors.Wrapf(err, "failed to cache source for %s", lp.Ident())
+		}
+		// A partial clone would need its upstream to fetch the missing files
+		// from wherever the bundle is imported.
+		if cs.Partial {
+			if ctx.Verbose {
+				ctx.Err.Printf("fetching the files missing from the partial clone of %s\n", lp.Ident())
+			}
+			if cs, err = sm.CompleteCachedSource(context.TODO(), cs); err != nil {
+				return err
+			}
+		}
+		srcs = append(srcs, cs)
+		ids = append(ids, lp.Ident())
+	}
+
+	td, err := ioutil.TempDir("", "dep")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temp dir")
+	}
+	defer os.RemoveAll(td)
+
+	cachePath := filepath.Join(td, filepath.Base(sm.PersistentCachePath()))
+	n, err := sm.ExportPersistentCache(ids, cachePath)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		cachePath = ""
+	}
+
+	if err := writeCacheBundle(cmd.out, srcs, cachePath); err != nil {
+		return err
+	}
+	ctx.Out.Printf("Exported %d sources and metadata for %d to %s\n", len(srcs), n, cmd.out)
+	return nil
+}
+
+func (cmd *cacheCommand) runImport(ctx *dep.Ctx, sm *gps.SourceMgr, bundle string) error {
+	// Unpack into the cache directory, so that sources can be moved into place.
+	td, err := ioutil.TempDir(sm.Cachedir(), ".import")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temp dir")
+	}
+	defer os.RemoveAll(td)
+
+	if err := readCacheBundle(bundle, td); err != nil {
+		return err
+	}
+
+	fis, err := ioutil.ReadDir(filepath.Join(td, bundleSourcesDir))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	sourcesDir := filepath.Join(sm.Cachedir(), bundleSourcesDir)
+	for _, fi := range fis {
+		to := filepath.Join(sourcesDir, fi.Name())
+		if err := os.RemoveAll(to); err != nil {
+			return errors.Wrapf(err, "failed to replace %s", to)
+		}
+		if err := os.Rename(filepath.Join(td, bundleSourcesDir, fi.Name()), to); err != nil {
+			return errors.Wrapf(err, "failed to move %s into the cache", fi.Name())
+		}
+		if ctx.Verbose {
+			ctx.Err.Printf("imported %s\n", fi.Name())
+		}
+	}
+
+	var n int
+	cachePath := filepath.Join(td, filepath.Base(sm.PersistentCachePath()))
+	if _, err := os.Stat(cachePath); err == nil {
+		if n, err = sm.ImportPersistentCache(cachePath); err != nil {
+			return err
+		}
+	}
+
+	ctx.Out.Printf("Imported %d sources and metadata for %d from %s\n", len(fis), n, bundle)
+	return nil
+}
+
+// writeCacheBundle writes a bundle of srcs and, if cachePath is not empty, the
+// persistent cache file at cachePath to the file at name. The bundle is gzipped
+// if name ends in .gz or .tgz.
+func writeCacheBundle(name string, srcs []gps.CachedSource, cachePath string) (err error) {
+	f, err := os.Create(name)
+	if err != nil {
+		return errors.Wrap(err, "failed to create bundle")
+	}
+	defer func() {
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	bw := bufio.NewWriter(f)
+	var w io.Writer = bw
+	var gzw *gzip.Writer
+	if strings.HasSuffix(name, ".gz") || strings.HasSuffix(name, ".tgz") {
+		gzw = gzip.NewWriter(bw)
+		w = gzw
+	}
+	tw := tar.NewWriter(w)
+
+	for _, cs := range srcs {
+		prefix := path.Join(bundleSourcesDir, filepath.Base(cs.Path))
+		if err := addTreeToTar(tw, cs.Path, prefix); err != nil {
+			return errors.Wrapf(err, "failed to add %s to bundle", cs.Path)
+		}
+	}
+	if cachePath != "" {
+		if err := addTreeToTar(tw, cachePath, filepath.Base(cachePath)); err != nil {
+			return errors.Wrap(err, "failed to add persistent cache to bundle")
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if gzw != nil {
+		if err := gzw.Close(); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// addTreeToTar adds the file or directory tree at root to tw, naming it
+// prefix.
+func addTreeToTar(tw *tar.Writer, root, prefix string) error {
+	return filepath.Walk(root, func(fpath string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, fpath)
+		if err != nil {
+			return err
+		}
+
+		var link string
+		if fi.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(fpath); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(fi, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = path.Join(prefix, filepath.ToSlash(rel))
+		if fi.IsDir() {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(fpath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+}
+
+// readCacheBundle unpacks the bundle at name into dir. Entries that would
+// land outside of dir, and symlinks that would point outside of it, are
+// rejected. Symlinks are created only after all other entries so that none
+// can be written through.
+func readCacheBundle(name, dir string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return errors.Wrap(err, "failed to open bundle")
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+	var r io.Reader = br
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzr, err := gzip.NewReader(br)
+		if err != nil {
+			return errors.Wrap(err, "failed to read bundle")
+		}
+		defer gzr.Close()
+		r = gzr
+	}
+
+	type symlink struct{ name, target string }
+	var links []symlink
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to read bundle")
+		}
+
+		clean := path.Clean(hdr.Name)
+		if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return errors.Errorf("bundle entry %q is outside of the cache directory", hdr.Name)
+		}
+		to := filepath.Join(dir, filepath.FromSlash(clean))
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(to, 0777); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(to), 0777); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(to, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.FileMode(hdr.Mode)&os.ModePerm|0600)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(out, tr); err != nil {
+				out.Close()
+				return err
+			}
+			if err := out.Close(); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			target := filepath.FromSlash(hdr.Linkname)
+			if filepath.IsAbs(target) || !withinDir(dir, filepath.Join(filepath.Dir(to), target)) {
+				return errors.Errorf("bundle entry %q links to %q, outside of the cache directory", hdr.Name, hdr.Linkname)
+			}
+			links = append(links, symlink{to, target})
+		default:
+			return errors.Errorf("bundle entry %q has unsupported type %c", hdr.Name, hdr.Typeflag)
+		}
+	}
+
+	// A link beneath another, or one whose target passes through another,
+	// could resolve outside of the cache directory though its target does not
+	// appear to.
+	isLink := make(map[string]bool, len(links))
+	for _, l := range links {
+		isLink[l.name] = true
+	}
+	for _, l := range links {
+		for p := filepath.Dir(l.name); withinDir(dir, p) && p != dir; p = filepath.Dir(p) {
+			if isLink[p] {
+				return errors.Errorf("bundle entry %q is beneath the link %q", l.name, p)
+			}
+		}
+		elems := strings.Split(l.target, string(filepath.Separator))
+		p := filepath.Dir(l.name)
+		for _, elem := range elems[:len(elems)-1] {
+			p = filepath.Join(p, elem)
+			if isLink[p] {
+				return errors.Errorf("bundle entry %q links through the link %q", l.name, p)
+			}
+		}
+	}
+
+	for _, l := range links {
+		if err := os.MkdirAll(filepath.Dir(l.name), 0777); err != nil {
+			return err
+		}
+		if err := os.Symlink(l.target, l.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// withinDir reports whether path, which must be clean, is dir or beneath it.
+func withinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
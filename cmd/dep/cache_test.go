@@ -0,0 +1,196 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/internal/test"
+)
+
+func TestSelectEvictions(t *testing.T) {
+	now := time.Date(2019, 6, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	srcs := []gps.CachedSource{
+		{Path: "a", Size: 100, LastUsed: now.Add(-1 * day)},
+		{Path: "b", Size: 200, LastUsed: now.Add(-40 * day)},
+		{Path: "c", Size: 300, LastUsed: now.Add(-10 * day)},
+		{Path: "d", Size: 400, LastUsed: now.Add(-60 * day)},
+	}
+
+	paths := func(srcs []gps.CachedSource) []string {
+		var p []string
+		for _, cs := range srcs {
+			p = append(p, cs.Path)
+		}
+		return p
+	}
+
+	tests := []struct {
+		name    string
+		maxAge  time.Duration
+		maxSize int64
+		want    []string
+	}{
+		{"age", 30 * day, 0, []string{"d", "b"}},
+		{"size", 0, 500, []string{"d", "b"}},
+		{"size evicts least recently used first", 0, 399, []string{"d", "b", "c"}},
+		{"age then size", 50 * day, 350, []string{"d", "b", "c"}},
+		{"nothing to do", 90 * day, 1000, nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := paths(selectEvictions(srcs, now, tc.maxAge, tc.maxSize))
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestParseByteSize(t *testing.T) {
+	tests := map[string]int64{
+		"0":      0,
+		"1024":   1024,
+		"100B":   100,
+		"2K":     2048,
+		"1.5M":   3 << 19,
+		"2G":     2 << 30,
+		"2GiB":   2 << 30,
+		"1tb":    1 << 40,
+		" 3 MB ": 3 << 20,
+	}
+	for s, want := range tests {
+		got, err := parseByteSize(s)
+		if err != nil {
+			t.Errorf("parseByteSize(%q): unexpected error: %s", s, err)
+		} else if got != want {
+			t.Errorf("parseByteSize(%q): expected %d, got %d", s, want, got)
+		}
+	}
+
+	for _, s := range []string{"", "G", "-1K", "12X"} {
+		if _, err := parseByteSize(s); err == nil {
+			t.Errorf("parseByteSize(%q): expected an error", s)
+		}
+	}
+}
+
+func TestFormatByteSize(t *testing.T) {
+	tests := map[int64]string{
+		0:             "0 B",
+		1023:          "1023 B",
+		1024:          "1.0 KiB",
+		3 << 19:       "1.5 MiB",
+		5 << 30:       "5.0 GiB",
+		3 << 40:       "3.0 TiB",
+		(1 << 50) * 2: "2048.0 TiB",
+	}
+	for n, want := range tests {
+		if got := formatByteSize(n); got != want {
+			t.Errorf("formatByteSize(%d): expected %q, got %q", n, want, got)
+		}
+	}
+}
+
+func TestCacheBundleRoundTrip(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	h.TempDir("cache/sources/https---example.com-foo/.git")
+	h.TempFile("cache/sources/https---example.com-foo/.git/HEAD", "ref: refs/heads/master\n")
+	h.TempFile("cache/sources/https---example.com-foo/foo.go", "package foo\n")
+	h.TempFile("cache/bolt-v1.db", "not really a database")
+
+	srcs := []gps.CachedSource{{Path: h.Path("cache/sources/https---example.com-foo")}}
+
+	for _, name := range []string{"bundle.tar", "bundle.tgz"} {
+		bundle := filepath.Join(h.Path("."), name)
+		if err := writeCacheBundle(bundle, srcs, h.Path("cache/bolt-v1.db")); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		out := filepath.Join(h.Path("."), "out-"+name)
+		if err := readCacheBundle(bundle, out); err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		for _, f := range []string{
+			"sources/https---example.com-foo/.git/HEAD",
+			"sources/https---example.com-foo/foo.go",
+			"bolt-v1.db",
+		} {
+			want, err := ioutil.ReadFile(h.Path(filepath.Join("cache", f)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := ioutil.ReadFile(filepath.Join(out, f))
+			if err != nil {
+				t.Fatalf("%s: %s", name, err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("%s: %s: expected %q, got %q", name, f, want, got)
+			}
+		}
+	}
+}
+
+func TestReadCacheBundleRejectsEscapes(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+
+	cases := map[string][]tar.Header{
+		"path":          {{Name: "sources/../../evil", Mode: 0644, Typeflag: tar.TypeReg}},
+		"absolute link": {{Name: "sources/evil", Linkname: "/etc", Typeflag: tar.TypeSymlink}},
+		"relative link": {{Name: "sources/foo/evil", Linkname: "../../..", Typeflag: tar.TypeSymlink}},
+		"nested link": {
+			{Name: "sources/foo/up", Linkname: ".", Typeflag: tar.TypeSymlink},
+			{Name: "sources/foo/up/evil", Linkname: "../..", Typeflag: tar.TypeSymlink},
+		},
+		"chained link": {
+			{Name: "sources/x/y/l2", Linkname: "../../..", Typeflag: tar.TypeSymlink},
+			{Name: "sources/x/y/l1", Linkname: "l2/..", Typeflag: tar.TypeSymlink},
+		},
+	}
+	for name, hdrs := range cases {
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		for _, hdr := range hdrs {
+			hdr := hdr
+			tw.WriteHeader(&hdr)
+		}
+		tw.Close()
+
+		h.TempFile("evil.tar", buf.String())
+		out := filepath.Join(h.Path("."), "out-"+strings.Replace(name, " ", "-", -1))
+		if err := readCacheBundle(h.Path("evil.tar"), out); err == nil {
+			t.Errorf("%s: expected an entry outside of the cache directory to be rejected", name)
+		}
+	}
+
+	// Links within the cache directory are kept.
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Name: "sources/foo/link", Linkname: "../bar", Typeflag: tar.TypeSymlink})
+	tw.Close()
+	h.TempFile("ok.tar", buf.String())
+	ok := filepath.Join(h.Path("."), "ok")
+	if err := readCacheBundle(h.Path("ok.tar"), ok); err != nil {
+		t.Fatal(err)
+	}
+	if dest, err := os.Readlink(filepath.Join(ok, "sources", "foo", "link")); err != nil || dest != filepath.FromSlash("../bar") {
+		t.Errorf("expected the link to ../bar to be kept, got %q, %v", dest, err)
+	}
+}
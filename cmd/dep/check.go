@@ -37,6 +37,10 @@ more information on what it means to be "in sync.")
 If your workflow necessitates that you modify the contents of vendor, you can
 force check to ignore hash mismatches on a per-project basis by naming
 project roots in Gopkg.toml's "noverify" list.
+
+Check always fails while an override in Gopkg.toml, or a project in
+Gopkg.lock, uses a working tree on disk as a project's source, as such
+overrides are only meant for local development.
 `
 
 type checkCommand struct {
@@ -78,6 +82,14 @@ func (cmd *checkCommand) Run(ctx *dep.Ctx, args []string) error {
 	defer sm.Release()
 
 	var fail bool
+	if local := localOverrides(p); len(local) > 0 {
+		fail = true
+		logger.Println("# local overrides are active:")
+		for _, line := range local {
+			logger.Println(line)
+		}
+	}
+
 	if !cmd.skiplock {
 		if p.Lock == nil {
 			return errors.New("Gopkg.lock does not exist, cannot check it against imports and Gopkg.toml")
@@ -88,6 +100,9 @@ func (cmd *checkCommand) Run(ctx *dep.Ctx, args []string) error {
 		sat, changed := lsat.Satisfied(), delta.Changed(verify.PruneOptsChanged|verify.HashVersionChanged)
 
 		if changed || !sat {
+			if fail {
+				logger.Println()
+			}
 			fail = true
 			logger.Println("# Gopkg.lock is out of sync:")
 			if !sat {
@@ -226,6 +241,22 @@ func (cmd *checkCommand) Run(ctx *dep.Ctx, args []string) error {
 	return nil
 }
 
+// localOverrides describes the overrides in the manifest, and the projects in
+// the lock, that use a working tree on disk as a project's source, sorted.
+func localOverrides(p *dep.Project) []string {
+	var local []string
+	for pr, path := range p.Manifest.LocalPaths {
+		local = append(local, fmt.Sprintf("%s: overridden in Gopkg.toml by the working tree at %s", pr, path))
+	}
+	if p.Lock != nil {
+		for pr, dir := range p.Lock.LocalProjects() {
+			local = append(local, fmt.Sprintf("%s: locked to the working tree at %s", pr, dir))
+		}
+	}
+	sort.Strings(local)
+	return local
+}
+
 func sprintLockUnsat(lsat verify.LockSatisfaction) string {
 	var buf bytes.Buffer
 	sort.Strings(lsat.MissingImports)
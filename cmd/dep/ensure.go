@@ -116,6 +116,15 @@ dep ensure -no-vendor -dry-run
     the Gopkg.toml or the project imports. It can be useful to run this during
     CI to check if Gopkg.lock is up to date.
 
+dep ensure -strategy=minimal
+
+    Solve again, ignoring Gopkg.lock, and select the oldest versions of all
+    dependencies allowed by the constraints in Gopkg.toml and in the manifests
+    of dependencies. Gopkg.lock and vendor/ are rewritten with the result, so
+    this is best run on a throwaway checkout, such as in CI; building and
+    testing there checks that the lower bounds of those constraints are
+    accurate. Add -dry-run to only print the versions that would be selected.
+
 `
 
 var (
@@ -125,7 +134,7 @@ var (
 
 func (cmd *ensureCommand) Name() string { return "ensure" }
 func (cmd *ensureCommand) Args() string {
-	return "[-update | -add] [-no-vendor | -vendor-only] [-dry-run] [-v] [<spec>...]"
+	return "[-update | -add] [-no-vendor | -vendor-only] [-strategy=minimal] [-dry-run] [-json-errors] [-trace-json=<file>] [-trace-html=<file>] [-v] [<spec>...]"
 }
 func (cmd *ensureCommand) ShortHelp() string { return ensureShortHelp }
 func (cmd *ensureCommand) LongHelp() string  { return ensureLongHelp }
@@ -138,15 +147,25 @@ func (cmd *ensureCommand) Register(fs *flag.FlagSet) {
 	fs.BoolVar(&cmd.vendorOnly, "vendor-only", false, "populate vendor/ from Gopkg.lock without updating it first")
 	fs.BoolVar(&cmd.noVendor, "no-vendor", false, "update Gopkg.lock (if needed), but do not update vendor/")
 	fs.BoolVar(&cmd.dryRun, "dry-run", false, "only report the changes that would be made")
+	fs.StringVar(&cmd.strategy, "strategy", "", "how to select versions: \"minimal\" selects the oldest allowed by the constraints, ignoring Gopkg.lock and rewriting it and vendor/")
+	fs.BoolVar(&cmd.jsonErrors, "json-errors", false, "print solve failures as JSON")
+	fs.StringVar(&cmd.traceJSON, "trace-json", "", "write the steps the solver takes to the named file, as lines of JSON")
+	fs.StringVar(&cmd.traceHTML, "trace-html", "", "write the solver's search tree to the named file, as an HTML page")
+	fs.BoolVar(&cmd.refreshDeductions, "refresh-deductions", false, "retrieve go get metadata again instead of using the cached metadata")
 }
 
 type ensureCommand struct {
-	examples   bool
-	update     bool
-	add        bool
-	noVendor   bool
-	vendorOnly bool
-	dryRun     bool
+	examples          bool
+	update            bool
+	add               bool
+	noVendor          bool
+	vendorOnly        bool
+	dryRun            bool
+	strategy          string
+	jsonErrors        bool
+	traceJSON         string
+	traceHTML         string
+	refreshDeductions bool
 }
 
 func (cmd *ensureCommand) Run(ctx *dep.Ctx, args []string) error {
@@ -164,6 +183,7 @@ func (cmd *ensureCommand) Run(ctx *dep.Ctx, args []string) error {
 		return err
 	}
 
+	ctx.RefreshDeductions = cmd.refreshDeductions
 	sm, err := ctx.SourceManager()
 	if err != nil {
 		return err
@@ -179,6 +199,16 @@ func (cmd *ensureCommand) Run(ctx *dep.Ctx, args []string) error {
 	if ctx.Verbose {
 		params.TraceLogger = ctx.Err
 	}
+	params.Prefetch = ctx.Prefetch
+	params.MaxAttempts = ctx.SolveMaxAttempts
+	params.Timeout = ctx.SolveTimeout
+	params.Minimal = cmd.strategy == "minimal"
+
+	finishTraces, err := cmd.startTraces(ctx, &params)
+	if err != nil {
+		return err
+	}
+	defer finishTraces()
 
 	if cmd.vendorOnly {
 		return cmd.runVendorOnly(ctx, args, p, sm, params)
@@ -218,6 +248,65 @@ func (cmd *ensureCommand) Run(ctx *dep.Ctx, args []string) error {
 	return cmd.runDefault(ctx, args, p, sm, params)
 }
 
+// startTraces sets params up to record the solve in the files named by
+// -trace-json and -trace-html, if any. The returned function finishes writing
+// them, once the solve is done.
+func (cmd *ensureCommand) startTraces(ctx *dep.Ctx, params *gps.SolveParameters) (func(), error) {
+	var (
+		tracers multiTracer
+		files   []*os.File
+		finish  []func() error
+	)
+	done := func() {
+		for k, f := range files {
+			if err := finish[k](); err != nil {
+				ctx.Err.Printf("Warning: unable to write the solver trace to %s: %v\n", f.Name(), err)
+			}
+			f.Close()
+		}
+	}
+
+	if cmd.traceJSON != "" {
+		f, err := os.Create(cmd.traceJSON)
+		if err != nil {
+			done()
+			return nil, errors.Wrap(err, "unable to create the solver trace file")
+		}
+		tr := gps.NewJSONTracer(f)
+		tracers = append(tracers, tr)
+		files, finish = append(files, f), append(finish, tr.Flush)
+	}
+
+	if cmd.traceHTML != "" {
+		f, err := os.Create(cmd.traceHTML)
+		if err != nil {
+			done()
+			return nil, errors.Wrap(err, "unable to create the solver trace file")
+		}
+		tr := newHTMLTracer()
+		tracers = append(tracers, tr)
+		files, finish = append(files, f), append(finish, func() error { return tr.write(f) })
+	}
+
+	switch len(tracers) {
+	case 0:
+	case 1:
+		params.Tracer = tracers[0]
+	default:
+		params.Tracer = tracers
+	}
+	return done, nil
+}
+
+// multiTracer sends trace events to each of several gps.Tracers.
+type multiTracer []gps.Tracer
+
+func (mt multiTracer) Trace(e gps.TraceEvent) {
+	for _, t := range mt {
+		t.Trace(e)
+	}
+}
+
 func (cmd *ensureCommand) validateFlags() error {
 	if cmd.add && cmd.update {
 		return errors.New("cannot pass both -add and -update")
@@ -234,6 +323,21 @@ func (cmd *ensureCommand) validateFlags() error {
 			// TODO(sdboyer) can't think of anything not snarky right now
 			return errors.New("really?")
 		}
+		if cmd.strategy != "" {
+			return errors.New("-vendor-only does not solve, so -strategy would be a no-op; cannot pass them together")
+		}
+		if cmd.traceJSON != "" {
+			return errors.New("-vendor-only does not solve, so -trace-json would be a no-op; cannot pass them together")
+		}
+		if cmd.traceHTML != "" {
+			return errors.New("-vendor-only does not solve, so -trace-html would be a no-op; cannot pass them together")
+		}
+	}
+
+	switch cmd.strategy {
+	case "", "minimal":
+	default:
+		return errors.Errorf("invalid -strategy %q; the only strategy is \"minimal\"", cmd.strategy)
 	}
 	return nil
 }
@@ -257,13 +361,20 @@ func (cmd *ensureCommand) runDefault(ctx *dep.Ctx, args []string, p *dep.Project
 
 	var solve bool
 	lock := p.ChangedLock
-	if lock != nil {
+	if params.Minimal {
+		// The solution need not match Gopkg.lock, so always solve.
+		solve = true
+	} else if lock != nil {
 		lsat := verify.LockSatisfiesInputs(p.Lock, p.Manifest, params.RootPackageTree)
 		if !lsat.Satisfied() {
 			if ctx.Verbose {
 				ctx.Out.Printf("# Gopkg.lock is out of sync with Gopkg.toml and project imports:\n%s\n\n", sprintLockUnsat(lsat))
 			}
 			solve = true
+		} else if len(p.Lock.LocalProjects()) > 0 {
+			// A working tree from a local override may have changed since it
+			// was locked, so solve to pick up its current revision.
+			solve = true
 		} else if cmd.noVendor {
 			// The user said not to touch vendor/, so definitely nothing to do.
 			return nil
@@ -280,9 +391,9 @@ func (cmd *ensureCommand) runDefault(ctx *dep.Ctx, args []string, p *dep.Project
 
 		solution, err := solver.Solve(context.TODO())
 		if err != nil {
-			return handleAllTheFailuresOfTheWorld(err)
+			return handleSolveFailure(ctx, err, cmd.jsonErrors)
 		}
-		lock = dep.LockFromSolution(solution, p.Manifest.PruneOptions)
+		lock = dep.LockFromSolution(solution, p.Manifest.PruneOptions, p.Manifest.LocalPaths)
 	}
 
 	dw, err := dep.NewDeltaWriter(p, lock, cmd.vendorBehavior())
@@ -358,10 +469,10 @@ func (cmd *ensureCommand) runUpdate(ctx *dep.Ctx, args []string, p *dep.Project,
 		// TODO(sdboyer) special handling for warning cases as described in spec
 		// - e.g., named projects did not upgrade even though newer versions
 		// were available.
-		return handleAllTheFailuresOfTheWorld(err)
+		return handleSolveFailure(ctx, err, cmd.jsonErrors)
 	}
 
-	dw, err := dep.NewDeltaWriter(p, dep.LockFromSolution(solution, p.Manifest.PruneOptions), cmd.vendorBehavior())
+	dw, err := dep.NewDeltaWriter(p, dep.LockFromSolution(solution, p.Manifest.PruneOptions, p.Manifest.LocalPaths), cmd.vendorBehavior())
 	if err != nil {
 		return err
 	}
@@ -587,7 +698,7 @@ func (cmd *ensureCommand) runAdd(ctx *dep.Ctx, args []string, p *dep.Project, sm
 	solution, err := solver.Solve(context.TODO())
 	if err != nil {
 		// TODO(sdboyer) detect if the failure was specifically about some of the -add arguments
-		return handleAllTheFailuresOfTheWorld(err)
+		return handleSolveFailure(ctx, err, cmd.jsonErrors)
 	}
 
 	// Prep post-actions and feedback from adds.
@@ -630,7 +741,7 @@ func (cmd *ensureCommand) runAdd(ctx *dep.Ctx, args []string, p *dep.Project, sm
 	}
 	sort.Strings(reqlist)
 
-	dw, err := dep.NewDeltaWriter(p, dep.LockFromSolution(solution, p.Manifest.PruneOptions), cmd.vendorBehavior())
+	dw, err := dep.NewDeltaWriter(p, dep.LockFromSolution(solution, p.Manifest.PruneOptions, p.Manifest.LocalPaths), cmd.vendorBehavior())
 	if err != nil {
 		return err
 	}
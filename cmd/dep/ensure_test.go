@@ -43,7 +43,17 @@ func TestInvalidEnsureFlagCombinations(t *testing.T) {
 	if err := ec.validateFlags(); err == nil {
 		t.Error("-vendor-only with -no-vendor should fail validation")
 	}
-	ec.noVendor = false
+
+	ec.noVendor, ec.strategy = false, "minimal"
+	if err := ec.validateFlags(); err == nil {
+		t.Error("-vendor-only with -strategy should fail validation")
+	}
+
+	ec.vendorOnly, ec.strategy = false, "maximal"
+	if err := ec.validateFlags(); err == nil {
+		t.Error("an unknown -strategy should fail validation")
+	}
+	ec.vendorOnly, ec.strategy = true, ""
 
 	// Also verify that the plain ensure path takes no args. This is a shady
 	// test, as lots of other things COULD return errors, and we don't check
@@ -5,8 +5,11 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 
+	"github.com/golang/dep"
 	"github.com/golang/dep/gps"
 	"github.com/pkg/errors"
 )
@@ -21,3 +24,20 @@ func handleAllTheFailuresOfTheWorld(err error) error {
 
 	return errors.Wrap(err, "Solving failure")
 }
+
+// handleSolveFailure handles a failure to solve like
+// handleAllTheFailuresOfTheWorld. If asJSON is true, the structured report of
+// the failure is printed to ctx.Out instead of being returned.
+func handleSolveFailure(ctx *dep.Ctx, err error, asJSON bool) error {
+	err = handleAllTheFailuresOfTheWorld(err)
+	if err == nil || !asJSON {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if jerr := json.NewEncoder(&buf).Encode(gps.ReportFailure(err)); jerr != nil {
+		return err
+	}
+	ctx.Out.Print(buf.String())
+	return silentfail{}
+}
@@ -137,6 +137,9 @@ func (cmd *initCommand) Run(ctx *dep.Ctx, args []string) error {
 		Manifest:        p.Manifest,
 		Lock:            p.Lock,
 		ProjectAnalyzer: rootAnalyzer,
+		Prefetch:        ctx.Prefetch,
+		MaxAttempts:     ctx.SolveMaxAttempts,
+		Timeout:         ctx.SolveTimeout,
 	}
 
 	if ctx.Verbose {
@@ -157,7 +160,7 @@ func (cmd *initCommand) Run(ctx *dep.Ctx, args []string) error {
 		err = handleAllTheFailuresOfTheWorld(err)
 		return errors.Wrap(err, "init failed: unable to solve the dependency graph")
 	}
-	p.Lock = dep.LockFromSolution(soln, p.Manifest.PruneOptions)
+	p.Lock = dep.LockFromSolution(soln, p.Manifest.PruneOptions, p.Manifest.LocalPaths)
 
 	rootAnalyzer.FinalizeRootManifestAndLock(p.Manifest, p.Lock, copyLock)
 
@@ -0,0 +1,237 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/internal/fs"
+	"github.com/golang/dep/internal/license"
+	"github.com/pkg/errors"
+)
+
+const licenseShortHelp = `Report the licenses of vendored dependencies`
+const licenseLongHelp = `
+License finds the license and other legal files that are kept in vendor for
+each project in Gopkg.lock, and identifies the license in each by its SPDX
+identifier. Files that cannot be identified, such as AUTHORS or NOTICE, are
+listed without one.
+
+  PROJECT   Import path
+  VERSION   Version chosen, from the lock
+  LICENSES  SPDX identifiers of the licenses found, or UNKNOWN
+  FILES     Legal files found in the project
+
+If Gopkg.toml has a [license] table with an allowed list, license exits 1 when
+any project has a license that is not on the list, or has none that can be
+identified:
+
+  [license]
+    allowed = ["Apache-2.0", "BSD-3-Clause", "MIT"]
+
+License only reads the vendor directory; run "dep ensure -vendor-only" first if
+it is out of date.
+`
+
+type licenseCommand struct {
+	json bool
+	csv  bool
+}
+
+func (cmd *licenseCommand) Name() string      { return "license" }
+func (cmd *licenseCommand) Args() string      { return "[-json | -csv]" }
+func (cmd *licenseCommand) ShortHelp() string { return licenseShortHelp }
+func (cmd *licenseCommand) LongHelp() string  { return licenseLongHelp }
+func (cmd *licenseCommand) Hidden() bool      { return false }
+
+func (cmd *licenseCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.json, "json", false, "output in JSON format")
+	fs.BoolVar(&cmd.csv, "csv", false, "output in CSV format")
+}
+
+func (cmd *licenseCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) > 0 {
+		return errors.Errorf("license takes no arguments")
+	}
+	if cmd.json && cmd.csv {
+		return errors.New("cannot pass multiple output format flags")
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+	if p.Lock == nil {
+		return errors.Errorf("no Gopkg.lock found. Run `dep ensure` to generate lock file")
+	}
+
+	reports, err := licenseReports(filepath.Join(p.AbsRoot, "vendor"), p.Lock.Projects())
+	if err != nil {
+		return err
+	}
+
+	allowed := make(map[string]bool, len(p.Manifest.AllowedLicenses))
+	for _, id := range p.Manifest.AllowedLicenses {
+		allowed[id] = true
+	}
+
+	var buf bytes.Buffer
+	switch {
+	case cmd.json:
+		err = json.NewEncoder(&buf).Encode(reports)
+	case cmd.csv:
+		w := csv.NewWriter(&buf)
+		w.Write([]string{"Project", "Version", "Licenses", "Files"})
+		for _, r := range reports {
+			w.Write([]string{r.ProjectRoot, r.Version, strings.Join(r.Licenses, " "), strings.Join(r.Files, " ")})
+		}
+		w.Flush()
+		err = w.Error()
+	default:
+		tw := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "PROJECT\tVERSION\tLICENSES\tFILES")
+		for _, r := range reports {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.ProjectRoot, r.Version, strings.Join(r.Licenses, ", "), strings.Join(r.Files, ", "))
+		}
+		err = tw.Flush()
+	}
+	if err != nil {
+		return err
+	}
+	ctx.Out.Print(buf.String())
+
+	if len(allowed) == 0 {
+		return nil
+	}
+
+	var fail bool
+	for _, r := range reports {
+		if bad := r.disallowed(allowed); len(bad) > 0 {
+			if !fail {
+				fail = true
+				ctx.Err.Printf("# licenses not allowed by %s:\n", dep.ManifestName)
+			}
+			ctx.Err.Printf("%s: %s\n", r.ProjectRoot, strings.Join(bad, ", "))
+		}
+	}
+	if fail {
+		return silentfail{}
+	}
+	return nil
+}
+
+// licenseReport holds the legal files found in a single vendored project and
+// the licenses identified in them.
+type licenseReport struct {
+	ProjectRoot string
+	Version     string
+	// Licenses holds the sorted SPDX identifiers of the licenses found, or
+	// license.Unknown alone if there are none.
+	Licenses []string
+	// Files holds the slash-separated paths of the legal files, relative to
+	// the project root.
+	Files []string
+}
+
+// disallowed returns the licenses in r that are not in allowed.
+func (r licenseReport) disallowed(allowed map[string]bool) []string {
+	var bad []string
+	for _, id := range r.Licenses {
+		if !allowed[id] {
+			bad = append(bad, id)
+		}
+	}
+	return bad
+}
+
+// licenseReports builds a licenseReport for each of the locked projects,
+// reading their files from vendorDir.
+func licenseReports(vendorDir string, lps []gps.LockedProject) ([]licenseReport, error) {
+	reports := make([]licenseReport, 0, len(lps))
+	for _, lp := range lps {
+		pr := lp.Ident().ProjectRoot
+		dir := filepath.Join(vendorDir, filepath.FromSlash(string(pr)))
+
+		isDir, err := fs.IsDir(dir)
+		if err != nil || !isDir {
+			return nil, errors.Errorf("%s is missing from vendor; run `dep ensure -vendor-only` first", pr)
+		}
+
+		r, err := scanLicenses(dir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read legal files for %s", pr)
+		}
+		r.ProjectRoot = string(pr)
+		r.Version = formatVersion(lp.Version())
+		reports = append(reports, r)
+	}
+
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].ProjectRoot < reports[j].ProjectRoot
+	})
+	return reports, nil
+}
+
+// scanLicenses classifies the files within dir that pruning preserves for legal
+// reasons. Nested vendor directories are skipped, as they belong to other
+// projects.
+func scanLicenses(dir string) (licenseReport, error) {
+	var r licenseReport
+	ids := make(map[string]bool)
+
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			if path != dir && fi.Name() == "vendor" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !fi.Mode().IsRegular() || !gps.IsPreservedFile(fi.Name()) {
+			return nil
+		}
+
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		r.Files = append(r.Files, filepath.ToSlash(rel))
+
+		if id := license.Classify(data); id != license.Unknown {
+			ids[id] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return r, err
+	}
+
+	for id := range ids {
+		r.Licenses = append(r.Licenses, id)
+	}
+	sort.Strings(r.Licenses)
+	if len(r.Licenses) == 0 {
+		r.Licenses = []string{license.Unknown}
+	}
+	return r, nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep/gps"
+)
+
+func TestLicenseReports(t *testing.T) {
+	vendorDir := filepath.Join("testdata", "license", "vendor")
+	lps := []gps.LockedProject{
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/example/foo"}, gps.NewVersion("v1.0.0").Pair("abc"), []string{"."}),
+		gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/example/bar"}, gps.NewBranch("master").Pair("def"), []string{"."}),
+	}
+
+	reports, err := licenseReports(vendorDir, lps)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []licenseReport{
+		{
+			ProjectRoot: "github.com/example/bar",
+			Version:     "branch master",
+			Licenses:    []string{"UNKNOWN"},
+			Files:       []string{"sub/COPYING"},
+		},
+		{
+			ProjectRoot: "github.com/example/foo",
+			Version:     "v1.0.0",
+			Licenses:    []string{"MIT"},
+			Files:       []string{"AUTHORS", "LICENSE"},
+		},
+	}
+	if !reflect.DeepEqual(reports, want) {
+		t.Fatalf("unexpected reports:\n\t(GOT): %#v\n\t(WNT): %#v", reports, want)
+	}
+
+	allowed := map[string]bool{"MIT": true}
+	if bad := reports[0].disallowed(allowed); !reflect.DeepEqual(bad, []string{"UNKNOWN"}) {
+		t.Errorf("expected UNKNOWN to be disallowed, got %v", bad)
+	}
+	if bad := reports[1].disallowed(allowed); len(bad) != 0 {
+		t.Errorf("expected MIT to be allowed, got %v", bad)
+	}
+
+	missing := append(lps, gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: "github.com/example/baz"}, gps.NewVersion("v1.0.0"), []string{"."}))
+	if _, err := licenseReports(vendorDir, missing); err == nil {
+		t.Error("expected an error for a project missing from vendor")
+	}
+}
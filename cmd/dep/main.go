@@ -16,11 +16,13 @@ import (
 	"path/filepath"
 	"runtime"
 	"runtime/pprof"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 	"time"
 
 	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
 	"github.com/golang/dep/internal/fs"
 )
 
@@ -195,14 +197,85 @@ func (c *Config) Run() int {
 				}
 			}
 
+			// The go get metadata cached for import paths has its own TTL,
+			// which defaults to that of the rest of the cached data.
+			deductionCacheAge := cacheAge
+			if env := getEnv(c.Env, "DEPDEDUCTIONCACHEAGE"); env != "" {
+				var err error
+				deductionCacheAge, err = time.ParseDuration(env)
+				if err != nil {
+					errLogger.Printf("dep: failed to parse $DEPDEDUCTIONCACHEAGE duration %q: %v\n", env, err)
+					return errorExitCode
+				}
+			}
+
+			urlRewrites, err := parseURLRewrites(getEnv(c.Env, "DEPURLREWRITES"))
+			if err != nil {
+				errLogger.Printf("dep: failed to parse $DEPURLREWRITES: %v\n", err)
+				return errorExitCode
+			}
+
+			var deducers []gps.CustomDeducer
+			if env := getEnv(c.Env, "DEPDEDUCERS"); env != "" {
+				if deducers, err = dep.LoadDeducers(env); err != nil {
+					errLogger.Printf("dep: failed to read $DEPDEDUCERS: %v\n", err)
+					return errorExitCode
+				}
+			}
+
+			var httpConfig gps.HTTPConfig
+			if env := getEnv(c.Env, "DEPHTTPCONFIG"); env != "" {
+				if httpConfig, err = dep.LoadHTTPConfig(env); err != nil {
+					errLogger.Printf("dep: failed to read $DEPHTTPCONFIG: %v\n", err)
+					return errorExitCode
+				}
+			}
+
+			goProxy := parseGoProxy(getEnv(c.Env, "DEPGOPROXY"))
+
+			var prefetch int
+			if env := getEnv(c.Env, "DEPPREFETCH"); env != "" {
+				if prefetch, err = strconv.Atoi(env); err != nil {
+					errLogger.Printf("dep: failed to parse $DEPPREFETCH %q: %v\n", env, err)
+					return errorExitCode
+				}
+			}
+
+			var solveMaxAttempts int
+			if env := getEnv(c.Env, "DEPSOLVEMAXATTEMPTS"); env != "" {
+				if solveMaxAttempts, err = strconv.Atoi(env); err != nil {
+					errLogger.Printf("dep: failed to parse $DEPSOLVEMAXATTEMPTS %q: %v\n", env, err)
+					return errorExitCode
+				}
+			}
+
+			var solveTimeout time.Duration
+			if env := getEnv(c.Env, "DEPSOLVETIMEOUT"); env != "" {
+				if solveTimeout, err = time.ParseDuration(env); err != nil {
+					errLogger.Printf("dep: failed to parse $DEPSOLVETIMEOUT duration %q: %v\n", env, err)
+					return errorExitCode
+				}
+			}
+
 			// Set up dep context.
 			ctx := &dep.Ctx{
-				Out:            outLogger,
-				Err:            errLogger,
-				Verbose:        verbose,
-				DisableLocking: getEnv(c.Env, "DEPNOLOCK") != "",
-				Cachedir:       cachedir,
-				CacheAge:       cacheAge,
+				Out:               outLogger,
+				Err:               errLogger,
+				Verbose:           verbose,
+				DisableLocking:    getEnv(c.Env, "DEPNOLOCK") != "",
+				Cachedir:          cachedir,
+				CacheAge:          cacheAge,
+				Offline:           getEnv(c.Env, "DEPOFFLINE") != "",
+				URLRewrites:       urlRewrites,
+				Deducers:          deducers,
+				DeductionCacheAge: deductionCacheAge,
+				HTTP:              httpConfig,
+				GoProxy:           goProxy,
+				LinkLocal:         getEnv(c.Env, "DEPLINKLOCAL") != "",
+				PartialClone:      getEnv(c.Env, "DEPPARTIALCLONE") != "",
+				Prefetch:          prefetch,
+				SolveMaxAttempts:  solveMaxAttempts,
+				SolveTimeout:      solveTimeout,
 			}
 
 			GOPATHS := filepath.SplitList(getEnv(c.Env, "GOPATH"))
@@ -235,9 +308,14 @@ func commandList() []command {
 		&initCommand{},
 		&statusCommand{},
 		&ensureCommand{},
+		&removeCommand{},
 		&pruneCommand{},
 		&versionCommand{},
 		&checkCommand{},
+		&whyCommand{},
+		&auditCommand{},
+		&licenseCommand{},
+		&cacheCommand{},
 	}
 }
 
@@ -358,6 +436,36 @@ func getEnv(env []string, key string) string {
 	return ""
 }
 
+// parseURLRewrites parses a comma-separated list of URL rewrite rules, each of
+// the form from=to.
+func parseURLRewrites(s string) ([]gps.URLRewrite, error) {
+	var rules []gps.URLRewrite
+	for _, rule := range strings.Split(s, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		kv := strings.SplitN(rule, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("rule %q is not of the form from=to", rule)
+		}
+		rules = append(rules, gps.URLRewrite{From: kv[0], To: kv[1]})
+	}
+	return rules, nil
+}
+
+// parseGoProxy parses a comma-separated list of Go module proxies, as in
+// $DEPGOPROXY.
+func parseGoProxy(s string) []string {
+	var proxies []string
+	for _, p := range strings.Split(s, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			proxies = append(proxies, p)
+		}
+	}
+	return proxies
+}
+
 // commentWriter writes a Go comment to the underlying io.Writer,
 // using line comment form (//).
 //
@@ -0,0 +1,179 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/paths"
+	"github.com/pkg/errors"
+)
+
+const removeShortHelp = `Remove dependencies from Gopkg.toml and re-solve`
+const removeLongHelp = `
+Remove deletes every rule that Gopkg.toml holds for each of the named projects:
+their [[constraint]], [[override]] and [[prune.project]] stanzas, and any
+required or noverify entries that fall within them. It then solves the
+dependency graph again and writes Gopkg.toml, Gopkg.lock and vendor/.
+
+Gopkg.toml is rewritten in canonical form, so comments in it are not kept.
+
+Remove does not change any import statements. If the project's packages are
+still imported, remove warns about it, and the project stays in Gopkg.lock and
+vendor/ - only without the rules that were removed.
+
+Examples:
+
+  dep remove github.com/pkg/foo              Drop all rules for github.com/pkg/foo
+  dep remove -no-vendor github.com/pkg/foo   As above, but leave vendor/ unchanged
+`
+
+var errRemoveDepsFailed = errors.New("removing dependencies failed")
+
+type removeCommand struct {
+	noVendor bool
+	dryRun   bool
+}
+
+func (cmd *removeCommand) Name() string      { return "remove" }
+func (cmd *removeCommand) Args() string      { return "[-no-vendor] [-dry-run] [-v] <project>..." }
+func (cmd *removeCommand) ShortHelp() string { return removeShortHelp }
+func (cmd *removeCommand) LongHelp() string  { return removeLongHelp }
+func (cmd *removeCommand) Hidden() bool      { return false }
+
+func (cmd *removeCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.noVendor, "no-vendor", false, "update Gopkg.lock (if needed), but do not update vendor/")
+	fs.BoolVar(&cmd.dryRun, "dry-run", false, "only report the changes that would be made")
+}
+
+func (cmd *removeCommand) Run(ctx *dep.Ctx, args []string) error {
+	if len(args) == 0 {
+		return errors.New("must specify at least one project to remove")
+	}
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	go p.VerifyVendor()
+
+	var failed []error
+	roots := make([]gps.ProjectRoot, 0, len(args))
+	for _, arg := range args {
+		pr, err := sm.DeduceProjectRoot(arg)
+		if err != nil {
+			failed = append(failed, errors.Wrapf(err, "could not infer project root from dependency path: %s", arg))
+			continue
+		}
+		if pr == p.ImportRoot {
+			failed = append(failed, errors.New("cannot remove the current project from itself"))
+			continue
+		}
+		if !p.Manifest.RemoveRulesFor(pr) {
+			failed = append(failed, errors.Errorf("nothing to remove, %s has no rules for %s", dep.ManifestName, pr))
+			continue
+		}
+		roots = append(roots, pr)
+	}
+
+	if len(failed) > 0 {
+		ctx.Err.Printf("Failed to remove the dependencies:\n\n")
+		for _, err := range failed {
+			ctx.Err.Println("  ✗", err.Error())
+		}
+		ctx.Err.Println()
+		return errRemoveDepsFailed
+	}
+
+	for _, pr := range roots {
+		if imps := importsWithin(p, pr); len(imps) > 0 {
+			ctx.Err.Printf("Warning: %s is still imported by your project:\n", pr)
+			ctx.Err.Printf("\t%s\n", strings.Join(imps, "\n\t"))
+			ctx.Err.Printf("Its rules have been removed from %s, but it will remain in %s and vendor/ until these imports are removed.\n\n", dep.ManifestName, dep.LockName)
+		}
+	}
+
+	params := p.MakeParams()
+	if ctx.Verbose {
+		params.TraceLogger = ctx.Err
+	}
+	params.Prefetch = ctx.Prefetch
+	params.MaxAttempts = ctx.SolveMaxAttempts
+	params.Timeout = ctx.SolveTimeout
+	if err := ctx.ValidateParams(sm, params); err != nil {
+		return err
+	}
+
+	solver, err := gps.Prepare(params, sm)
+	if err != nil {
+		return errors.Wrap(err, "prepare solver")
+	}
+	solution, err := solver.Solve(context.TODO())
+	if err != nil {
+		return handleAllTheFailuresOfTheWorld(err)
+	}
+
+	status, err := p.VerifyVendor()
+	if err != nil {
+		return err
+	}
+
+	vendor := dep.VendorOnChanged
+	if cmd.noVendor {
+		vendor = dep.VendorNever
+	}
+	sw, err := dep.NewSafeWriter(p.Manifest, p.Lock, dep.LockFromSolution(solution, p.Manifest.PruneOptions, p.Manifest.LocalPaths), vendor, p.Manifest.PruneOptions, status)
+	if err != nil {
+		return err
+	}
+
+	if cmd.dryRun {
+		return sw.PrintPreparedActions(ctx.Out, ctx.Verbose)
+	}
+
+	var logger *log.Logger
+	if ctx.Verbose {
+		logger = ctx.Err
+	}
+	return errors.WithMessage(sw.Write(p.AbsRoot, sm, false, logger), "grouped write of manifest, lock and vendor")
+}
+
+// importsWithin returns the sorted import paths within the project at pr that
+// the root project's packages import, or that the manifest requires.
+func importsWithin(p *dep.Project, pr gps.ProjectRoot) []string {
+	rm, _ := p.RootPackageTree.ToReachMap(true, true, false, p.Manifest.IgnoredPackages())
+	imps := rm.FlattenFn(paths.IsStandardImportPath)
+	for imp := range p.Manifest.RequiredPackages() {
+		imps = append(imps, imp)
+	}
+
+	var within []string
+	seen := make(map[string]bool)
+	for _, imp := range imps {
+		if seen[imp] {
+			continue
+		}
+		seen[imp] = true
+		if imp == string(pr) || strings.HasPrefix(imp, string(pr)+"/") {
+			within = append(within, imp)
+		}
+	}
+	sort.Strings(within)
+	return within
+}
@@ -0,0 +1,52 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps/pkgtree"
+)
+
+func TestImportsWithin(t *testing.T) {
+	m := dep.NewManifest()
+	m.Required = []string{"github.com/example/foo/cmd/tool"}
+
+	p := &dep.Project{
+		ImportRoot: "github.com/golang/notexist",
+		Manifest:   m,
+		RootPackageTree: pkgtree.PackageTree{
+			ImportRoot: "github.com/golang/notexist",
+			Packages: map[string]pkgtree.PackageOrErr{
+				"github.com/golang/notexist": {
+					P: pkgtree.Package{
+						ImportPath: "github.com/golang/notexist",
+						Name:       "main",
+						Imports:    []string{"fmt", "github.com/example/foo", "github.com/example/foobar"},
+					},
+				},
+				"github.com/golang/notexist/sub": {
+					P: pkgtree.Package{
+						ImportPath: "github.com/golang/notexist/sub",
+						Name:       "sub",
+						Imports:    []string{"github.com/example/foo", "github.com/example/foo/bar"},
+					},
+				},
+			},
+		},
+	}
+
+	got := importsWithin(p, "github.com/example/foo")
+	want := []string{"github.com/example/foo", "github.com/example/foo/bar", "github.com/example/foo/cmd/tool"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected imports:\n\t(GOT): %v\n\t(WNT): %v", got, want)
+	}
+
+	if got := importsWithin(p, "github.com/example/baz"); len(got) != 0 {
+		t.Errorf("expected no imports, got %v", got)
+	}
+}
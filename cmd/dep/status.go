@@ -20,6 +20,7 @@ import (
 	"text/tabwriter"
 	"text/template"
 
+	"github.com/Masterminds/semver"
 	"github.com/golang/dep"
 	"github.com/golang/dep/gps"
 	"github.com/golang/dep/gps/paths"
@@ -87,6 +88,13 @@ dep status -detail -f='{{range $i, $p := .Projects}}{{if ne .Source "" -}}
 	to the full output document, instead of to packages one at a time.
 	Available flags are as follows: ` + availableDefaultTemplateVariables + `
 
+dep status -outdated
+
+	Displays each dependency that has a newer version available, along with
+	the newest version allowed by its current constraint, the newest version
+	that would require changing Gopkg.toml, and whether each of those is a
+	patch, minor or major release, or new commits on a branch.
+
 dep status -json
 
 	Displays the dependency information in JSON format as a list of
@@ -128,22 +136,26 @@ func (cmd *statusCommand) Register(fs *flag.FlagSet) {
 	fs.BoolVar(&cmd.lock, "lock", false, "output in the lock file format (assumes -detail)")
 	fs.BoolVar(&cmd.dot, "dot", false, "output the dependency graph in GraphViz format")
 	fs.BoolVar(&cmd.old, "old", false, "only show out-of-date dependencies")
+	fs.BoolVar(&cmd.outdated, "outdated", false, "only show out-of-date dependencies, classified by the kind of update available")
 	fs.BoolVar(&cmd.missing, "missing", false, "only show missing dependencies")
 	fs.StringVar(&cmd.outFilePath, "out", "", "path to a file to which to write the output. Blank value will be ignored")
 	fs.BoolVar(&cmd.detail, "detail", false, "include more detail in the chosen format")
+	fs.BoolVar(&cmd.refreshDeductions, "refresh-deductions", false, "retrieve go get metadata again instead of using the cached metadata")
 }
 
 type statusCommand struct {
-	examples    bool
-	json        bool
-	template    string
-	lock        bool
-	output      string
-	dot         bool
-	old         bool
-	missing     bool
-	outFilePath string
-	detail      bool
+	examples          bool
+	json              bool
+	template          string
+	lock              bool
+	output            string
+	dot               bool
+	old               bool
+	outdated          bool
+	missing           bool
+	outFilePath       string
+	detail            bool
+	refreshDeductions bool
 }
 
 type outputter interface {
@@ -165,6 +177,13 @@ type oldOutputter interface {
 	OldFooter() error
 }
 
+// Only a subset of the outputters should be able to output outdated statuses.
+type outdatedOutputter interface {
+	OutdatedHeader() error
+	OutdatedLine(*OutdatedStatus) error
+	OutdatedFooter() error
+}
+
 type tableOutput struct{ w *tabwriter.Writer }
 
 func (out *tableOutput) BasicHeader() error {
@@ -250,12 +269,36 @@ func (out *tableOutput) OldFooter() error {
 	return out.w.Flush()
 }
 
+func (out *tableOutput) OutdatedHeader() error {
+	_, err := fmt.Fprintf(out.w, "PROJECT\tCONSTRAINT\tVERSION\tREVISION\tWANTED\tLATEST\tUPDATE\n")
+	return err
+}
+
+func (out *tableOutput) OutdatedLine(os *OutdatedStatus) error {
+	_, err := fmt.Fprintf(out.w,
+		"%s\t%s\t%s\t%s\t%s\t%s\t%s\t\n",
+		os.ProjectRoot,
+		os.getConsolidatedConstraint(),
+		formatVersion(os.Version),
+		formatVersion(os.Revision),
+		formatVersion(os.Wanted),
+		formatVersion(os.Latest),
+		os.getConsolidatedUpdate(),
+	)
+	return err
+}
+
+func (out *tableOutput) OutdatedFooter() error {
+	return out.w.Flush()
+}
+
 type jsonOutput struct {
-	w       io.Writer
-	basic   []*rawStatus
-	detail  []rawDetailProject
-	missing []*MissingStatus
-	old     []*rawOldStatus
+	w        io.Writer
+	basic    []*rawStatus
+	detail   []rawDetailProject
+	missing  []*MissingStatus
+	old      []*rawOldStatus
+	outdated []*rawOutdatedStatus
 }
 
 func (out *jsonOutput) BasicHeader() error {
@@ -319,6 +362,20 @@ func (out *jsonOutput) OldFooter() error {
 	return json.NewEncoder(out.w).Encode(out.old)
 }
 
+func (out *jsonOutput) OutdatedHeader() error {
+	out.outdated = []*rawOutdatedStatus{}
+	return nil
+}
+
+func (out *jsonOutput) OutdatedLine(os *OutdatedStatus) error {
+	out.outdated = append(out.outdated, os.marshalJSON())
+	return nil
+}
+
+func (out *jsonOutput) OutdatedFooter() error {
+	return json.NewEncoder(out.w).Encode(out.outdated)
+}
+
 type dotOutput struct {
 	w io.Writer
 	o string
@@ -424,6 +481,12 @@ func (out *templateOutput) OldLine(os *OldStatus) error {
 	return out.tmpl.Execute(out.w, os)
 }
 
+func (out *templateOutput) OutdatedHeader() error { return nil }
+func (out *templateOutput) OutdatedFooter() error { return nil }
+func (out *templateOutput) OutdatedLine(os *OutdatedStatus) error {
+	return out.tmpl.Execute(out.w, os.marshalJSON())
+}
+
 func (out *templateOutput) MissingHeader() error { return nil }
 func (out *templateOutput) MissingFooter() error { return nil }
 func (out *templateOutput) MissingLine(ms *MissingStatus) error {
@@ -445,6 +508,7 @@ func (cmd *statusCommand) Run(ctx *dep.Ctx, args []string) error {
 		return err
 	}
 
+	ctx.RefreshDeductions = cmd.refreshDeductions
 	sm, err := ctx.SourceManager()
 	if err != nil {
 		return err
@@ -509,6 +573,16 @@ func (cmd *statusCommand) Run(ctx *dep.Ctx, args []string) error {
 		return err
 	}
 
+	if cmd.outdated {
+		oout, ok := out.(outdatedOutputter)
+		if !ok {
+			return errors.Errorf("invalid output format used")
+		}
+		err = cmd.runOutdated(ctx, oout, p, sm)
+		ctx.Out.Print(buf.String())
+		return err
+	}
+
 	_, errCount, runerr := cmd.runStatusAll(ctx, out, p, sm)
 	if runerr != nil {
 		switch runerr {
@@ -550,6 +624,10 @@ func (cmd *statusCommand) validateFlags() error {
 		opModes = append(opModes, "-old")
 	}
 
+	if cmd.outdated {
+		opModes = append(opModes, "-outdated")
+	}
+
 	if cmd.missing {
 		opModes = append(opModes, "-missing")
 	}
@@ -649,6 +727,9 @@ func (cmd *statusCommand) runOld(ctx *dep.Ctx, out oldOutputter, p *dep.Project,
 		RootDir:         p.AbsRoot,
 		RootPackageTree: ptree,
 		Manifest:        p.Manifest,
+		Prefetch:        ctx.Prefetch,
+		MaxAttempts:     ctx.SolveMaxAttempts,
+		Timeout:         ctx.SolveTimeout,
 		// Locks aren't a part of the input hash check, so we can omit it.
 	}
 
@@ -723,6 +804,278 @@ func (cmd *statusCommand) runOld(ctx *dep.Ctx, out oldOutputter, p *dep.Project,
 	return nil
 }
 
+// OutdatedStatus contains information about a single dependency for which a
+// newer version is available, and the kind of update it would be.
+type OutdatedStatus struct {
+	ProjectRoot string
+	Constraint  gps.Constraint
+	Version     gps.UnpairedVersion
+	Revision    gps.Revision
+	// Wanted is the newest version allowed by Constraint, if it is newer than
+	// the locked version.
+	Wanted gps.Version
+	// Latest is the newest version overall, if Constraint does not allow it.
+	Latest gps.Version
+	// Update is the kind of update to Wanted: patch, minor, major or branch.
+	Update string
+	// LatestUpdate is the kind of update to Latest: patch, minor or major. It
+	// is empty if the locked version is not a semver version.
+	LatestUpdate string
+	hasOverride  bool
+}
+
+// The kinds of update that an OutdatedStatus may describe.
+const (
+	updatePatch  = "patch"
+	updateMinor  = "minor"
+	updateMajor  = "major"
+	updateBranch = "branch"
+)
+
+type rawOutdatedStatus struct {
+	ProjectRoot, Constraint, Version, Revision, Wanted, Latest, Update, LatestUpdate string
+}
+
+func (os OutdatedStatus) getConsolidatedConstraint() string {
+	bs := BasicStatus{Constraint: os.Constraint, hasOverride: os.hasOverride}
+	return bs.getConsolidatedConstraint()
+}
+
+// getConsolidatedUpdate lists the kinds of update to Wanted and to Latest, in
+// that order, leaving out those that are empty.
+func (os OutdatedStatus) getConsolidatedUpdate() string {
+	switch {
+	case os.Update == "":
+		return os.LatestUpdate
+	case os.LatestUpdate == "":
+		return os.Update
+	default:
+		return os.Update + ", " + os.LatestUpdate
+	}
+}
+
+func (os OutdatedStatus) marshalJSON() *rawOutdatedStatus {
+	raw := &rawOutdatedStatus{
+		ProjectRoot:  os.ProjectRoot,
+		Constraint:   os.getConsolidatedConstraint(),
+		Revision:     string(os.Revision),
+		Update:       os.Update,
+		LatestUpdate: os.LatestUpdate,
+	}
+	if os.Version != nil {
+		raw.Version = os.Version.String()
+	}
+	if os.Wanted != nil {
+		raw.Wanted = os.Wanted.String()
+	}
+	if os.Latest != nil {
+		raw.Latest = os.Latest.String()
+	}
+	return raw
+}
+
+// newOutdatedStatus compares the version in lp against vl, which must already
+// be sorted with gps.SortPairedForUpgrade, and classifies the kinds of update
+// available within and beyond the given constraint. It returns nil if lp is up
+// to date or is locked to a bare revision or a non-semver tag, as there is no
+// way to judge whether those are outdated.
+func newOutdatedStatus(lp gps.LockedProject, c gps.Constraint, vl []gps.PairedVersion) *OutdatedStatus {
+	if c == nil {
+		c = gps.Any()
+	}
+
+	os := &OutdatedStatus{
+		ProjectRoot: string(lp.Ident().ProjectRoot),
+		Constraint:  c,
+	}
+	switch tv := lp.Version().(type) {
+	case gps.PairedVersion:
+		os.Version = tv.Unpair()
+		os.Revision = tv.Revision()
+	case gps.UnpairedVersion:
+		os.Version = tv
+	default:
+		return nil
+	}
+
+	var cur semver.Version
+	if os.Version.Type() == gps.IsSemver {
+		var err error
+		if cur, err = semver.NewVersion(os.Version.String()); err != nil {
+			return nil
+		}
+	}
+
+	// Prereleases are only candidates for projects already on a prerelease.
+	// As releases are sorted before prereleases, the newest of each must then
+	// be found separately and compared.
+	newest := func(keep func(gps.PairedVersion) bool) (gps.PairedVersion, *semver.Version) {
+		v, sv := newestSemver(vl, func(v gps.PairedVersion, sv semver.Version) bool {
+			return sv.Prerelease() == "" && keep(v)
+		})
+		if cur.Prerelease() != "" {
+			pv, psv := newestSemver(vl, func(v gps.PairedVersion, sv semver.Version) bool {
+				return sv.Prerelease() != "" && keep(v)
+			})
+			if psv != nil && (sv == nil || psv.GreaterThan(*sv)) {
+				v, sv = pv, psv
+			}
+		}
+		return v, sv
+	}
+	latestv, latest := newest(func(gps.PairedVersion) bool { return true })
+
+	switch os.Version.Type() {
+	case gps.IsSemver:
+		wanted, wsv := newest(func(v gps.PairedVersion) bool { return c.Matches(v) })
+		if wanted != nil && wsv.GreaterThan(cur) {
+			os.Wanted = wanted.Unpair()
+			os.Update = semverUpdate(cur, *wsv)
+		}
+		if latestv != nil && !c.Matches(latestv) && latest.GreaterThan(cur) {
+			os.Latest = latestv.Unpair()
+			os.LatestUpdate = semverUpdate(cur, *latest)
+		}
+		if os.Wanted == nil && os.Latest == nil {
+			return nil
+		}
+
+	case gps.IsBranch:
+		for _, v := range vl {
+			if v.Type() == gps.IsBranch && v.String() == os.Version.String() {
+				if v.Revision() != os.Revision {
+					os.Wanted = v.Revision()
+				}
+				break
+			}
+		}
+		if os.Wanted == nil {
+			return nil
+		}
+		os.Update = updateBranch
+		if latestv != nil && !c.Matches(latestv) {
+			os.Latest = latestv.Unpair()
+		}
+
+	default:
+		return nil
+	}
+
+	return os
+}
+
+// semverUpdate classifies the update from cur to v.
+func semverUpdate(cur, v semver.Version) string {
+	switch {
+	case v.Major() != cur.Major():
+		return updateMajor
+	case v.Minor() != cur.Minor():
+		return updateMinor
+	default:
+		return updatePatch
+	}
+}
+
+// newestSemver returns the first semver version in vl for which keep returns
+// true, or nil if there is none. As vl is sorted for upgrade, that is the
+// newest of them, provided keep does not admit both releases and prereleases.
+func newestSemver(vl []gps.PairedVersion, keep func(gps.PairedVersion, semver.Version) bool) (gps.PairedVersion, *semver.Version) {
+	for _, v := range vl {
+		if v.Type() != gps.IsSemver {
+			continue
+		}
+		sv, err := semver.NewVersion(v.String())
+		if err == nil && keep(v, sv) {
+			return v, &sv
+		}
+	}
+	return nil, nil
+}
+
+func (cmd *statusCommand) runOutdated(ctx *dep.Ctx, out outdatedOutputter, p *dep.Project, sm gps.SourceManager) error {
+	logger := ctx.Err
+	if !ctx.Verbose {
+		logger = log.New(ioutil.Discard, "", 0)
+	}
+
+	// Errors while collecting constraints only make the results less precise,
+	// so they are reported rather than failing the whole run.
+	cm, ccerrs := collectConstraints(ctx, p, sm)
+	if len(ccerrs) > 0 && ctx.Verbose {
+		for _, err := range ccerrs {
+			ctx.Err.Println(err.Error())
+		}
+	}
+
+	slp := p.Lock.Projects()
+	sort.Slice(slp, func(i, j int) bool {
+		return slp[i].Ident().Less(slp[j].Ident())
+	})
+
+	logger.Println("Checking upstream projects:")
+
+	statuses := make([]*OutdatedStatus, len(slp))
+	errListVerCh := make(chan error, len(slp))
+
+	var wg sync.WaitGroup
+	for i, proj := range slp {
+		wg.Add(1)
+		logger.Printf("(%d/%d) %s\n", i+1, len(slp), proj.Ident().ProjectRoot)
+
+		go func(i int, proj gps.LockedProject) {
+			defer wg.Done()
+
+			vl, err := sm.ListVersions(proj.Ident())
+			if err != nil {
+				errListVerCh <- err
+				return
+			}
+			gps.SortPairedForUpgrade(vl)
+
+			c, hasOverride := effectiveConstraint(p.Manifest, cm, proj.Ident().ProjectRoot)
+			if os := newOutdatedStatus(proj, c, vl); os != nil {
+				os.hasOverride = hasOverride
+				statuses[i] = os
+			}
+		}(i, proj)
+	}
+
+	wg.Wait()
+	close(errListVerCh)
+
+	// Newline after printing the status progress output.
+	logger.Println()
+
+	if err := out.OutdatedHeader(); err != nil {
+		return err
+	}
+	for _, os := range statuses {
+		if os == nil {
+			continue
+		}
+		if err := out.OutdatedLine(os); err != nil {
+			return err
+		}
+	}
+	if err := out.OutdatedFooter(); err != nil {
+		return err
+	}
+
+	if len(errListVerCh) > 0 {
+		if ctx.Verbose {
+			for err := range errListVerCh {
+				ctx.Err.Println(err.Error())
+			}
+			ctx.Err.Println()
+		} else {
+			ctx.Out.Printf("The status of %d projects are unknown due to errors. Rerun with `-v` flag to see details.\n", len(errListVerCh))
+		}
+		return errFailedUpdate
+	}
+
+	return nil
+}
+
 type rawStatus struct {
 	ProjectRoot  string
 	Constraint   string
@@ -983,20 +1336,7 @@ func (cmd *statusCommand) runStatusAll(ctx *dep.Ctx, out outputter, p *dep.Proje
 					bs.Revision = tv.Revision()
 				}
 
-				// Check if the manifest has an override for this project. If so,
-				// set that as the constraint.
-				if pp, has := p.Manifest.Ovr[proj.Ident().ProjectRoot]; has && pp.Constraint != nil {
-					bs.hasOverride = true
-					bs.Constraint = pp.Constraint
-				} else if pp, has := p.Manifest.Constraints[proj.Ident().ProjectRoot]; has && pp.Constraint != nil {
-					// If the manifest has a constraint then set that as the constraint.
-					bs.Constraint = pp.Constraint
-				} else {
-					bs.Constraint = gps.Any()
-					for _, c := range cm[bs.ProjectRoot] {
-						bs.Constraint = c.Constraint.Intersect(bs.Constraint)
-					}
-				}
+				bs.Constraint, bs.hasOverride = effectiveConstraint(p.Manifest, cm, proj.Ident().ProjectRoot)
 
 				// Only if we have a non-rev and non-plain version do/can we display
 				// anything wrt the version's updateability.
@@ -1397,6 +1737,25 @@ func collectConstraints(ctx *dep.Ctx, p *dep.Project, sm gps.SourceManager) (con
 	return constraintCollection, errs
 }
 
+// effectiveConstraint returns the constraint that applies to the project at pr:
+// an override from the manifest if there is one, else the manifest's own
+// constraint, else the intersection of the constraints in cm. The boolean
+// result indicates whether the constraint is an override.
+func effectiveConstraint(m *dep.Manifest, cm constraintsCollection, pr gps.ProjectRoot) (gps.Constraint, bool) {
+	if pp, has := m.Ovr[pr]; has && pp.Constraint != nil {
+		return pp.Constraint, true
+	}
+	if pp, has := m.Constraints[pr]; has && pp.Constraint != nil {
+		return pp.Constraint, false
+	}
+
+	c := gps.Any()
+	for _, pc := range cm[string(pr)] {
+		c = pc.Constraint.Intersect(c)
+	}
+	return c, false
+}
+
 type byProject []projectConstraint
 
 func (p byProject) Len() int           { return len(p) }
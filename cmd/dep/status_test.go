@@ -839,6 +839,16 @@ func TestValidateFlags(t *testing.T) {
 			cmd:     statusCommand{old: true, template: "foo"},
 			wantErr: nil,
 		},
+		{
+			name:    "outdated with -old",
+			cmd:     statusCommand{old: true, outdated: true},
+			wantErr: errors.Wrapf(errors.New("cannot pass multiple operating mode flags"), "[-old -outdated]"),
+		},
+		{
+			name:    "outdated with -json",
+			cmd:     statusCommand{outdated: true, json: true},
+			wantErr: nil,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -856,6 +866,136 @@ func TestValidateFlags(t *testing.T) {
 	}
 }
 
+func TestNewOutdatedStatus(t *testing.T) {
+	pi := gps.ProjectIdentifier{ProjectRoot: "github.com/foo/bar"}
+	vl := []gps.PairedVersion{
+		gps.NewVersion("v1.0.0").Pair("rev100"),
+		gps.NewVersion("v2.0.0").Pair("rev200"),
+		gps.NewVersion("v1.1.0").Pair("rev110"),
+		gps.NewVersion("v1.0.1").Pair("rev101"),
+		gps.NewVersion("v3.0.0-beta.1").Pair("rev3b1"),
+		gps.NewBranch("master").Pair("revmaster"),
+		gps.NewVersion("footag").Pair("revfoo"),
+	}
+	gps.SortPairedForUpgrade(vl)
+
+	caret, _ := gps.NewSemverConstraint("^1.0.0")
+	tilde, _ := gps.NewSemverConstraint("~1.0.0")
+	any, _ := gps.NewSemverConstraint("*")
+
+	tests := []struct {
+		name       string
+		version    gps.Version
+		constraint gps.Constraint
+		want       *OutdatedStatus
+	}{
+		{
+			name:       "major available, minor allowed",
+			version:    gps.NewVersion("v1.0.0").Pair("rev100"),
+			constraint: caret,
+			want: &OutdatedStatus{
+				Version:      gps.NewVersion("v1.0.0"),
+				Revision:     "rev100",
+				Wanted:       gps.NewVersion("v1.1.0"),
+				Latest:       gps.NewVersion("v2.0.0"),
+				Update:       updateMinor,
+				LatestUpdate: updateMajor,
+			},
+		},
+		{
+			name:       "only patch allowed",
+			version:    gps.NewVersion("v1.0.0").Pair("rev100"),
+			constraint: tilde,
+			want: &OutdatedStatus{
+				Version:      gps.NewVersion("v1.0.0"),
+				Revision:     "rev100",
+				Wanted:       gps.NewVersion("v1.0.1"),
+				Latest:       gps.NewVersion("v2.0.0"),
+				Update:       updatePatch,
+				LatestUpdate: updateMajor,
+			},
+		},
+		{
+			name:       "newest allowed by constraint",
+			version:    gps.NewVersion("v1.1.0").Pair("rev110"),
+			constraint: any,
+			want: &OutdatedStatus{
+				Version:  gps.NewVersion("v1.1.0"),
+				Revision: "rev110",
+				Wanted:   gps.NewVersion("v2.0.0"),
+				Update:   updateMajor,
+			},
+		},
+		{
+			name:       "nothing allowed by constraint",
+			version:    gps.NewVersion("v1.1.0").Pair("rev110"),
+			constraint: caret,
+			want: &OutdatedStatus{
+				Version:      gps.NewVersion("v1.1.0"),
+				Revision:     "rev110",
+				Latest:       gps.NewVersion("v2.0.0"),
+				LatestUpdate: updateMajor,
+			},
+		},
+		{
+			name:       "up to date",
+			version:    gps.NewVersion("v2.0.0").Pair("rev200"),
+			constraint: any,
+		},
+		{
+			name:       "prerelease only offered to prereleases",
+			version:    gps.NewVersion("v3.0.0-alpha.1").Pair("rev3a1"),
+			constraint: any,
+			want: &OutdatedStatus{
+				Version:  gps.NewVersion("v3.0.0-alpha.1"),
+				Revision: "rev3a1",
+				Wanted:   gps.NewVersion("v3.0.0-beta.1"),
+				Update:   updatePatch,
+			},
+		},
+		{
+			name:       "branch drift",
+			version:    gps.NewBranch("master").Pair("revold"),
+			constraint: gps.NewBranch("master"),
+			want: &OutdatedStatus{
+				Version:  gps.NewBranch("master"),
+				Revision: "revold",
+				Wanted:   gps.Revision("revmaster"),
+				Latest:   gps.NewVersion("v2.0.0"),
+				Update:   updateBranch,
+			},
+		},
+		{
+			name:       "branch at tip",
+			version:    gps.NewBranch("master").Pair("revmaster"),
+			constraint: gps.NewBranch("master"),
+		},
+		{
+			name:    "plain tag",
+			version: gps.NewVersion("footag").Pair("revfoo"),
+		},
+		{
+			name:    "bare revision",
+			version: gps.Revision("rev100"),
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			lp := gps.NewLockedProject(pi, tc.version, nil)
+			got := newOutdatedStatus(lp, tc.constraint, vl)
+
+			if tc.want != nil {
+				tc.want.ProjectRoot = string(pi.ProjectRoot)
+				tc.want.Constraint = tc.constraint
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("unexpected OutdatedStatus:\n\t(GOT): %#v\n\t(WNT): %#v", got, tc.want)
+			}
+		})
+	}
+}
+
 func execStatusTemplate(w io.Writer, format string, data interface{}) error {
 	tpl, err := parseStatusTemplate(format)
 	if err != nil {
@@ -0,0 +1,185 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+
+	"github.com/golang/dep/gps"
+)
+
+// htmlTracer is a gps.Tracer that builds the tree of decisions a solver makes,
+// to be written as a self-contained HTML page.
+//
+// Each project the solver visits is a node under the selection that led to
+// it, and each version of the project it attempts is a node under that. The
+// version that is selected then holds the visits that follow, until it is
+// unselected by backtracking. The page initially shows the path to the final
+// selections, with the branches abandoned along the way collapsed.
+type htmlTracer struct {
+	root *traceNode
+	// stack holds the nodes of the current selections, the root first.
+	stack []*traceNode
+	// visits holds the node of the latest visit to each project, so that
+	// attempts resume there after backtracking.
+	visits map[string]*traceNode
+	// visit and attempt are the nodes of the current visit and attempt.
+	visit, attempt *traceNode
+	// result is the outcome of the solve.
+	result *traceNode
+}
+
+// traceNode is a step in a solve, and the steps that followed from it.
+type traceNode struct {
+	Label string
+	// Class is one of "selected", "rejected", "unselected", "backtrack" or
+	// "info", for styling.
+	Class string
+	// Detail is the reason for a rejection or backtrack.
+	Detail   string
+	Children []*traceNode
+}
+
+func newHTMLTracer() *htmlTracer {
+	root := &traceNode{Label: "(root)", Class: "selected"}
+	return &htmlTracer{
+		root:   root,
+		stack:  []*traceNode{root},
+		visits: make(map[string]*traceNode),
+	}
+}
+
+func (n *traceNode) add(label, class string) *traceNode {
+	c := &traceNode{Label: label, Class: class}
+	n.Children = append(n.Children, c)
+	return c
+}
+
+func (t *htmlTracer) top() *traceNode {
+	return t.stack[len(t.stack)-1]
+}
+
+func (t *htmlTracer) Trace(e gps.TraceEvent) {
+	switch e.Type {
+	case gps.TraceSelectRoot:
+		t.root.Label = e.Project + " (root)"
+	case gps.TraceVisit:
+		switch {
+		case e.PkgOnly:
+			t.visit = t.top().add(fmt.Sprintf("add %s to %s", pkgList(e.Packages), e.Project), "info")
+			t.attempt = nil
+		case e.Continue && t.visits[e.Project] != nil:
+			t.visit = t.visits[e.Project]
+			t.visit.add(fmt.Sprintf("continue after backtracking; %d more versions to try", e.Versions), "info")
+		default:
+			t.visit = t.top().add(fmt.Sprintf("%s: %d versions to try, for %s", e.Project, e.Versions, pkgList(e.Packages)), "info")
+			t.visits[e.Project] = t.visit
+		}
+	case gps.TraceAttempt:
+		if t.visit == nil {
+			t.visit = t.top()
+		}
+		t.attempt = t.visit.add(e.Project+"@"+e.Version, "")
+	case gps.TraceReject:
+		n := t.attempt
+		if e.PkgOnly || n == nil {
+			n = t.visit
+		}
+		if n != nil {
+			n.Class, n.Detail = "rejected", failureDetail(e.Failure)
+		}
+	case gps.TraceSelect:
+		n := t.attempt
+		if e.PkgOnly || n == nil {
+			n = t.visit
+		}
+		if n == nil {
+			n = t.top().add(e.Project+"@"+e.Version, "")
+		}
+		n.Class = "selected"
+		t.stack = append(t.stack, n)
+		t.visit, t.attempt = nil, nil
+	case gps.TraceUnselect:
+		if len(t.stack) > 1 {
+			t.stack[len(t.stack)-1].Class = "unselected"
+			t.stack = t.stack[:len(t.stack)-1]
+		}
+		t.visit, t.attempt = nil, nil
+	case gps.TraceBacktrack:
+		n := t.visits[e.Project]
+		if e.PkgOnly || n == nil {
+			n = t.top()
+		}
+		b := n.add("no version can be selected; backtrack", "backtrack")
+		if e.PkgOnly {
+			b.Label = fmt.Sprintf("cannot add %s to %s; backtrack", pkgList(e.Packages), e.Project)
+		}
+		b.Detail = failureDetail(e.Failure)
+	case gps.TraceBackjump:
+		t.top().add(fmt.Sprintf("backjump over %d projects to %s, as they did not cause the failure", e.Skipped, e.Project), "backtrack")
+	case gps.TraceFinish:
+		if e.Failure != nil {
+			t.result = &traceNode{Label: "solving failed", Class: "rejected", Detail: e.Failure.Message}
+		} else {
+			t.result = &traceNode{Label: fmt.Sprintf("found a solution with %d projects, after %d attempts", e.Projects, e.Attempts), Class: "selected"}
+		}
+	}
+}
+
+func pkgList(pl []string) string {
+	if len(pl) == 1 {
+		return "1 package"
+	}
+	return fmt.Sprintf("%d packages", len(pl))
+}
+
+func failureDetail(r *gps.FailureReport) string {
+	if r == nil {
+		return ""
+	}
+	return strings.TrimSpace(r.Message)
+}
+
+// write writes the tree as an HTML page.
+func (t *htmlTracer) write(w io.Writer) error {
+	return traceHTMLTemplate.Execute(w, struct {
+		Root, Result *traceNode
+	}{t.root, t.result})
+}
+
+var traceHTMLTemplate = template.Must(template.New("trace").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>dep solve: {{.Root.Label}}</title>
+<style>
+body { font-family: sans-serif; font-size: 14px; }
+details, .leaf { margin-left: 1.5em; }
+summary { cursor: pointer; }
+.selected > .label, .selected > summary > .label { color: #070; font-weight: bold; }
+.rejected > .label, .rejected > summary > .label { color: #b00; }
+.unselected > .label, .unselected > summary > .label { color: #777; text-decoration: line-through; }
+.backtrack > .label, .backtrack > summary > .label { color: #a50; }
+.info > .label, .info > summary > .label { color: #333; }
+pre { margin: 0.2em 0 0.2em 1.5em; white-space: pre-wrap; color: #555; }
+</style>
+</head>
+<body>
+{{with .Result}}<p class="{{.Class}}"><span class="label">{{.Label}}</span></p>{{with .Detail}}<pre>{{.}}</pre>{{end}}{{end}}
+<p>
+<button onclick="for (const d of document.querySelectorAll('details')) d.open = true">Expand all</button>
+<button onclick="for (const d of document.querySelectorAll('details')) d.open = false">Collapse all</button>
+</p>
+{{template "node" .Root}}
+</body>
+</html>
+{{define "node"}}{{if or .Children .Detail}}<details class="{{.Class}}"{{if or (eq .Class "selected") (eq .Class "info")}} open{{end}}>
+<summary><span class="label">{{.Label}}</span></summary>
+{{with .Detail}}<pre>{{.}}</pre>{{end}}{{range .Children}}{{template "node" .}}{{end}}</details>
+{{else}}<div class="leaf {{.Class}}"><span class="label">{{.Label}}</span></div>
+{{end}}{{end}}`))
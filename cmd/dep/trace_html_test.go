@@ -0,0 +1,106 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep/gps"
+)
+
+func TestHTMLTracer(t *testing.T) {
+	pl := []string{"github.com/foo/bar"}
+	events := []gps.TraceEvent{
+		{Type: gps.TraceSelectRoot, Depth: 1, Project: "github.com/you/project"},
+		{Type: gps.TraceVisit, Depth: 1, Project: "github.com/foo/bar", Packages: pl, Versions: 2},
+		{Type: gps.TraceAttempt, Depth: 1, Project: "github.com/foo/bar", Version: "v1.5.0", Packages: pl},
+		{Type: gps.TraceReject, Depth: 1, Project: "github.com/foo/bar", Version: "v1.5.0", Packages: pl, Failure: &gps.FailureReport{
+			Kind:    gps.FailureVersionNotAllowed,
+			Message: "Could not introduce github.com/foo/bar@v1.5.0, as it is not allowed by constraint <1.4.0 from project root.",
+		}},
+		{Type: gps.TraceAttempt, Depth: 1, Project: "github.com/foo/bar", Version: "v1.3.0", Packages: pl},
+		{Type: gps.TraceSelect, Depth: 2, Project: "github.com/foo/bar", Version: "v1.3.0", Packages: pl},
+		{Type: gps.TraceVisit, Depth: 2, Project: "github.com/baz/qux", Packages: []string{"github.com/baz/qux"}, Versions: 1},
+		{Type: gps.TraceAttempt, Depth: 2, Project: "github.com/baz/qux", Version: "v0.1.0"},
+		{Type: gps.TraceSelect, Depth: 3, Project: "github.com/baz/qux", Version: "v0.1.0"},
+		{Type: gps.TraceFinish, Depth: 3, Projects: 2, Attempts: 0},
+	}
+
+	tr := newHTMLTracer()
+	for _, e := range events {
+		tr.Trace(e)
+	}
+
+	// The rejected version is under the visit to its project, and the next
+	// visit is under the version that was selected.
+	visit := tr.root.Children[0]
+	if len(visit.Children) != 2 {
+		t.Fatalf("expected 2 attempts under %q, got %d", visit.Label, len(visit.Children))
+	}
+	if rej := visit.Children[0]; rej.Class != "rejected" || !strings.Contains(rej.Detail, "constraint <1.4.0") {
+		t.Errorf("expected %s to be rejected with a reason, got %q, %q", rej.Label, rej.Class, rej.Detail)
+	}
+	sel := visit.Children[1]
+	if sel.Class != "selected" || len(sel.Children) != 1 || !strings.HasPrefix(sel.Children[0].Label, "github.com/baz/qux:") {
+		t.Errorf("expected %s to be selected, and to lead to the visit to github.com/baz/qux, got %#v", sel.Label, sel)
+	}
+
+	var buf bytes.Buffer
+	if err := tr.write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	page := buf.String()
+	for _, want := range []string{
+		"<title>dep solve: github.com/you/project (root)</title>",
+		"found a solution with 2 projects",
+		"github.com/foo/bar@v1.3.0",
+		"constraint &lt;1.4.0",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("expected the page to contain %q", want)
+		}
+	}
+}
+
+func TestHTMLTracerBacktrack(t *testing.T) {
+	events := []gps.TraceEvent{
+		{Type: gps.TraceSelectRoot, Depth: 1, Project: "root"},
+		{Type: gps.TraceVisit, Depth: 1, Project: "a", Versions: 2},
+		{Type: gps.TraceAttempt, Depth: 1, Project: "a", Version: "2.0.0"},
+		{Type: gps.TraceSelect, Depth: 2, Project: "a", Version: "2.0.0"},
+		{Type: gps.TraceVisit, Depth: 2, Project: "b", Versions: 1},
+		{Type: gps.TraceAttempt, Depth: 2, Project: "b", Version: "1.0.0"},
+		{Type: gps.TraceReject, Depth: 2, Project: "b", Version: "1.0.0", Failure: &gps.FailureReport{Message: "conflicts with a@2.0.0"}},
+		{Type: gps.TraceBacktrack, Depth: 2, Project: "b", Failure: &gps.FailureReport{Message: "No versions of b met constraints"}},
+		{Type: gps.TraceUnselect, Depth: 1, Project: "a", Version: "2.0.0"},
+		{Type: gps.TraceVisit, Depth: 1, Project: "a", Versions: 1, Continue: true},
+		{Type: gps.TraceAttempt, Depth: 1, Project: "a", Version: "1.0.0"},
+		{Type: gps.TraceSelect, Depth: 2, Project: "a", Version: "1.0.0"},
+		{Type: gps.TraceFinish, Depth: 2, Projects: 1, Attempts: 1},
+	}
+
+	tr := newHTMLTracer()
+	for _, e := range events {
+		tr.Trace(e)
+	}
+
+	// Both versions of a are attempts under the same visit, the first undone.
+	visit := tr.root.Children[0]
+	var classes []string
+	for _, c := range visit.Children {
+		classes = append(classes, c.Label+" "+c.Class)
+	}
+	want := []string{"a@2.0.0 unselected", "continue after backtracking; 1 more versions to try info", "a@1.0.0 selected"}
+	if strings.Join(classes, ", ") != strings.Join(want, ", ") {
+		t.Errorf("unexpected attempts:\n\t(GOT): %v\n\t(WNT): %v", classes, want)
+	}
+
+	bvisit := visit.Children[0].Children[0]
+	if n := len(bvisit.Children); n != 2 || bvisit.Children[1].Class != "backtrack" {
+		t.Errorf("expected the visit to b to end with a backtrack, got %#v", bvisit.Children)
+	}
+}
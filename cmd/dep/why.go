@@ -0,0 +1,429 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+	"github.com/golang/dep/gps/paths"
+	"github.com/pkg/errors"
+)
+
+const whyShortHelp = `Explain why a project or package is in Gopkg.lock`
+const whyLongHelp = `
+Why prints every import chain that leads from the current project's packages to
+the named project or package. If the argument is the root of a project in
+Gopkg.lock, a chain ends at the first package from that project; otherwise, it
+must be the import path of a single package in one of the locked projects.
+
+Each chain begins with a package in the current project and lists, one per
+line, each package imported on the way to the target. Packages from test files
+of the current project are included; test files of dependencies are not.
+
+After the chains, a table shows the version in Gopkg.lock of each dependency
+that appears in them, along with every constraint declared on it: by the current
+project's Gopkg.toml (including overrides) and by the manifests of the other
+locked projects.
+`
+
+const whyExamples = `
+dep why github.com/pkg/errors
+
+	Show every chain of imports through which the current project depends on
+	any package in github.com/pkg/errors.
+
+dep why golang.org/x/net/context
+
+	Show only the chains that end at the golang.org/x/net/context package.
+`
+
+type whyCommand struct {
+	examples bool
+}
+
+func (cmd *whyCommand) Name() string      { return "why" }
+func (cmd *whyCommand) Args() string      { return "<import-path>" }
+func (cmd *whyCommand) ShortHelp() string { return whyShortHelp }
+func (cmd *whyCommand) LongHelp() string  { return whyLongHelp }
+func (cmd *whyCommand) Hidden() bool      { return false }
+
+func (cmd *whyCommand) Register(fs *flag.FlagSet) {
+	fs.BoolVar(&cmd.examples, "examples", false, "print detailed usage examples")
+}
+
+func (cmd *whyCommand) Run(ctx *dep.Ctx, args []string) error {
+	if cmd.examples {
+		ctx.Err.Println(strings.TrimSpace(whyExamples))
+		return nil
+	}
+
+	if len(args) != 1 {
+		return errors.Errorf("dep why takes exactly one import path, got %d", len(args))
+	}
+	target := strings.TrimSuffix(args[0], "/")
+
+	p, err := ctx.LoadProject()
+	if err != nil {
+		return err
+	}
+
+	if p.Lock == nil {
+		return errors.Errorf("no Gopkg.lock found. Run `dep ensure` to generate lock file")
+	}
+
+	if paths.IsStandardImportPath(target) {
+		return errors.Errorf("%s is in the standard library", target)
+	}
+	if target == string(p.ImportRoot) || strings.HasPrefix(target, string(p.ImportRoot)+"/") {
+		return errors.Errorf("%s is part of the current project", target)
+	}
+
+	sm, err := ctx.SourceManager()
+	if err != nil {
+		return err
+	}
+	sm.UseDefaultSignalHandling()
+	defer sm.Release()
+
+	g, cm, err := buildWhyGraph(ctx, p, sm)
+	if err != nil {
+		return err
+	}
+
+	if _, has := g.projectOf(target); !has {
+		return errors.Errorf("%s is not in %s", target, dep.LockName)
+	}
+
+	chains := g.chains(target)
+	if len(chains) == 0 {
+		ctx.Out.Printf("%s is in %s, but no package in %s imports it\n", target, dep.LockName, p.ImportRoot)
+		return nil
+	}
+
+	var buf bytes.Buffer
+	for i, chain := range chains {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		for depth, pkg := range chain {
+			fmt.Fprintf(&buf, "%s%s\n", strings.Repeat("  ", depth), pkg)
+		}
+	}
+	buf.WriteString("\n")
+
+	tw := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROJECT\tVERSION\tCONSTRAINT\tFROM")
+	for _, pr := range g.chainProjects(chains) {
+		lp := g.locked[pr]
+		version := formatVersion(lp.Version())
+		if pv, ok := lp.Version().(gps.PairedVersion); ok {
+			version = fmt.Sprintf("%s (%s)", formatVersion(pv.Unpair()), formatVersion(pv.Revision()))
+		}
+
+		pcs := whyConstraints(p.Manifest, pr, cm[string(pr)])
+		if len(pcs) == 0 {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t\n", pr, version, gps.Any())
+			continue
+		}
+		for i, pc := range pcs {
+			if i > 0 {
+				pr, version = "", ""
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", pr, version, pc.Constraint, pc.Project)
+		}
+	}
+	tw.Flush()
+
+	ctx.Out.Print(buf.String())
+	return nil
+}
+
+// whyGraph is a package-level import graph spanning the current project and
+// every project in its lock.
+type whyGraph struct {
+	// starts holds the current project's packages, sorted.
+	starts []string
+	// imports maps each known package to its direct, non-standard imports.
+	// Imports from the current project only include packages outside of it.
+	imports map[string][]string
+	locked  map[gps.ProjectRoot]gps.LockedProject
+}
+
+// buildWhyGraph assembles the whyGraph for p, listing the packages of each
+// locked project through sm. It also collects every constraint the locked
+// projects' manifests declare, keyed by the constrained project root.
+func buildWhyGraph(ctx *dep.Ctx, p *dep.Project, sm gps.SourceManager) (*whyGraph, constraintsCollection, error) {
+	logger := ctx.Err
+	if !ctx.Verbose {
+		logger = log.New(ioutil.Discard, "", 0)
+	}
+
+	g := &whyGraph{
+		imports: make(map[string][]string),
+		locked:  make(map[gps.ProjectRoot]gps.LockedProject),
+	}
+
+	ignore := p.Manifest.IgnoredPackages()
+	ptree := p.RootPackageTree
+	rm, _ := ptree.ToReachMap(true, true, false, ignore)
+	for pkg := range rm {
+		poe, has := ptree.Packages[pkg]
+		if !has || poe.Err != nil {
+			continue
+		}
+
+		var imps []string
+		for _, imp := range dedupe(poe.P.Imports, poe.P.TestImports) {
+			if ignore.IsIgnored(imp) || paths.IsStandardImportPath(imp) {
+				continue
+			}
+			if imp == ptree.ImportRoot || strings.HasPrefix(imp, ptree.ImportRoot+"/") {
+				continue
+			}
+			imps = append(imps, imp)
+		}
+		g.starts = append(g.starts, pkg)
+		g.imports[pkg] = imps
+	}
+	sort.Strings(g.starts)
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs []error
+		cm   = make(constraintsCollection)
+	)
+
+	lps := p.Lock.Projects()
+	logger.Println("Listing packages of locked projects:")
+	for i, lp := range lps {
+		g.locked[lp.Ident().ProjectRoot] = lp
+		logger.Printf("(%d/%d) %s\n", i+1, len(lps), lp.Ident().ProjectRoot)
+
+		wg.Add(1)
+		go func(lp gps.LockedProject) {
+			defer wg.Done()
+
+			id, v := lp.Ident(), lp.Version()
+			ptree, err := sm.ListPackages(id, v)
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, errors.Wrapf(err, "failed to list packages for %s", id))
+				mu.Unlock()
+				return
+			}
+			m, _, err := sm.GetManifestAndLock(id, v, dep.Analyzer{})
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, errors.Wrapf(err, "failed to read manifest for %s", id))
+				mu.Unlock()
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			for _, pkg := range lp.Packages() {
+				ip := string(id.ProjectRoot)
+				if pkg != "." {
+					ip = ip + "/" + pkg
+				}
+
+				poe, has := ptree.Packages[ip]
+				if !has || poe.Err != nil {
+					continue
+				}
+
+				var imps []string
+				for _, imp := range poe.P.Imports {
+					if !paths.IsStandardImportPath(imp) {
+						imps = append(imps, imp)
+					}
+				}
+				g.imports[ip] = imps
+			}
+
+			for pr, pp := range m.DependencyConstraints() {
+				if pp.Constraint == nil {
+					continue
+				}
+				cm[string(pr)] = append(cm[string(pr)], projectConstraint{id.ProjectRoot, pp.Constraint})
+			}
+		}(lp)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		if ctx.Verbose {
+			for _, err := range errs {
+				ctx.Err.Println(err)
+			}
+		}
+		return nil, nil, errors.Errorf("failed to analyze %d of the locked projects", len(errs))
+	}
+
+	for pr := range cm {
+		sort.Sort(byProject(cm[pr]))
+	}
+
+	return g, cm, nil
+}
+
+// projectOf returns the root of the locked project containing the package at
+// path ip, if there is one.
+func (g *whyGraph) projectOf(ip string) (gps.ProjectRoot, bool) {
+	for pr := gps.ProjectRoot(ip); ; {
+		if _, has := g.locked[pr]; has {
+			return pr, true
+		}
+		i := strings.LastIndex(string(pr), "/")
+		if i == -1 {
+			return "", false
+		}
+		pr = pr[:i]
+	}
+}
+
+// chains returns every import chain from one of the current project's packages
+// to target. If target is the root of a locked project, a chain ends at the
+// first package from that project it reaches; otherwise, target is treated as a
+// single package. Chains never visit a package twice.
+func (g *whyGraph) chains(target string) [][]string {
+	_, isRoot := g.locked[gps.ProjectRoot(target)]
+	matches := func(pkg string) bool {
+		if pkg == target {
+			return true
+		}
+		if isRoot {
+			pr, has := g.projectOf(pkg)
+			return has && string(pr) == target
+		}
+		return false
+	}
+
+	// Walk the graph backwards first to find which packages can reach the
+	// target at all. The search below then never wanders into dead ends, so its
+	// cost stays proportional to the size of its output.
+	importers := make(map[string][]string)
+	for pkg, imps := range g.imports {
+		for _, imp := range imps {
+			importers[imp] = append(importers[imp], pkg)
+		}
+	}
+	reaches := make(map[string]bool)
+	var queue []string
+	for pkg := range importers {
+		if matches(pkg) {
+			reaches[pkg] = true
+			queue = append(queue, pkg)
+		}
+	}
+	for len(queue) > 0 {
+		pkg := queue[0]
+		queue = queue[1:]
+		for _, imper := range importers[pkg] {
+			if !reaches[imper] {
+				reaches[imper] = true
+				queue = append(queue, imper)
+			}
+		}
+	}
+
+	var (
+		chains [][]string
+		path   []string
+		onPath = make(map[string]bool)
+		walk   func(pkg string)
+	)
+	walk = func(pkg string) {
+		path = append(path, pkg)
+		onPath[pkg] = true
+		defer func() {
+			path = path[:len(path)-1]
+			onPath[pkg] = false
+		}()
+
+		if len(path) > 1 && matches(pkg) {
+			chains = append(chains, append([]string(nil), path...))
+			return
+		}
+
+		imps := append([]string(nil), g.imports[pkg]...)
+		sort.Strings(imps)
+		for _, imp := range imps {
+			if reaches[imp] && !onPath[imp] {
+				walk(imp)
+			}
+		}
+	}
+
+	for _, start := range g.starts {
+		if reaches[start] {
+			walk(start)
+		}
+	}
+
+	return chains
+}
+
+// chainProjects returns the sorted roots of the locked projects that appear in
+// chains.
+func (g *whyGraph) chainProjects(chains [][]string) []gps.ProjectRoot {
+	seen := make(map[gps.ProjectRoot]bool)
+	var prs []gps.ProjectRoot
+	for _, chain := range chains {
+		for _, pkg := range chain {
+			if pr, has := g.projectOf(pkg); has && !seen[pr] {
+				seen[pr] = true
+				prs = append(prs, pr)
+			}
+		}
+	}
+
+	sort.Slice(prs, func(i, j int) bool { return prs[i] < prs[j] })
+	return prs
+}
+
+// whyConstraints returns all the constraints declared on pr: first any override
+// or constraint from the root manifest, then those from the dependencies'
+// manifests in deps. When an override is present, it is the only constraint
+// the solver considered, so the others are omitted.
+func whyConstraints(m *dep.Manifest, pr gps.ProjectRoot, deps []projectConstraint) []projectConstraint {
+	if pp, has := m.Ovr[pr]; has && pp.Constraint != nil {
+		return []projectConstraint{{"root (override)", pp.Constraint}}
+	}
+
+	var pcs []projectConstraint
+	if pp, has := m.Constraints[pr]; has && pp.Constraint != nil {
+		pcs = append(pcs, projectConstraint{"root", pp.Constraint})
+	}
+	return append(pcs, deps...)
+}
+
+// dedupe returns the sorted union of the given string slices.
+func dedupe(lists ...[]string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, l := range lists {
+		for _, s := range l {
+			if !seen[s] {
+				seen[s] = true
+				out = append(out, s)
+			}
+		}
+	}
+
+	sort.Strings(out)
+	return out
+}
@@ -0,0 +1,116 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/dep"
+	"github.com/golang/dep/gps"
+)
+
+func newTestWhyGraph() *whyGraph {
+	locked := func(root string) gps.LockedProject {
+		return gps.NewLockedProject(gps.ProjectIdentifier{ProjectRoot: gps.ProjectRoot(root)}, gps.NewVersion("v1.0.0"), nil)
+	}
+
+	return &whyGraph{
+		starts: []string{"root", "root/cmd"},
+		imports: map[string][]string{
+			"root":     {"a.com/a", "b.com/b"},
+			"root/cmd": {"b.com/b/sub"},
+			"a.com/a":  {"a.com/a/internal"},
+			// A cycle between projects must not be followed forever.
+			"a.com/a/internal": {"c.com/c", "b.com/b"},
+			"b.com/b":          {"a.com/a"},
+			"b.com/b/sub":      {"c.com/c"},
+			"c.com/c":          {},
+			"d.com/d":          {"c.com/c"},
+		},
+		locked: map[gps.ProjectRoot]gps.LockedProject{
+			"a.com/a": locked("a.com/a"),
+			"b.com/b": locked("b.com/b"),
+			"c.com/c": locked("c.com/c"),
+			"d.com/d": locked("d.com/d"),
+		},
+	}
+}
+
+func TestWhyGraphChains(t *testing.T) {
+	g := newTestWhyGraph()
+
+	tests := map[string][][]string{
+		"c.com/c": {
+			{"root", "a.com/a", "a.com/a/internal", "c.com/c"},
+			{"root", "b.com/b", "a.com/a", "a.com/a/internal", "c.com/c"},
+			{"root/cmd", "b.com/b/sub", "c.com/c"},
+		},
+		// A project root stops at the first package from that project.
+		"b.com/b": {
+			{"root", "a.com/a", "a.com/a/internal", "b.com/b"},
+			{"root", "b.com/b"},
+			{"root/cmd", "b.com/b/sub"},
+		},
+		// A package path that isn't a project root only matches itself.
+		"b.com/b/sub": {
+			{"root/cmd", "b.com/b/sub"},
+		},
+		"d.com/d": nil,
+	}
+
+	for target, want := range tests {
+		t.Run(target, func(t *testing.T) {
+			got := g.chains(target)
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("unexpected chains:\n\t(GOT): %v\n\t(WNT): %v", got, want)
+			}
+		})
+	}
+}
+
+func TestWhyGraphProjectOf(t *testing.T) {
+	g := newTestWhyGraph()
+
+	tests := map[string]gps.ProjectRoot{
+		"a.com/a":          "a.com/a",
+		"a.com/a/internal": "a.com/a",
+		"a.com/ab":         "",
+		"e.com/e":          "",
+	}
+
+	for ip, want := range tests {
+		got, has := g.projectOf(ip)
+		if got != want || has != (want != "") {
+			t.Errorf("projectOf(%q): expected (%q, %t), got (%q, %t)", ip, want, want != "", got, has)
+		}
+	}
+}
+
+func TestWhyConstraints(t *testing.T) {
+	caret, _ := gps.NewSemverConstraint("^1.0.0")
+	tilde, _ := gps.NewSemverConstraint("~1.2.0")
+	deps := []projectConstraint{{"d.com/d", caret}}
+
+	m := dep.NewManifest()
+	m.Constraints["a.com/a"] = gps.ProjectProperties{Constraint: tilde}
+	m.Ovr["b.com/b"] = gps.ProjectProperties{Constraint: gps.NewBranch("master")}
+
+	tests := []struct {
+		pr   gps.ProjectRoot
+		want []projectConstraint
+	}{
+		{"a.com/a", []projectConstraint{{"root", tilde}, {"d.com/d", caret}}},
+		{"b.com/b", []projectConstraint{{"root (override)", gps.NewBranch("master")}}},
+		{"c.com/c", deps},
+	}
+
+	for _, tc := range tests {
+		got := whyConstraints(m, tc.pr, deps)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("whyConstraints(%q):\n\t(GOT): %v\n\t(WNT): %v", tc.pr, got, tc.want)
+		}
+	}
+}
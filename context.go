@@ -39,15 +39,27 @@ import (
 //	}
 //
 type Ctx struct {
-	WorkingDir     string        // Where to execute.
-	GOPATH         string        // Selected Go path, containing WorkingDir.
-	GOPATHs        []string      // Other Go paths.
-	ExplicitRoot   string        // An explicitly-set path to use as the project root.
-	Out, Err       *log.Logger   // Required loggers.
-	Verbose        bool          // Enables more verbose logging.
-	DisableLocking bool          // When set, no lock file will be created to protect against simultaneous dep processes.
-	Cachedir       string        // Cache directory loaded from environment.
-	CacheAge       time.Duration // Maximum valid age of cached source data. <=0: Don't cache.
+	WorkingDir        string              // Where to execute.
+	GOPATH            string              // Selected Go path, containing WorkingDir.
+	GOPATHs           []string            // Other Go paths.
+	ExplicitRoot      string              // An explicitly-set path to use as the project root.
+	Out, Err          *log.Logger         // Required loggers.
+	Verbose           bool                // Enables more verbose logging.
+	DisableLocking    bool                // When set, no lock file will be created to protect against simultaneous dep processes.
+	Cachedir          string              // Cache directory loaded from environment.
+	CacheAge          time.Duration       // Maximum valid age of cached source data. <=0: Don't cache.
+	Offline           bool                // When set, sources are never contacted upstream; only the local cache is used.
+	URLRewrites       []gps.URLRewrite    // Rules for rewriting the URLs that sources are fetched from.
+	Deducers          []gps.CustomDeducer // Additional rules for deducing the sources of import paths.
+	DeductionCacheAge time.Duration       // Maximum valid age of cached go get metadata. <=0: Don't cache.
+	RefreshDeductions bool                // When set, cached go get metadata is retrieved again.
+	HTTP              gps.HTTPConfig      // Configures the client that retrieves go get metadata and fetches from proxies.
+	GoProxy           []string            // Go module proxies to fetch projects from, in order; "direct" for their deduced sources.
+	LinkLocal         bool                // When set, projects overridden by local paths are vendored as symlinks.
+	PartialClone      bool                // When set, git sources are cloned without the contents of files, fetched as they are needed.
+	Prefetch          int                 // Maximum concurrent requests to prefetch data while solving. 0: Default. <0: Don't prefetch.
+	SolveMaxAttempts  int                 // Maximum number of times the solver may backtrack. <=0: No limit.
+	SolveTimeout      time.Duration       // Maximum time the solver may run for. <=0: No limit.
 }
 
 // SetPaths sets the WorkingDir and GOPATHs fields. If GOPATHs is empty, then
@@ -108,10 +120,19 @@ func (c *Ctx) SourceManager() (*gps.SourceMgr, error) {
 	}
 
 	return gps.NewSourceManager(gps.SourceManagerConfig{
-		CacheAge:       c.CacheAge,
-		Cachedir:       cachedir,
-		Logger:         c.Out,
-		DisableLocking: c.DisableLocking,
+		CacheAge:          c.CacheAge,
+		Cachedir:          cachedir,
+		Logger:            c.Out,
+		DisableLocking:    c.DisableLocking,
+		Offline:           c.Offline,
+		URLRewrites:       c.URLRewrites,
+		Deducers:          c.Deducers,
+		DeductionCacheAge: c.DeductionCacheAge,
+		RefreshDeductions: c.RefreshDeductions,
+		HTTP:              c.HTTP,
+		GoProxy:           c.GoProxy,
+		LinkLocal:         c.LinkLocal,
+		PartialClone:      c.PartialClone,
 	})
 }
 
@@ -174,6 +195,7 @@ func (c *Ctx) LoadProject() (*Project, error) {
 	if err != nil {
 		return nil, errors.Wrapf(err, "error while parsing %s", mp)
 	}
+	p.Manifest.resolveLocalPaths(p.AbsRoot)
 
 	// Parse in the root package tree.
 	ptree, err := p.parseRootPackageTree()
@@ -190,6 +212,7 @@ func (c *Ctx) LoadProject() (*Project, error) {
 		if err != nil {
 			return nil, errors.Wrapf(err, "error while parsing %s", lp)
 		}
+		p.Lock.resolveLocalPaths(p.AbsRoot)
 
 		// If there's a current Lock, apply the input and pruneopt changes that we
 		// can know without solving.
@@ -0,0 +1,66 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"io"
+	"os"
+
+	"github.com/golang/dep/gps"
+	"github.com/pelletier/go-toml"
+	"github.com/pkg/errors"
+)
+
+// rawDeducers is the TOML form of a deducers file:
+//
+//	[[deducer]]
+//	  prefix = "git.corp.example.com/"
+//	  root = '^(?P<root>git\.corp\.example\.com/[^/]+/[^/]+)(/.*)?$'
+//	  vcs = "git"
+//	  url = "https://{root}.git"
+type rawDeducers struct {
+	Deducers []rawDeducer `toml:"deducer"`
+}
+
+type rawDeducer struct {
+	Prefix string `toml:"prefix"`
+	Root   string `toml:"root"`
+	VCS    string `toml:"vcs"`
+	URL    string `toml:"url"`
+}
+
+// LoadDeducers reads the custom path deducers declared in the TOML file at
+// path. The deducers are validated when a SourceManager is created with them.
+func LoadDeducers(path string) ([]gps.CustomDeducer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open deducers file")
+	}
+	defer f.Close()
+
+	cds, err := readDeducers(f)
+	return cds, errors.Wrapf(err, "unable to load deducers from %s", path)
+}
+
+func readDeducers(r io.Reader) ([]gps.CustomDeducer, error) {
+	var raw rawDeducers
+	if err := toml.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, errors.Wrap(err, "unable to parse the deducers as TOML")
+	}
+
+	cds := make([]gps.CustomDeducer, len(raw.Deducers))
+	for i, rd := range raw.Deducers {
+		if rd.Prefix == "" {
+			return nil, errors.Errorf("deducer %d has no %q", i+1, "prefix")
+		}
+		cds[i] = gps.CustomDeducer{
+			Prefix: rd.Prefix,
+			Root:   rd.Root,
+			VCS:    rd.VCS,
+			URL:    rd.URL,
+		}
+	}
+	return cds, nil
+}
@@ -0,0 +1,57 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep/gps"
+)
+
+func TestReadDeducers(t *testing.T) {
+	cds, err := readDeducers(strings.NewReader(`
+[[deducer]]
+  prefix = "git.corp.example.com/"
+  root = '^(?P<root>git\.corp\.example\.com/[^/]+/[^/]+)(/.*)?$'
+  vcs = "git"
+  url = "https://{root}.git"
+
+[[deducer]]
+  prefix = "hg.corp.example.com/"
+  root = '^(?P<root>hg\.corp\.example\.com/[^/]+)(/.*)?$'
+  vcs = "hg"
+  url = "ssh://hg@{root}"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []gps.CustomDeducer{
+		{
+			Prefix: "git.corp.example.com/",
+			Root:   `^(?P<root>git\.corp\.example\.com/[^/]+/[^/]+)(/.*)?$`,
+			VCS:    "git",
+			URL:    "https://{root}.git",
+		},
+		{
+			Prefix: "hg.corp.example.com/",
+			Root:   `^(?P<root>hg\.corp\.example\.com/[^/]+)(/.*)?$`,
+			VCS:    "hg",
+			URL:    "ssh://hg@{root}",
+		},
+	}
+	if !reflect.DeepEqual(cds, want) {
+		t.Errorf("unexpected deducers:\n\t(GOT): %#v\n\t(WNT): %#v", cds, want)
+	}
+
+	if _, err := readDeducers(strings.NewReader("[[deducer]]\n  vcs = \"git\"\n")); err == nil {
+		t.Error("expected an error for a deducer without a prefix")
+	}
+	if _, err := readDeducers(strings.NewReader("[[deducer]\n")); err == nil {
+		t.Error("expected an error for invalid TOML")
+	}
+}
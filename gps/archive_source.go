@@ -0,0 +1,668 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/golang/dep/gps/pkgtree"
+	"github.com/golang/dep/internal/fs"
+	"github.com/pkg/errors"
+)
+
+// archiveVersionVar is replaced by a version in the URL template of an
+// archive source.
+const archiveVersionVar = "{version}"
+
+// archiveRevisionPrefix begins the revisions of archive sources, which are
+// the SHA-256 digests of their archives.
+const archiveRevisionPrefix = "sha256:"
+
+var archiveRevisionRegex = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+
+// The formats of archives, chosen by the extension of the URL template.
+const (
+	archiveZip    = "zip"
+	archiveTar    = "tar"
+	archiveTarGz  = "tar.gz"
+	archiveTarBz2 = "tar.bz2"
+)
+
+var archiveExtensions = []struct {
+	ext, format string
+}{
+	{".zip", archiveZip},
+	{".tar", archiveTar},
+	{".tar.gz", archiveTarGz},
+	{".tgz", archiveTarGz},
+	{".tar.bz2", archiveTarBz2},
+	{".tbz2", archiveTarBz2},
+}
+
+// deduceArchiveSource deduces the archive source for a source that is the
+// URL template of the archives of each version, such as
+// https://artifacts.corp/foo/foo-{version}.tar.gz.
+//
+// The fragment of the URL says where to find the versions: either
+// "versions=" and a comma-separated list of them, or "index=" and the URL of
+// an index file, relative to the template. Each line of an index file holds a
+// version, optionally followed by the digest of its archive.
+func (dc *deductionCoordinator) deduceArchiveSource(p string) (pathDeduction, error) {
+	u, err := url.Parse(p)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return pathDeduction{}, errors.Errorf("archive source %q is not an http or https URL", p)
+	}
+
+	m := maybeArchiveSource{client: dc.client}
+	for _, e := range archiveExtensions {
+		if strings.HasSuffix(u.Path, e.ext) {
+			m.format = e.format
+			break
+		}
+	}
+	if m.format == "" {
+		return pathDeduction{}, errors.Errorf("archive source %q does not end in a known archive extension", p)
+	}
+
+	cfg := u.Fragment
+	u.Fragment = ""
+	switch {
+	case strings.HasPrefix(cfg, "versions="):
+		for _, v := range strings.Split(strings.TrimPrefix(cfg, "versions="), ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				m.versions = append(m.versions, v)
+			}
+		}
+		if len(m.versions) == 0 {
+			return pathDeduction{}, errors.Errorf("archive source %q lists no versions", p)
+		}
+	case strings.HasPrefix(cfg, "index="):
+		if m.index, err = u.Parse(strings.TrimPrefix(cfg, "index=")); err != nil {
+			return pathDeduction{}, errors.Wrapf(err, "archive source %q has an invalid index URL", p)
+		}
+	default:
+		return pathDeduction{}, errors.Errorf("archive source %q must end in #versions=<versions> or #index=<URL>", p)
+	}
+	m.url = u
+
+	return pathDeduction{root: p, mb: maybeSources{m}}, nil
+}
+
+type maybeArchiveSource struct {
+	// The URL template of the archives, without its fragment.
+	url    *url.URL
+	format string
+	// Either the versions, or the URL of the index listing them.
+	versions []string
+	index    *url.URL
+	client   *http.Client
+}
+
+func (m maybeArchiveSource) try(ctx context.Context, cachedir string) (source, error) {
+	return &archiveSource{
+		maybeArchiveSource: m,
+		path:               sourceCachePath(cachedir, m.url.String()),
+		digests:            make(map[string]Revision),
+	}, nil
+}
+
+func (m maybeArchiveSource) URL() *url.URL {
+	return m.url
+}
+
+func (m maybeArchiveSource) String() string {
+	if m.index != nil {
+		return fmt.Sprintf("%T: %s (index %s)", m, m.url, m.index)
+	}
+	return fmt.Sprintf("%T: %s (versions %s)", m, m.url, strings.Join(m.versions, ","))
+}
+
+// archiveSource is a source whose versions are archives downloaded over
+// HTTP, such as the release tarballs of an SDK.
+//
+// The revision of each version is the SHA-256 digest of its archive, as in
+// "sha256:<hex>", so that a lock records exactly which archive was used. Each
+// archive is extracted in a directory of its own under the local path, named
+// by its digest; a file named by the version and ending in .digest records
+// which directory holds it. If all the files of an archive are in a single
+// directory, that directory is removed from their paths.
+type archiveSource struct {
+	maybeArchiveSource
+	path string
+	// The digests of archives learned from the index or by downloading them,
+	// keyed by version.
+	digests map[string]Revision
+}
+
+func (*archiveSource) sourceType() string {
+	return "archive"
+}
+
+func (s *archiveSource) existsLocally(ctx context.Context) bool {
+	_, err := os.Stat(s.path)
+	return err == nil
+}
+
+// existsUpstream reports whether the index, or else the archive of the first
+// configured version, answers a HEAD request, without downloading either.
+func (s *archiveSource) existsUpstream(ctx context.Context) bool {
+	var u string
+	if s.index != nil {
+		u = s.index.String()
+	} else {
+		u = s.archiveURL(s.versions[0])
+	}
+	req, err := http.NewRequest("HEAD", u, nil)
+	if err != nil {
+		return false
+	}
+	req = addAuthFromNetrc(u, req)
+
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return false
+	}
+	resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+func (*archiveSource) existsCallsListVersions() bool {
+	return false
+}
+
+func (*archiveSource) listVersionsRequiresLocal() bool {
+	return false
+}
+
+func (s *archiveSource) upstreamURL() string {
+	return s.url.String()
+}
+
+func (s *archiveSource) localPath() string {
+	return s.path
+}
+
+func (s *archiveSource) initLocal(ctx context.Context) error {
+	return os.MkdirAll(s.path, 0777)
+}
+
+// updateLocal does nothing, as archives are downloaded when they are needed.
+func (s *archiveSource) updateLocal(ctx context.Context) error {
+	return s.initLocal(ctx)
+}
+
+func (*archiveSource) maybeClean(ctx context.Context) error {
+	return nil
+}
+
+// listVersions lists the configured versions, or those in the index, paired
+// with the digests of their archives. Nothing is downloaded: a version whose
+// digest is neither in the index nor recorded locally is paired with a
+// provisional revision, and its archive is only downloaded, to compute the
+// digest, by resolveRevision once the version is used.
+func (s *archiveSource) listVersions(ctx context.Context) ([]PairedVersion, error) {
+	versions, err := s.knownDigests(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	vlist := make([]PairedVersion, 0, len(versions))
+	for _, v := range versions {
+		r, has := s.digests[v]
+		if !has {
+			r = Revision(provisionalRevisionPrefix + v)
+		}
+		vlist = append(vlist, NewVersion(v).Pair(r))
+	}
+	return vlist, nil
+}
+
+// resolveRevision returns the digest of the archive of the version that the
+// provisional revision r names, downloading the archive if needed.
+func (s *archiveSource) resolveRevision(ctx context.Context, r Revision) (Revision, error) {
+	v := strings.TrimPrefix(string(r), provisionalRevisionPrefix)
+	if d, has := s.digests[v]; has {
+		return d, nil
+	}
+	d, err := s.localDigest(v)
+	if err != nil || d != "" {
+		return d, err
+	}
+	return s.download(ctx, v, "")
+}
+
+// knownDigests returns the configured versions, or those in the index, after
+// adding the digests that the index gives, or that are recorded locally, to
+// those already known.
+func (s *archiveSource) knownDigests(ctx context.Context) ([]string, error) {
+	versions := s.versions
+	if s.index != nil {
+		var digests map[string]Revision
+		var err error
+		if versions, digests, err = s.readIndex(ctx); err != nil {
+			return nil, err
+		}
+		for v, r := range digests {
+			s.digests[v] = r
+		}
+	}
+
+	if err := os.MkdirAll(s.path, 0777); err != nil {
+		return nil, err
+	}
+	for _, v := range versions {
+		if _, has := s.digests[v]; has {
+			continue
+		}
+		r, err := s.localDigest(v)
+		if err != nil {
+			return nil, err
+		}
+		if r != "" {
+			s.digests[v] = r
+		}
+	}
+	return versions, nil
+}
+
+// listLocalVersions lists the versions whose archives have been downloaded.
+func (s *archiveSource) listLocalVersions(ctx context.Context) ([]PairedVersion, error) {
+	fis, err := ioutil.ReadDir(s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var vlist []PairedVersion
+	for _, fi := range fis {
+		name := fi.Name()
+		if fi.IsDir() || !strings.HasSuffix(name, ".digest") {
+			continue
+		}
+		v, err := url.PathUnescape(strings.TrimSuffix(name, ".digest"))
+		if err != nil {
+			continue
+		}
+		r, err := s.localDigest(v)
+		if err != nil {
+			return nil, err
+		}
+		if r != "" {
+			vlist = append(vlist, NewVersion(v).Pair(r))
+		}
+	}
+	return vlist, nil
+}
+
+// revisionPresentIn reports whether r is the digest of the archive of one of
+// the versions, downloading that archive if it has not been already.
+func (s *archiveSource) revisionPresentIn(r Revision) (bool, error) {
+	_, err := s.revisionDir(context.TODO(), r)
+	if _, ok := err.(*archiveRevisionNotFoundError); ok {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// disambiguateRevision accepts only the full digests of the archives, as
+// there is nothing else a revision could name.
+func (s *archiveSource) disambiguateRevision(ctx context.Context, r Revision) (Revision, error) {
+	if _, err := s.revisionDir(ctx, r); err != nil {
+		return "", err
+	}
+	return r, nil
+}
+
+func (s *archiveSource) getManifestAndLock(ctx context.Context, pr ProjectRoot, r Revision, an ProjectAnalyzer) (Manifest, Lock, error) {
+	dir, err := s.revisionDir(ctx, r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m, l, err := an.DeriveManifestAndLock(dir, pr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if l != nil && l != Lock(nil) {
+		l = prepLock(l)
+	}
+
+	return prepManifest(m), l, nil
+}
+
+func (s *archiveSource) listPackages(ctx context.Context, pr ProjectRoot, r Revision) (pkgtree.PackageTree, error) {
+	dir, err := s.revisionDir(ctx, r)
+	if err != nil {
+		return pkgtree.PackageTree{}, err
+	}
+	return pkgtree.ListPackages(dir, string(pr))
+}
+
+func (s *archiveSource) exportRevisionTo(ctx context.Context, r Revision, to string) error {
+	dir, err := s.revisionDir(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	// Only make the parent dir, as CopyDir will balk on trying to write to an
+	// empty but existing dir.
+	if err := os.MkdirAll(filepath.Dir(to), 0777); err != nil {
+		return err
+	}
+	return fs.CopyDir(dir, to)
+}
+
+// archiveRevisionNotFoundError is returned for a revision that is not the
+// digest of the archive of any version.
+type archiveRevisionNotFoundError struct {
+	rev Revision
+	url string
+}
+
+func (e *archiveRevisionNotFoundError) Error() string {
+	return fmt.Sprintf("%s is not the digest of any archive of %s", e.rev, e.url)
+}
+
+// revisionDir returns the directory holding the archive with digest r,
+// downloading and extracting it first if necessary. Only that archive is
+// downloaded, unless the digests of others must be computed to find it.
+func (s *archiveSource) revisionDir(ctx context.Context, r Revision) (string, error) {
+	if !archiveRevisionRegex.MatchString(string(r)) {
+		return "", &archiveRevisionNotFoundError{rev: r, url: s.upstreamURL()}
+	}
+	dir := filepath.Join(s.path, strings.TrimPrefix(string(r), archiveRevisionPrefix))
+	if _, err := os.Stat(dir); err == nil {
+		return dir, nil
+	}
+
+	if v, has := s.versionWithDigest(r); has {
+		if _, err := s.download(ctx, v, r); err != nil {
+			return "", err
+		}
+		return dir, nil
+	}
+
+	versions, err := s.knownDigests(ctx)
+	if err != nil {
+		return "", err
+	}
+	if v, has := s.versionWithDigest(r); has {
+		if _, err := s.download(ctx, v, r); err != nil {
+			return "", err
+		}
+		return dir, nil
+	}
+	for _, v := range versions {
+		if _, has := s.digests[v]; has {
+			continue
+		}
+		got, err := s.download(ctx, v, "")
+		if err != nil {
+			return "", err
+		}
+		if got == r {
+			return dir, nil
+		}
+	}
+	return "", &archiveRevisionNotFoundError{rev: r, url: s.upstreamURL()}
+}
+
+// versionWithDigest returns the version whose archive is known to have the
+// digest r.
+func (s *archiveSource) versionWithDigest(r Revision) (string, bool) {
+	for v, d := range s.digests {
+		if d == r {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// localDigest returns the recorded digest of the archive of version v, or ""
+// if it has not been downloaded.
+func (s *archiveSource) localDigest(v string) (Revision, error) {
+	b, err := ioutil.ReadFile(s.digestFile(v))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	r := Revision(strings.TrimSpace(string(b)))
+	if !archiveRevisionRegex.MatchString(string(r)) {
+		return "", nil
+	}
+	if _, err := os.Stat(filepath.Join(s.path, strings.TrimPrefix(string(r), archiveRevisionPrefix))); err != nil {
+		return "", nil
+	}
+	return r, nil
+}
+
+func (s *archiveSource) digestFile(v string) string {
+	return filepath.Join(s.path, url.PathEscape(v)+".digest")
+}
+
+// archiveURL returns the URL of the archive of version v.
+func (s *archiveSource) archiveURL(v string) string {
+	ev := url.PathEscape(v)
+	// The braces may have been escaped when the template was parsed.
+	return strings.NewReplacer(archiveVersionVar, ev, url.PathEscape(archiveVersionVar), ev).Replace(s.url.String())
+}
+
+// download downloads and extracts the archive of version v, and returns its
+// digest, which must be want, unless want is empty.
+func (s *archiveSource) download(ctx context.Context, v string, want Revision) (Revision, error) {
+	u := s.archiveURL(v)
+	body, err := s.get(ctx, u)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	// The archive is extracted to a temporary directory, so that an
+	// interrupted download leaves nothing behind.
+	af, err := ioutil.TempFile(s.path, ".archive-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(af.Name())
+	defer af.Close()
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(af, h), body); err != nil {
+		return "", errors.Wrapf(err, "failed to download %s", u)
+	}
+	r := Revision(archiveRevisionPrefix + hex.EncodeToString(h.Sum(nil)))
+	if want != "" && r != want {
+		return "", errors.Errorf("the archive %s has digest %s, not %s", u, r, want)
+	}
+
+	dir := filepath.Join(s.path, strings.TrimPrefix(string(r), archiveRevisionPrefix))
+	if _, err := os.Stat(dir); err != nil {
+		tmp, err := ioutil.TempDir(s.path, ".extract-")
+		if err != nil {
+			return "", err
+		}
+		defer os.RemoveAll(tmp)
+		if err := extractArchive(af, s.format, tmp); err != nil {
+			return "", errors.Wrapf(err, "failed to extract %s", u)
+		}
+		if err := fs.RenameWithFallback(archiveRoot(tmp), dir); err != nil {
+			return "", err
+		}
+	}
+
+	if err := ioutil.WriteFile(s.digestFile(v), []byte(string(r)+"\n"), 0666); err != nil {
+		return "", err
+	}
+	s.digests[v] = r
+	return r, nil
+}
+
+// readIndex reads the versions in the index, and the digests it gives for
+// them.
+func (s *archiveSource) readIndex(ctx context.Context) ([]string, map[string]Revision, error) {
+	body, err := s.get(ctx, s.index.String())
+	if err != nil {
+		return nil, nil, err
+	}
+	defer body.Close()
+
+	var versions []string
+	digests := make(map[string]Revision)
+	sc := bufio.NewScanner(body)
+	for line := 1; sc.Scan(); line++ {
+		f := strings.Fields(sc.Text())
+		if len(f) == 0 || strings.HasPrefix(f[0], "#") {
+			continue
+		}
+		if len(f) > 2 || (len(f) == 2 && !archiveRevisionRegex.MatchString(f[1])) {
+			return nil, nil, errors.Errorf("%s:%d: expected a version, optionally followed by a sha256: digest", s.index, line)
+		}
+		versions = append(versions, f[0])
+		if len(f) == 2 {
+			digests[f[0]] = Revision(f[1])
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to read the index %s", s.index)
+	}
+	return versions, digests, nil
+}
+
+func (s *archiveSource) get(ctx context.Context, u string) (io.ReadCloser, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to build HTTP request for URL %q", u)
+	}
+	req = addAuthFromNetrc(u, req)
+
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed HTTP request to URL %q", u)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, errors.Errorf("failed HTTP request to URL %q: %s", u, resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// extractArchive extracts the archive in f, of the given format, to dir.
+// Only directories and regular files are extracted.
+func extractArchive(f *os.File, format, dir string) error {
+	if format == archiveZip {
+		fi, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		zr, err := zip.NewReader(f, fi.Size())
+		if err != nil {
+			return err
+		}
+		for _, zf := range zr.File {
+			if strings.HasSuffix(zf.Name, "/") || !zf.Mode().IsRegular() {
+				continue
+			}
+			target, err := archiveTarget(dir, zf.Name)
+			if err != nil {
+				return err
+			}
+			if err := extractZipFile(zf, target); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	var r io.Reader = f
+	switch format {
+	case archiveTarGz:
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+		r = gr
+	case archiveTarBz2:
+		r = bzip2.NewReader(f)
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg && hdr.Typeflag != tar.TypeRegA {
+			continue
+		}
+		target, err := archiveTarget(dir, hdr.Name)
+		if err != nil {
+			return err
+		}
+		w, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(w, tr); err != nil {
+			w.Close()
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+	}
+}
+
+// archiveTarget returns the path in dir to extract the file with the given
+// name in an archive to, after creating its parent directory.
+func archiveTarget(dir, name string) (string, error) {
+	rel := strings.TrimPrefix(name, "./")
+	if !isSafeArchivePath(rel) {
+		return "", errors.Errorf("invalid file path %s", name)
+	}
+	target := filepath.Join(dir, filepath.FromSlash(rel))
+	return target, os.MkdirAll(filepath.Dir(target), 0777)
+}
+
+// isSafeArchivePath reports whether rel, the slash-separated path of a file
+// in an archive, stays within the directory the archive is extracted to.
+func isSafeArchivePath(rel string) bool {
+	return rel != "" && !path.IsAbs(rel) && path.Clean(rel) == rel &&
+		rel != ".." && !strings.HasPrefix(rel, "../") && !strings.Contains(rel, `\`)
+}
+
+// archiveRoot returns dir, or, if it contains nothing but a directory, that
+// directory.
+func archiveRoot(dir string) string {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil || len(fis) != 1 || !fis[0].IsDir() {
+		return dir
+	}
+	return filepath.Join(dir, fis[0].Name())
+}
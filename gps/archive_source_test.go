@@ -0,0 +1,342 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep/internal/test"
+)
+
+func mkTarGz(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gw)
+	for name, content := range files {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func mkZip(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func archiveDigest(b []byte) Revision {
+	sum := sha256.Sum256(b)
+	return Revision(archiveRevisionPrefix + hex.EncodeToString(sum[:]))
+}
+
+// newTestArchiveServer serves files, counting the GET requests for each.
+func newTestArchiveServer(files map[string][]byte) (*httptest.Server, map[string]int) {
+	hits := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Method == "GET" {
+			hits[r.URL.Path]++
+		}
+		w.Write(b)
+	}))
+	return srv, hits
+}
+
+func TestArchiveSource(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempDir("cache")
+
+	v1 := mkTarGz(t, map[string]string{
+		"sdk-1.0.0/sdk.go":        "package sdk\n",
+		"sdk-1.0.0/client/cli.go": "package client\n",
+	})
+	v2 := mkTarGz(t, map[string]string{
+		"sdk-1.1.0/sdk.go": "package sdk\n\nconst Version = \"1.1.0\"\n",
+	})
+	srv, hits := newTestArchiveServer(map[string][]byte{
+		"/sdk/sdk-1.0.0.tar.gz": v1,
+		"/sdk/sdk-1.1.0.tar.gz": v2,
+		"/sdk/index.txt":        []byte(fmt.Sprintf("# SDK releases\n1.0.0\n1.1.0 %s\n", archiveDigest(v2))),
+	})
+	defer srv.Close()
+
+	ctx := context.Background()
+	dc := newDeductionCoordinator(nil)
+	pd, err := dc.deduceArchiveSource(srv.URL + "/sdk/sdk-{version}.tar.gz#index=index.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := pd.mb[0].try(ctx, h.Path("cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !src.existsUpstream(ctx) || len(hits) != 0 {
+		t.Errorf("expected the source to exist upstream without downloading anything, got %v", hits)
+	}
+
+	// The digest of 1.0.0 is not in the index, so it is paired with a
+	// provisional revision, without downloading its archive.
+	vlist, err := src.listVersions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []PairedVersion{
+		NewVersion("1.0.0").Pair(provisionalRevisionPrefix + "1.0.0"),
+		NewVersion("1.1.0").Pair(archiveDigest(v2)),
+	}
+	if !reflect.DeepEqual(vlist, want) {
+		t.Errorf("unexpected versions:\n\t(GOT): %v\n\t(WNT): %v", vlist, want)
+	}
+	if hits["/sdk/sdk-1.0.0.tar.gz"] != 0 || hits["/sdk/sdk-1.1.0.tar.gz"] != 0 {
+		t.Errorf("expected no archive to be downloaded, got %v", hits)
+	}
+
+	// Resolving the provisional revision downloads the archive, and listing
+	// again uses the recorded digest.
+	asrc := src.(*archiveSource)
+	if r, err := asrc.resolveRevision(ctx, vlist[0].Revision()); err != nil || r != archiveDigest(v1) {
+		t.Errorf("expected the provisional revision to resolve to %s, got %s, %v", archiveDigest(v1), r, err)
+	}
+	vlist, err = src.listVersions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vlist[0].Revision() != archiveDigest(v1) || hits["/sdk/sdk-1.0.0.tar.gz"] != 1 {
+		t.Errorf("expected the archive of 1.0.0 to be downloaded once, and its digest listed, got %v, %v", vlist, hits)
+	}
+
+	ptree, err := src.listPackages(ctx, "vendor.corp/sdk", archiveDigest(v1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, has := ptree.Packages["vendor.corp/sdk/client"]; !has || len(ptree.Packages) != 2 {
+		t.Errorf("expected the packages of 1.0.0, without its top-level directory, got %v", ptree.Packages)
+	}
+
+	to := filepath.Join(h.Path("."), "export")
+	if err := src.exportRevisionTo(ctx, archiveDigest(v2), to); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(to, "sdk.go")); err != nil || !strings.Contains(string(b), "1.1.0") {
+		t.Errorf("expected the export to contain sdk.go of 1.1.0, got %q, %v", b, err)
+	}
+
+	// A source without the local digests downloads only the archive asked for,
+	// whose digest is in the index, and remembers the digest of 1.0.0 once it
+	// has downloaded it.
+	fresh, err := pd.mb[0].try(ctx, filepath.Join(h.Path("."), "cache2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fresh.exportRevisionTo(ctx, archiveDigest(v2), filepath.Join(h.Path("."), "export2")); err != nil {
+		t.Fatal(err)
+	}
+	if hits["/sdk/sdk-1.0.0.tar.gz"] != 1 || hits["/sdk/sdk-1.1.0.tar.gz"] != 2 {
+		t.Errorf("expected only the archive of 1.1.0 to be downloaded, got %v", hits)
+	}
+	if _, err := fresh.listPackages(ctx, "vendor.corp/sdk", archiveDigest(v1)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fresh.listVersions(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if hits["/sdk/sdk-1.0.0.tar.gz"] != 2 {
+		t.Errorf("expected the archive of 1.0.0 to be downloaded once more, got %v", hits)
+	}
+
+	if present, err := src.revisionPresentIn(archiveDigest([]byte("nope"))); err != nil || present {
+		t.Errorf("expected an unknown digest not to be present, got %v, %v", present, err)
+	}
+	if _, err := src.disambiguateRevision(ctx, "1.0.0"); err == nil {
+		t.Error("expected a version not to be accepted as a revision")
+	}
+}
+
+func TestArchiveSourceDigestMismatch(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempDir("cache")
+
+	v1 := mkZip(t, map[string]string{"sdk.go": "package sdk\n"})
+	srv, _ := newTestArchiveServer(map[string][]byte{
+		"/sdk-1.0.0.zip": v1,
+		"/index":         []byte("1.0.0 " + string(archiveDigest([]byte("something else"))) + "\n"),
+	})
+	defer srv.Close()
+
+	ctx := context.Background()
+	pd, err := newDeductionCoordinator(nil).deduceArchiveSource(srv.URL + "/sdk-{version}.zip#index=index")
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := pd.mb[0].try(ctx, h.Path("cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vlist, err := src.listVersions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = src.exportRevisionTo(ctx, vlist[0].Revision(), filepath.Join(h.Path("."), "export"))
+	if err == nil || !strings.Contains(err.Error(), "has digest") {
+		t.Errorf("expected the export to fail as the digest does not match, got %v", err)
+	}
+}
+
+func TestExtractArchiveSkipsSymlinks(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempDir("out")
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, mode := range map[string]os.FileMode{
+		"sdk.go": 0644,
+		"escape": os.ModeSymlink | 0777,
+	} {
+		fh := &zip.FileHeader{Name: name, Method: zip.Deflate}
+		fh.SetMode(mode)
+		f, err := zw.CreateHeader(fh)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte("../../etc/passwd")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	h.TempFile("sdk.zip", buf.String())
+
+	f, err := os.Open(h.Path("sdk.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := extractArchive(f, archiveZip, h.Path("out")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Lstat(filepath.Join(h.Path("out"), "sdk.go")); err != nil {
+		t.Error(err)
+	}
+	if _, err := os.Lstat(filepath.Join(h.Path("out"), "escape")); !os.IsNotExist(err) {
+		t.Errorf("expected the symlink not to be extracted, got %v", err)
+	}
+}
+
+func TestSourceManagerArchiveSource(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempDir("cache")
+
+	v1 := mkZip(t, map[string]string{"sdk/sdk.go": "package sdk\n"})
+	srv, _ := newTestArchiveServer(map[string][]byte{"/sdk-v1.0.0.zip": v1})
+	defer srv.Close()
+
+	sm, err := NewSourceManager(SourceManagerConfig{
+		Cachedir: h.Path("cache"),
+		Logger:   log.New(test.Writer{TB: t}, "", 0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Release()
+
+	id := ProjectIdentifier{ProjectRoot: "vendor.corp/sdk", Source: srv.URL + "/sdk-{version}.zip#versions=v1.0.0"}
+	vlist, err := sm.ListVersions(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []PairedVersion{NewVersion("v1.0.0").Pair(provisionalRevisionPrefix + "v1.0.0")}; !reflect.DeepEqual(vlist, want) {
+		t.Errorf("unexpected versions:\n\t(GOT): %v\n\t(WNT): %v", vlist, want)
+	}
+
+	// Using the version resolves its revision to the digest of its archive,
+	// which solutions then carry.
+	if _, err := sm.ListPackages(id, vlist[0]); err != nil {
+		t.Fatal(err)
+	}
+	want := NewVersion("v1.0.0").Pair(archiveDigest(v1))
+	if vlist, err = sm.ListVersions(id); err != nil || !reflect.DeepEqual(vlist, []PairedVersion{want}) {
+		t.Errorf("expected the version to be paired with the digest of its archive, got %v, %v", vlist, err)
+	}
+	if got := (&bridge{sm: sm}).resolveProvisional(id, NewVersion("v1.0.0").Pair(provisionalRevisionPrefix+"v1.0.0")); got != want {
+		t.Errorf("expected the provisional version to resolve to %v, got %v", want, got)
+	}
+
+	for _, bad := range []string{
+		srv.URL + "/sdk-{version}.zip",
+		srv.URL + "/sdk-{version}.rar#versions=1.0.0",
+		"ftp://vendor.corp/sdk-{version}.zip#versions=1.0.0",
+	} {
+		if _, err := sm.ListVersions(ProjectIdentifier{ProjectRoot: "vendor.corp/sdk", Source: bad}); err == nil {
+			t.Errorf("expected the source %q to be rejected", bad)
+		}
+	}
+}
+
+func TestIsSafeArchivePath(t *testing.T) {
+	for p, want := range map[string]bool{
+		"a/b.go":       true,
+		"a/../b.go":    false,
+		"../b.go":      false,
+		"/etc/passwd":  false,
+		"a//b.go":      false,
+		`a\..\..\b.go`: false,
+		"":             false,
+	} {
+		if got := isSafeArchivePath(p); got != want {
+			t.Errorf("isSafeArchivePath(%q) = %v, want %v", p, got, want)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"sort"
+	"strings"
+)
+
+// A conflictSet is a set of selected projects that, together with the root
+// project, are responsible for a failure: for as long as all of them remain
+// selected at their current versions, the failure will recur. The solver uses
+// it to backjump directly to the most recent selection that could make a
+// difference, skipping over all those that could not.
+type conflictSet map[ProjectRoot]struct{}
+
+// A nogood is a combination of atoms, each with at least the given packages,
+// that was found not to be part of any solution. The solver learns one each
+// time it backjumps, and rejects any atom that would complete one, rather
+// than exploring the same dead end again after backtracking further.
+type nogood []atomWithPackages
+
+// addCulprit adds the selected project with the given root to cs, unless it is
+// the root project.
+//
+// If packages were added to the project after it was first selected, the
+// projects that depend on it are added as well, as they are as responsible as
+// its version for the constraints it imposes.
+func (s *solver) addCulprit(cs conflictSet, pr ProjectRoot) {
+	if _, has := cs[pr]; has || s.rd.isRoot(pr) {
+		return
+	}
+	awp, has := s.sel.selected(ProjectIdentifier{ProjectRoot: pr})
+	if !has {
+		return
+	}
+	cs[pr] = struct{}{}
+
+	for _, sel := range s.sel.projects {
+		if !sel.first && sel.a.a.id.ProjectRoot == pr {
+			s.addDependers(cs, awp.a.id)
+			return
+		}
+	}
+}
+
+// addDependers adds the projects that depend on id to cs.
+func (s *solver) addDependers(cs conflictSet, id ProjectIdentifier) {
+	for _, dep := range s.sel.getDependenciesOn(id) {
+		s.addCulprit(cs, dep.depender.id.ProjectRoot)
+	}
+}
+
+// addCulprits adds the selected projects that caused err, a failure to select
+// an atom, to cs. Failures intrinsic to the atom, such as a missing revision,
+// add nothing.
+func (s *solver) addCulprits(cs conflictSet, err error) {
+	switch e := err.(type) {
+	case *versionNotAllowedFailure:
+		for _, dep := range e.failparent {
+			s.addCulprit(cs, dep.depender.id.ProjectRoot)
+		}
+	case *disjointConstraintFailure:
+		for _, dep := range e.failsib {
+			s.addCulprit(cs, dep.depender.id.ProjectRoot)
+		}
+	case *constraintNotAllowedFailure:
+		s.addCulprit(cs, e.goal.dep.Ident.ProjectRoot)
+	case *depHasProblemPackagesFailure:
+		s.addCulprit(cs, e.goal.dep.Ident.ProjectRoot)
+	case *checkeeHasProblemPackagesFailure:
+		for _, errdep := range e.failpkg {
+			for _, depper := range errdep.deppers {
+				s.addCulprit(cs, depper.id.ProjectRoot)
+			}
+		}
+	case *sourceMismatchFailure:
+		// The source in use was established by the project's dependers, or by
+		// the project itself, if selected.
+		s.addCulprit(cs, e.shared)
+		s.addDependers(cs, ProjectIdentifier{ProjectRoot: e.shared})
+	case *caseMismatchFailure:
+		s.addCulprit(cs, e.current)
+		for _, dep := range e.failsib {
+			s.addCulprit(cs, dep.depender.id.ProjectRoot)
+		}
+	case *wrongCaseFailure:
+		for _, dep := range e.badcase {
+			s.addCulprit(cs, dep.depender.id.ProjectRoot)
+		}
+	case *nogoodFailure:
+		for _, awp := range e.nogood {
+			s.addCulprit(cs, awp.a.id.ProjectRoot)
+		}
+	}
+}
+
+// conflictsOf returns the conflict set of a failure to select any version of
+// id: the projects that depend on it, along with those that caused the
+// failures of the versions in q, if any.
+func (s *solver) conflictsOf(id ProjectIdentifier, q *versionQueue) conflictSet {
+	cs := make(conflictSet)
+	if q != nil {
+		for pr := range q.conflicts {
+			s.addCulprit(cs, pr)
+		}
+	}
+	s.addDependers(cs, id)
+	return cs
+}
+
+// learn records the current selections of the projects in cs as a nogood.
+func (s *solver) learn(cs conflictSet) {
+	if len(cs) == 0 {
+		return
+	}
+
+	ng := make(nogood, 0, len(cs))
+	for pr := range cs {
+		awp, has := s.sel.selected(ProjectIdentifier{ProjectRoot: pr})
+		if !has {
+			// Only selected projects are added to conflict sets, so this
+			// indicates a broken invariant.
+			panic("canary - conflict set contains a project that is not selected: " + string(pr))
+		}
+
+		pm := s.sel.getSelectedPackagesIn(awp.a.id)
+		pl := make([]string, 0, len(pm))
+		for pkg := range pm {
+			pl = append(pl, pkg)
+		}
+		sort.Strings(pl)
+		ng = append(ng, atomWithPackages{a: awp.a, pl: pl})
+	}
+	sort.Slice(ng, func(i, j int) bool {
+		return ng[i].a.id.Less(ng[j].a.id)
+	})
+
+	if s.nogoods == nil {
+		s.nogoods = make(map[ProjectRoot][]nogood)
+	}
+	for _, awp := range ng {
+		s.nogoods[awp.a.id.ProjectRoot] = append(s.nogoods[awp.a.id.ProjectRoot], ng)
+	}
+}
+
+// completes reports whether selecting pa with the packages in pl would
+// complete ng, given the current selections.
+func (s *solver) completes(ng nogood, pa atom, pl []string) bool {
+	for _, m := range ng {
+		if m.a.id.ProjectRoot == pa.id.ProjectRoot {
+			if !m.a.id.eq(pa.id) || !m.a.v.identical(pa.v) || !containsAll(pl, m.pl) {
+				return false
+			}
+			continue
+		}
+
+		sel, has := s.sel.selected(m.a.id)
+		if !has || !sel.a.id.eq(m.a.id) || !sel.a.v.identical(m.a.v) {
+			return false
+		}
+		pm := s.sel.getSelectedPackagesIn(sel.a.id)
+		for _, pkg := range m.pl {
+			if _, has := pm[pkg]; !has {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// containsAll reports whether all the packages in sub are also in pl.
+func containsAll(pl, sub []string) bool {
+	if len(sub) == 0 {
+		return true
+	}
+	pm := make(map[string]struct{}, len(pl))
+	for _, pkg := range pl {
+		pm[pkg] = struct{}{}
+	}
+	for _, pkg := range sub {
+		if _, has := pm[pkg]; !has {
+			return false
+		}
+	}
+	return true
+}
+
+func (ng nogood) String() string {
+	var buf strings.Builder
+	for k, awp := range ng {
+		if k > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(a2vs(awp.a))
+	}
+	return buf.String()
+}
@@ -26,6 +26,7 @@ type sourceBridge interface {
 	DeduceProjectRoot(ip string) (ProjectRoot, error)
 
 	listVersions(ProjectIdentifier) ([]Version, error)
+	resolveProvisional(ProjectIdentifier, Version) Version
 	verifyRootDir(path string) error
 	vendorCodeExists(ProjectIdentifier) (bool, error)
 	breakLock()
@@ -112,6 +113,27 @@ func (b *bridge) listVersions(id ProjectIdentifier) ([]Version, error) {
 	return vl, nil
 }
 
+// resolveProvisional returns v paired with its real revision, if it is paired
+// with a provisional one. The source learns the real one when the version is
+// first used, as every version in a solution has been.
+func (b *bridge) resolveProvisional(id ProjectIdentifier, v Version) Version {
+	pv, ok := v.(PairedVersion)
+	if !ok || !isProvisionalRevision(pv.Revision()) {
+		return v
+	}
+
+	pvl, err := b.sm.ListVersions(id)
+	if err != nil {
+		return v
+	}
+	for _, lpv := range pvl {
+		if lpv.Unpair() == pv.Unpair() && !isProvisionalRevision(lpv.Revision()) {
+			return lpv
+		}
+	}
+	return v
+}
+
 func (b *bridge) RevisionPresentIn(id ProjectIdentifier, r Revision) (bool, error) {
 	b.s.mtr.push("b-rev-present-in")
 	i, e := b.sm.RevisionPresentIn(id, r)
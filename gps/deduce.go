@@ -19,6 +19,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	radix "github.com/armon/go-radix"
 	"github.com/pkg/errors"
@@ -30,6 +31,7 @@ var (
 	hgSchemes      = []string{"https", "ssh", "http"}
 	svnSchemes     = []string{"https", "http", "svn", "svn+ssh"}
 	gopkginSchemes = []string{"https", "http"}
+	forgeSchemes   = []string{"https", "ssh"}
 	netrc          []netrcLine
 	readNetrcOnce  sync.Once
 )
@@ -79,6 +81,9 @@ var (
 	//gcRegex      = regexp.MustCompile(`^(?P<root>code\.google\.com/[pr]/(?P<project>[a-z0-9\-]+)(\.(?P<subrepo>[a-z0-9\-]+))?)(/[A-Za-z0-9_.\-]+)*$`)
 	jazzRegex         = regexp.MustCompile(`^(?P<root>hub\.jazz\.net(/git/[a-z0-9]+/[A-Za-z0-9_.\-]+))((?:/[A-Za-z0-9_.\-]+)*)$`)
 	apacheRegex       = regexp.MustCompile(`^(?P<root>git\.apache\.org(/[a-z0-9_.\-]+\.git))((?:/[A-Za-z0-9_.\-]+)*)$`)
+	glRegex           = regexp.MustCompile(`^gitlab\.com(/[A-Za-z0-9_.\-]+){2,}$`)
+	giteaRegex        = regexp.MustCompile(`^(?P<root>(?P<host>gitea\.com|codeberg\.org)(/[A-Za-z0-9_.\-]+/[A-Za-z0-9_.\-]+))((?:/[A-Za-z0-9_.\-]+)*)$`)
+	srhtRegex         = regexp.MustCompile(`^(?P<root>(?P<vcs>git|hg)\.sr\.ht(/~[A-Za-z0-9_.\-]+/[A-Za-z0-9_.\-]+))((?:/[A-Za-z0-9_.\-]+)*)$`)
 	vcsExtensionRegex = regexp.MustCompile(`^(?P<root>([a-z0-9.\-]+\.)+[a-z0-9.\-]+(:[0-9]+)?/[A-Za-z0-9_.\-/~]*?\.(?P<vcs>bzr|git|hg|svn))((?:/[A-Za-z0-9_.\-]+)*)$`)
 )
 
@@ -98,6 +103,11 @@ func pathDeducerTrie() *deducerTrie {
 	dxt.Insert("git.launchpad.net/", launchpadGitDeducer{regexp: glpRegex})
 	dxt.Insert("hub.jazz.net/", jazzDeducer{regexp: jazzRegex})
 	dxt.Insert("git.apache.org/", apacheDeducer{regexp: apacheRegex})
+	dxt.Insert("gitlab.com/", gitlabDeducer{regexp: glRegex})
+	dxt.Insert("gitea.com/", giteaDeducer{regexp: giteaRegex})
+	dxt.Insert("codeberg.org/", giteaDeducer{regexp: giteaRegex})
+	dxt.Insert("git.sr.ht/", sourcehutDeducer{regexp: srhtRegex})
+	dxt.Insert("hg.sr.ht/", sourcehutDeducer{regexp: srhtRegex})
 
 	return dxt
 }
@@ -473,6 +483,156 @@ func (m apacheDeducer) deduceSource(path string, u *url.URL) (maybeSources, erro
 	return mb, nil
 }
 
+// A nestedRootDeducer is a pathDeducer for a host where projects may be nested
+// at any depth, so that an import path alone does not always say where its
+// project root is. Its deduceRoot returns the deepest root possible.
+type nestedRootDeducer interface {
+	pathDeducer
+	// possibleRoots returns the roots that path might have, deepest first.
+	// The deductionCoordinator picks the one named by the host's go get
+	// metadata, or else the first whose source exists upstream.
+	possibleRoots(path string) ([]string, error)
+	// deduceSourceAt is deduceSource for a project at root.
+	deduceSourceAt(root string, u *url.URL) (maybeSources, error)
+}
+
+// gitlabDeducer deduces the sources of projects on gitlab.com, which may be in
+// any number of nested subgroups. The end of the project's path can be marked
+// with a .git suffix, as in gitlab.com/group/sub/repo.git/pkg; otherwise, it is
+// found by checking upstream.
+type gitlabDeducer struct {
+	regexp *regexp.Regexp
+}
+
+func (m gitlabDeducer) possibleRoots(path string) ([]string, error) {
+	if !m.regexp.MatchString(path) {
+		return nil, fmt.Errorf("%s is not a valid path for a source on gitlab.com", path)
+	}
+
+	elems := strings.Split(path, "/")
+	for i := 2; i < len(elems); i++ {
+		if strings.HasSuffix(elems[i], ".git") {
+			return []string{strings.Join(elems[:i+1], "/")}, nil
+		}
+	}
+
+	roots := make([]string, 0, len(elems)-2)
+	for i := len(elems); i > 2; i-- {
+		roots = append(roots, strings.Join(elems[:i], "/"))
+	}
+	return roots, nil
+}
+
+func (m gitlabDeducer) deduceRoot(path string) (string, error) {
+	roots, err := m.possibleRoots(path)
+	if err != nil {
+		return "", err
+	}
+	return roots[0], nil
+}
+
+func (m gitlabDeducer) deduceSource(path string, u *url.URL) (maybeSources, error) {
+	root, err := m.deduceRoot(path)
+	if err != nil {
+		return nil, err
+	}
+	return m.deduceSourceAt(root, u)
+}
+
+func (m gitlabDeducer) deduceSourceAt(root string, u *url.URL) (maybeSources, error) {
+	u.Host = "gitlab.com"
+	u.Path = strings.TrimPrefix(root, "gitlab.com")
+	return forgeSources(u, "gitlab.com", "git", "git")
+}
+
+// giteaDeducer deduces the sources of projects on the public Gitea instances,
+// which, like those of Gogs, are at owner/repo.
+type giteaDeducer struct {
+	regexp *regexp.Regexp
+}
+
+func (m giteaDeducer) deduceRoot(path string) (string, error) {
+	v := m.regexp.FindStringSubmatch(path)
+	if v == nil {
+		return "", fmt.Errorf("%s is not a valid path for a source on a Gitea host", path)
+	}
+
+	return v[1], nil
+}
+
+func (m giteaDeducer) deduceSource(path string, u *url.URL) (maybeSources, error) {
+	v := m.regexp.FindStringSubmatch(path)
+	if v == nil {
+		return nil, fmt.Errorf("%s is not a valid path for a source on a Gitea host", path)
+	}
+
+	u.Host = v[2]
+	u.Path = v[3]
+	return forgeSources(u, v[2], "git", "git")
+}
+
+// sourcehutDeducer deduces the sources of projects on SourceHut, which keeps
+// git and hg repositories on separate hosts.
+type sourcehutDeducer struct {
+	regexp *regexp.Regexp
+}
+
+func (m sourcehutDeducer) deduceRoot(path string) (string, error) {
+	v := m.regexp.FindStringSubmatch(path)
+	if v == nil {
+		return "", fmt.Errorf("%s is not a valid path for a source on sr.ht", path)
+	}
+
+	return v[1], nil
+}
+
+func (m sourcehutDeducer) deduceSource(path string, u *url.URL) (maybeSources, error) {
+	v := m.regexp.FindStringSubmatch(path)
+	if v == nil {
+		return nil, fmt.Errorf("%s is not a valid path for a source on sr.ht", path)
+	}
+
+	u.Host = v[2] + ".sr.ht"
+	u.Path = v[3]
+	return forgeSources(u, u.Host, v[2], v[2])
+}
+
+// forgeSources returns the maybeSources for the repository of type vcs at u, on
+// a host that serves repositories over https and ssh, with sshUser as the ssh
+// user. If u has a scheme, only it is used.
+func forgeSources(u *url.URL, host, vcs, sshUser string) (maybeSources, error) {
+	mk := func(u *url.URL) maybeSource {
+		if vcs == "hg" {
+			return maybeHgSource{url: u}
+		}
+		return maybeGitSource{url: u}
+	}
+
+	if u.Scheme == "ssh" && u.User != nil && u.User.Username() != sshUser {
+		return nil, fmt.Errorf("%s ssh must be accessed via the '%s' user; %s was provided", host, sshUser, u.User.Username())
+	} else if u.Scheme != "" {
+		if !validateVCSScheme(u.Scheme, vcs) {
+			return nil, fmt.Errorf("%s is not a valid scheme for accessing %s repositories", u.Scheme, vcs)
+		}
+		if u.Scheme == "ssh" {
+			u.User = url.User(sshUser)
+		}
+		return maybeSources{mk(u)}, nil
+	}
+
+	mb := make(maybeSources, len(forgeSchemes))
+	for k, scheme := range forgeSchemes {
+		u2 := *u
+		if scheme == "ssh" {
+			u2.User = url.User(sshUser)
+		}
+		u2.Scheme = scheme
+		mb[k] = mk(&u2)
+	}
+
+	return mb, nil
+}
+
 type vcsExtensionDeducer struct {
 	regexp *regexp.Regexp
 }
@@ -493,7 +653,7 @@ func (m vcsExtensionDeducer) deduceSource(path string, u *url.URL) (maybeSources
 	}
 
 	switch v[4] {
-	case "git", "hg", "bzr":
+	case "git", "hg", "bzr", "svn":
 		x := strings.SplitN(v[1], "/", 2)
 		// TODO(sdboyer) is this actually correct for bzr?
 		u.Host = x[0]
@@ -511,6 +671,8 @@ func (m vcsExtensionDeducer) deduceSource(path string, u *url.URL) (maybeSources
 				return maybeSources{maybeBzrSource{url: u}}, nil
 			case "hg":
 				return maybeSources{maybeHgSource{url: u}}, nil
+			case "svn":
+				return maybeSources{maybeSvnSource{url: u}}, nil
 			}
 		}
 
@@ -534,6 +696,11 @@ func (m vcsExtensionDeducer) deduceSource(path string, u *url.URL) (maybeSources
 			f = func(k int, u *url.URL) {
 				mb[k] = maybeHgSource{url: u}
 			}
+		case "svn":
+			schemes = svnSchemes
+			f = func(k int, u *url.URL) {
+				mb[k] = maybeSvnSource{url: u}
+			}
 		}
 
 		mb = make(maybeSources, len(schemes))
@@ -561,10 +728,23 @@ type deducer interface {
 }
 
 type deductionCoordinator struct {
-	suprvsr  *supervisor
-	mut      sync.RWMutex
-	rootxt   *radix.Tree
-	deducext *deducerTrie
+	suprvsr   *supervisor
+	mut       sync.RWMutex
+	rootxt    *radix.Tree
+	deducext  *deducerTrie
+	offline   bool                // don't retrieve go get metadata
+	rewrites  urlRewrites         // applied to the URLs of deduced sources
+	proxies   []*url.URL          // Go module proxies; nil for direct
+	linkLocal bool                // export local sources as symlinks
+	cache     *deductionCacheBolt // persistent cache of go get metadata, if any
+	client    *http.Client        // retrieves go get metadata
+	// partialClone causes git sources to be cloned as partial clones.
+	partialClone bool
+	// probe reports whether a source exists upstream, when choosing between
+	// the possible roots of a nestedRootDeducer. Its answers are kept in
+	// probed, keyed by URL.
+	probe  func(context.Context, maybeSource) bool
+	probed map[string]bool
 }
 
 func newDeductionCoordinator(superv *supervisor) *deductionCoordinator {
@@ -572,19 +752,75 @@ func newDeductionCoordinator(superv *supervisor) *deductionCoordinator {
 		suprvsr:  superv,
 		rootxt:   radix.New(),
 		deducext: pathDeducerTrie(),
+		probe:    maybeSourceExists,
+		probed:   make(map[string]bool),
+		client:   http.DefaultClient,
 	}
 
 	return dc
 }
 
+// close closes dc's persistent cache, unless it is shared with a sourceCache,
+// which closes it instead.
+func (dc *deductionCoordinator) close() {
+	if dc.cache == nil || !dc.cache.owned {
+		return
+	}
+	if err := dc.cache.close(); err != nil {
+		dc.cache.logger.Println(errors.Wrap(err, "failed to close the deduction cache"))
+	}
+}
+
 // deduceRootPath takes an import path and attempts to deduce various
 // metadata about it - what type of source should handle it, and where its
 // "root" is (for vcs repositories, the repository root).
 //
 // If no errors are encountered, the returned pathDeduction will contain both
 // the root path and a list of maybeSources, which can be subsequently used to
-// create a handler that will manage the particular source.
+// create a handler that will manage the particular source. Their URLs have
+// been rewritten according to any URLRewrite rules.
 func (dc *deductionCoordinator) deduceRootPath(ctx context.Context, path string) (pathDeduction, error) {
+	pd, err := dc.deduceUnrewrittenRootPath(ctx, path)
+	if err != nil || (len(dc.rewrites) == 0 && len(dc.proxies) == 0 && !dc.partialClone && !dc.offline) {
+		return pd, err
+	}
+
+	mb := pd.mb
+	if len(dc.rewrites) > 0 {
+		if mb, err = dc.rewrites.rewrite(mb); err != nil {
+			return pathDeduction{}, err
+		}
+	}
+	return pathDeduction{root: pd.root, mb: dc.proxied(pd.root, dc.partial(mb))}, nil
+}
+
+// partial returns mb with its git sources marked to be cloned as partial
+// clones, if dc is configured to do so, and to never fetch the files missing
+// from a partial clone, which may be left by an earlier run, while offline.
+func (dc *deductionCoordinator) partial(mb maybeSources) maybeSources {
+	if !dc.partialClone && !dc.offline {
+		return mb
+	}
+
+	pmb := make(maybeSources, len(mb))
+	for k, m := range mb {
+		switch m := m.(type) {
+		case maybeGitSource:
+			m.partial, m.offline = dc.partialClone, dc.offline
+			pmb[k] = m
+		case maybeGopkginSource:
+			m.partial, m.offline = dc.partialClone, dc.offline
+			pmb[k] = m
+		default:
+			pmb[k] = m
+		}
+	}
+	return pmb
+}
+
+// deduceUnrewrittenRootPath does the work of deduceRootPath. Deductions are
+// recorded in the rootxt as they were made, before any rewriting.
+func (dc *deductionCoordinator) deduceUnrewrittenRootPath(ctx context.Context, path string) (pathDeduction, error) {
 	if err := dc.suprvsr.ctx.Err(); err != nil {
 		return pathDeduction{}, err
 	}
@@ -611,7 +847,7 @@ func (dc *deductionCoordinator) deduceRootPath(ctx context.Context, path string)
 	}
 
 	// No match. Try known path deduction first.
-	pd, err := dc.deduceKnownPaths(path)
+	pd, err := dc.deduceKnownPaths(ctx, path)
 	if err == nil {
 		// Deduction worked; store it in the rootxt, send on retchan and
 		// terminate.
@@ -632,6 +868,9 @@ func (dc *deductionCoordinator) deduceRootPath(ctx context.Context, path string)
 	hmd := &httpMetadataDeducer{
 		basePath: path,
 		suprvsr:  dc.suprvsr,
+		cache:    dc.cache,
+		offline:  dc.offline,
+		client:   dc.client,
 		// The vanity deducer will call this func with a completed
 		// pathDeduction if it succeeds in finding one. We process it
 		// back through the action channel to ensure serialized
@@ -663,7 +902,14 @@ type pathDeduction struct {
 
 var errNoKnownPathMatch = errors.New("no known path match")
 
-func (dc *deductionCoordinator) deduceKnownPaths(path string) (pathDeduction, error) {
+func (dc *deductionCoordinator) deduceKnownPaths(ctx context.Context, path string) (pathDeduction, error) {
+	if strings.HasPrefix(path, localSourceScheme+"://") {
+		return dc.deduceLocalSource(path)
+	}
+	if strings.Contains(path, archiveVersionVar) {
+		return dc.deduceArchiveSource(path)
+	}
+
 	u, path, err := normalizeURI(path)
 	if err != nil {
 		return pathDeduction{}, err
@@ -671,6 +917,10 @@ func (dc *deductionCoordinator) deduceKnownPaths(path string) (pathDeduction, er
 
 	// First, try the root path-based matches
 	if _, mtch, has := dc.deducext.LongestPrefix(path); has {
+		if nested, ok := mtch.(nestedRootDeducer); ok {
+			return dc.deduceNestedRoot(ctx, nested, path, u)
+		}
+
 		root, err := mtch.deduceRoot(path)
 		if err != nil {
 			return pathDeduction{}, err
@@ -703,6 +953,147 @@ func (dc *deductionCoordinator) deduceKnownPaths(path string) (pathDeduction, er
 	return pathDeduction{}, errNoKnownPathMatch
 }
 
+// deduceNestedRoot picks the possible root of path that the host's go get
+// metadata names. Failing that, it picks the deepest whose source exists
+// upstream, checking the first URL of each root before any other, so that ssh
+// is only tried if https does not answer. If none do, the shallowest is used,
+// so that the packages beneath it are deduced to be in the same project
+// without checking again.
+func (dc *deductionCoordinator) deduceNestedRoot(ctx context.Context, d nestedRootDeducer, path string, u *url.URL) (pathDeduction, error) {
+	roots, err := d.possibleRoots(path)
+	if err != nil {
+		return pathDeduction{}, err
+	}
+
+	deduce := func(root string) (pathDeduction, error) {
+		u2 := *u
+		mb, err := d.deduceSourceAt(root, &u2)
+		return pathDeduction{root: root, mb: mb}, err
+	}
+
+	if len(roots) == 1 {
+		return deduce(roots[0])
+	}
+	if dc.offline {
+		return pathDeduction{}, &OfflineError{Source: path, Op: "find the project root of"}
+	}
+
+	// Hosts hide private projects in their metadata by naming the shallowest
+	// root, so only a deeper one is taken as it is.
+	meta := dc.nestedMetadataRoot(ctx, path, roots)
+	if meta != "" && meta != roots[len(roots)-1] {
+		return deduce(meta)
+	}
+
+	pds := make([]pathDeduction, len(roots))
+	checks := make([]maybeSources, len(roots))
+	var most int
+	for k, root := range roots {
+		if pds[k], err = deduce(root); err != nil {
+			return pathDeduction{}, err
+		}
+
+		// Check the URLs that will actually be used.
+		checks[k] = pds[k].mb
+		if len(dc.rewrites) > 0 {
+			if checks[k], err = dc.rewrites.rewrite(pds[k].mb); err != nil {
+				return pathDeduction{}, err
+			}
+		}
+		if len(checks[k]) > most {
+			most = len(checks[k])
+		}
+	}
+
+	for i := 0; i < most; i++ {
+		for k := range roots {
+			if i >= len(checks[k]) {
+				continue
+			}
+			exists, err := dc.sourceExists(ctx, checks[k][i])
+			if err != nil {
+				return pathDeduction{}, err
+			}
+			if exists {
+				return pds[k], nil
+			}
+		}
+	}
+
+	return pds[len(pds)-1], nil
+}
+
+// nestedMetadataRoot returns the root that the go get metadata for path names,
+// if it is one of roots.
+func (dc *deductionCoordinator) nestedMetadataRoot(ctx context.Context, path string, roots []string) string {
+	var root string
+	dc.suprvsr.do(ctx, path, ctHTTPMetadata, func(ctx context.Context) error {
+		var err error
+		root, _, _, err = getMetadata(ctx, dc.client, path, "https")
+		return err
+	})
+	for _, r := range roots {
+		if r == root {
+			return root
+		}
+	}
+	return ""
+}
+
+// sourceExists reports whether the repository of mb answers upstream,
+// remembering the answer for the lifetime of dc.
+func (dc *deductionCoordinator) sourceExists(ctx context.Context, mb maybeSource) (bool, error) {
+	key := mb.URL().String()
+	dc.mut.RLock()
+	exists, has := dc.probed[key]
+	dc.mut.RUnlock()
+	if has {
+		return exists, nil
+	}
+
+	err := dc.suprvsr.do(ctx, key, ctSourcePing, func(ctx context.Context) error {
+		exists = dc.probe(ctx, mb)
+		return nil
+	})
+	if err != nil {
+		return false, err
+	}
+
+	dc.mut.Lock()
+	dc.probed[key] = exists
+	dc.mut.Unlock()
+	return exists, nil
+}
+
+// probeTimeout bounds the time that maybeSourceExists waits for an answer.
+const probeTimeout = 30 * time.Second
+
+// maybeSourceExists reports whether the repository of mb answers upstream. Only
+// git repositories can be checked.
+func maybeSourceExists(ctx context.Context, mb maybeSource) bool {
+	m, ok := mb.(maybeGitSource)
+	if !ok {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+	defer cancel()
+
+	cmd := commandContext(ctx, "git", "ls-remote", m.url.String(), "HEAD")
+	// Ensure no prompting for PWs
+	env := append([]string{"GIT_ASKPASS=", "GIT_TERMINAL_PROMPT=0"}, os.Environ()...)
+	// Never wait on a prompt for a host key or a passphrase, unless the user
+	// has configured the ssh command, which is then left alone.
+	if os.Getenv("GIT_SSH_COMMAND") == "" && os.Getenv("GIT_SSH") == "" {
+		if _, has := gitConfig(ctx, "", "core.sshCommand"); !has {
+			env = append(env, "GIT_SSH_COMMAND=ssh -o BatchMode=yes")
+		}
+	}
+	cmd.SetEnv(env)
+	_, err := cmd.CombinedOutput()
+	return err == nil
+}
+
 type httpMetadataDeducer struct {
 	once       sync.Once
 	deduced    pathDeduction
@@ -710,6 +1101,9 @@ type httpMetadataDeducer struct {
 	basePath   string
 	returnFunc func(pathDeduction)
 	suprvsr    *supervisor
+	cache      *deductionCacheBolt // consulted before retrieving metadata
+	offline    bool                // only cached metadata may be used
+	client     *http.Client
 }
 
 func (hmd *httpMetadataDeducer) deduce(ctx context.Context, path string) (pathDeduction, error) {
@@ -724,19 +1118,27 @@ func (hmd *httpMetadataDeducer) deduce(ctx context.Context, path string) (pathDe
 
 		pd := pathDeduction{}
 
-		// Make the HTTP call to attempt to retrieve go-get metadata
-		var root, vcs, reporoot string
-		err = hmd.suprvsr.do(ctx, path, ctHTTPMetadata, func(ctx context.Context) error {
-			root, vcs, reporoot, err = getMetadata(ctx, path, u.Scheme)
+		// Use go-get metadata from a previous run if it's cached; otherwise,
+		// make the HTTP call to attempt to retrieve it.
+		root, vcs, reporoot, cached := hmd.cache.getMetadata(path)
+		if !cached {
+			if hmd.offline {
+				hmd.deduceErr = &OfflineError{Source: opath, Op: "retrieve go get metadata for"}
+				return
+			}
+
+			err = hmd.suprvsr.do(ctx, path, ctHTTPMetadata, func(ctx context.Context) error {
+				root, vcs, reporoot, err = getMetadata(ctx, hmd.client, path, u.Scheme)
+				if err != nil {
+					err = errors.Wrapf(err, "unable to read metadata")
+				}
+				return err
+			})
 			if err != nil {
-				err = errors.Wrapf(err, "unable to read metadata")
+				err = errors.Wrapf(err, "unable to deduce repository and source type for %q", opath)
+				hmd.deduceErr = err
+				return
 			}
-			return err
-		})
-		if err != nil {
-			err = errors.Wrapf(err, "unable to deduce repository and source type for %q", opath)
-			hmd.deduceErr = err
-			return
 		}
 		pd.root = root
 
@@ -770,11 +1172,17 @@ func (hmd *httpMetadataDeducer) deduce(ctx context.Context, path string) (pathDe
 			pd.mb = maybeSources{maybeBzrSource{url: repoURL}}
 		case "hg":
 			pd.mb = maybeSources{maybeHgSource{url: repoURL}}
+		case "svn":
+			pd.mb = maybeSources{maybeSvnSource{url: repoURL}}
 		default:
 			hmd.deduceErr = errors.Errorf("unsupported vcs type %s in go-get metadata from %s", vcs, path)
 			return
 		}
 
+		if !cached {
+			hmd.cache.setMetadata(path, root, vcs, reporoot)
+		}
+
 		hmd.deduced = pd
 		// All data is assigned for other goroutines that may be waiting. Now,
 		// send the pathDeduction back to the deductionCoordinator by calling
@@ -829,23 +1237,23 @@ func normalizeURI(p string) (*url.URL, string, error) {
 	return u, newpath, nil
 }
 
-// fetchMetadata fetches the remote metadata for path.
-func fetchMetadata(ctx context.Context, path, scheme string) (rc io.ReadCloser, err error) {
+// fetchMetadata fetches the remote metadata for path with client.
+func fetchMetadata(ctx context.Context, client *http.Client, path, scheme string) (rc io.ReadCloser, err error) {
 	if scheme == "http" {
-		rc, err = doFetchMetadata(ctx, "http", path)
+		rc, err = doFetchMetadata(ctx, client, "http", path)
 		return
 	}
 
-	rc, err = doFetchMetadata(ctx, "https", path)
+	rc, err = doFetchMetadata(ctx, client, "https", path)
 	if err == nil {
 		return
 	}
 
-	rc, err = doFetchMetadata(ctx, "http", path)
+	rc, err = doFetchMetadata(ctx, client, "http", path)
 	return
 }
 
-func doFetchMetadata(ctx context.Context, scheme, path string) (io.ReadCloser, error) {
+func doFetchMetadata(ctx context.Context, client *http.Client, scheme, path string) (io.ReadCloser, error) {
 	url := fmt.Sprintf("%s://%s?go-get=1", scheme, path)
 	switch scheme {
 	case "https", "http":
@@ -856,7 +1264,7 @@ func doFetchMetadata(ctx context.Context, scheme, path string) (io.ReadCloser, e
 
 		req = addAuthFromNetrc(url, req)
 
-		resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+		resp, err := client.Do(req.WithContext(ctx))
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed HTTP request to URL %q", url)
 		}
@@ -979,8 +1387,8 @@ func addAuthFromNetrc(rawurl string, req *http.Request) *http.Request {
 // scheme is optional. If it's http, only http will be attempted for fetching.
 // Any other scheme (including none) will first try https, then fall back to
 // http.
-func getMetadata(ctx context.Context, path, scheme string) (string, string, string, error) {
-	rc, err := fetchMetadata(ctx, path, scheme)
+func getMetadata(ctx context.Context, client *http.Client, path, scheme string) (string, string, string, error) {
+	rc, err := fetchMetadata(ctx, client, path, scheme)
 	if err != nil {
 		return "", "", "", errors.Wrapf(err, "unable to fetch raw metadata")
 	}
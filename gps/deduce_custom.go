@@ -0,0 +1,143 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// CustomDeducer is a user-defined rule for deducing the project roots and
+// sources of the import paths on a host that gps does not know about, and that
+// may not serve go get metadata.
+type CustomDeducer struct {
+	// Prefix is the import path prefix the rule applies to, such as
+	// "git.corp.example.com/". It takes precedence over any built-in rule for
+	// the same prefix.
+	Prefix string
+	// Root is a regular expression matching the import paths under Prefix,
+	// whose subexpression named "root" matches their project root, such as
+	// `^(?P<root>git\.corp\.example\.com/(?P<repo>[^/]+/[^/]+))(/.*)?$`.
+	Root string
+	// VCS is the type of the repositories: "git", "hg", "bzr" or "svn".
+	VCS string
+	// URL is the template for the URLs of the repositories. "{root}" is
+	// replaced by the project root, and "{name}" by the match of the Root
+	// subexpression called name, as in "ssh://git@git.corp.example.com/{repo}.git".
+	URL string
+}
+
+var customDeducerVarRegex = regexp.MustCompile(`\{([A-Za-z0-9_]+)\}`)
+
+// customDeducer is the pathDeducer for a CustomDeducer.
+type customDeducer struct {
+	regexp *regexp.Regexp
+	vcs    string
+	url    string
+}
+
+// newCustomDeducers validates cds, and returns their pathDeducers keyed by
+// prefix.
+func newCustomDeducers(cds []CustomDeducer) (map[string]pathDeducer, error) {
+	ds := make(map[string]pathDeducer, len(cds))
+	for _, cd := range cds {
+		if cd.Prefix == "" {
+			return nil, errors.New("custom deducer has no import path prefix")
+		}
+		if _, has := ds[cd.Prefix]; has {
+			return nil, errors.Errorf("multiple custom deducers for %s", cd.Prefix)
+		}
+
+		re, err := regexp.Compile(cd.Root)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid root regexp for custom deducer %s", cd.Prefix)
+		}
+		names := make(map[string]bool)
+		for _, name := range re.SubexpNames() {
+			names[name] = true
+		}
+		if !names["root"] {
+			return nil, errors.Errorf("root regexp for custom deducer %s has no subexpression named \"root\"", cd.Prefix)
+		}
+
+		switch cd.VCS {
+		case "git", "hg", "bzr", "svn":
+		default:
+			return nil, errors.Errorf("custom deducer %s has unsupported vcs type %q", cd.Prefix, cd.VCS)
+		}
+
+		if cd.URL == "" {
+			return nil, errors.Errorf("custom deducer %s has no URL template", cd.Prefix)
+		}
+		for _, m := range customDeducerVarRegex.FindAllStringSubmatch(cd.URL, -1) {
+			if !names[m[1]] {
+				return nil, errors.Errorf("URL template for custom deducer %s refers to unknown subexpression %q", cd.Prefix, m[1])
+			}
+		}
+
+		ds[cd.Prefix] = customDeducer{regexp: re, vcs: cd.VCS, url: cd.URL}
+	}
+	return ds, nil
+}
+
+// match returns the matches of the named subexpressions of m's regexp in path.
+func (m customDeducer) match(path string) (map[string]string, error) {
+	v := m.regexp.FindStringSubmatch(path)
+	if v == nil {
+		return nil, fmt.Errorf("%s does not match the custom deducer regexp %s", path, m.regexp)
+	}
+
+	vars := make(map[string]string)
+	for i, name := range m.regexp.SubexpNames() {
+		if name != "" {
+			vars[name] = v[i]
+		}
+	}
+	if root := vars["root"]; root == "" || !strings.HasPrefix(path, root) || !isPathPrefixOrEqual(root, path) {
+		return nil, fmt.Errorf("custom deducer regexp %s matched root %q, which does not contain %s", m.regexp, vars["root"], path)
+	}
+	return vars, nil
+}
+
+func (m customDeducer) deduceRoot(path string) (string, error) {
+	vars, err := m.match(path)
+	if err != nil {
+		return "", err
+	}
+	return vars["root"], nil
+}
+
+func (m customDeducer) deduceSource(path string, u *url.URL) (maybeSources, error) {
+	vars, err := m.match(path)
+	if err != nil {
+		return nil, err
+	}
+
+	ustr := customDeducerVarRegex.ReplaceAllStringFunc(m.url, func(s string) string {
+		return vars[s[1:len(s)-1]]
+	})
+	su, err := url.Parse(ustr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "custom deducer for %s produced an invalid URL", path)
+	}
+	if !validateVCSScheme(su.Scheme, m.vcs) {
+		return nil, fmt.Errorf("%q is not a valid scheme for accessing %s repositories (URL %s)", su.Scheme, m.vcs, ustr)
+	}
+
+	switch m.vcs {
+	case "git":
+		return maybeSources{maybeGitSource{url: su}}, nil
+	case "hg":
+		return maybeSources{maybeHgSource{url: su}}, nil
+	case "svn":
+		return maybeSources{maybeSvnSource{url: su}}, nil
+	default:
+		return maybeSources{maybeBzrSource{url: su}}, nil
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestNewCustomDeducersValidation(t *testing.T) {
+	valid := CustomDeducer{
+		Prefix: "git.corp.example.com/",
+		Root:   `^(?P<root>git\.corp\.example\.com/[^/]+/[^/]+)(/.*)?$`,
+		VCS:    "git",
+		URL:    "https://{root}.git",
+	}
+	if _, err := newCustomDeducers([]CustomDeducer{valid}); err != nil {
+		t.Fatalf("unexpected error for a valid deducer: %s", err)
+	}
+
+	bad := map[string]func(cd *CustomDeducer){
+		"no prefix":      func(cd *CustomDeducer) { cd.Prefix = "" },
+		"bad regexp":     func(cd *CustomDeducer) { cd.Root = `^(?P<root>git` },
+		"no root group":  func(cd *CustomDeducer) { cd.Root = `^(git\.corp\.example\.com/[^/]+/[^/]+)(/.*)?$` },
+		"bad vcs":        func(cd *CustomDeducer) { cd.VCS = "cvs" },
+		"no url":         func(cd *CustomDeducer) { cd.URL = "" },
+		"unknown var":    func(cd *CustomDeducer) { cd.URL = "https://{repo}.git" },
+		"duplicate rule": nil,
+	}
+	for name, f := range bad {
+		cds := []CustomDeducer{valid, valid}
+		if f != nil {
+			cds = cds[:1]
+			f(&cds[0])
+		}
+		if _, err := newCustomDeducers(cds); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestCustomDeducer(t *testing.T) {
+	ds, err := newCustomDeducers([]CustomDeducer{
+		{
+			Prefix: "git.corp.example.com/",
+			Root:   `^(?P<root>git\.corp\.example\.com/(?P<repo>[^/]+/[^/]+))(/.*)?$`,
+			VCS:    "git",
+			URL:    "ssh://git@git.corp.example.com:7999/{repo}.git",
+		},
+		{
+			Prefix: "hg.corp.example.com/",
+			Root:   `^(?P<root>hg\.corp\.example\.com/[^/]+)(/.*)?$`,
+			VCS:    "hg",
+			URL:    "https://{root}",
+		},
+		{
+			Prefix: "bad.corp.example.com/",
+			Root:   `^(?P<root>bad\.corp\.example\.com/[^/]+)(/.*)?$`,
+			VCS:    "git",
+			URL:    "{root}",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	dc := newDeductionCoordinator(newSupervisor(ctx))
+	for prefix, d := range ds {
+		dc.deducext.Insert(prefix, d)
+	}
+
+	table := []struct {
+		in   string
+		root string
+		mb   maybeSources
+	}{
+		{
+			in:   "git.corp.example.com/team/proj/sub/pkg",
+			root: "git.corp.example.com/team/proj",
+			mb:   maybeSources{maybeGitSource{url: mkurl("ssh://git@git.corp.example.com:7999/team/proj.git")}},
+		},
+		{
+			in:   "hg.corp.example.com/proj",
+			root: "hg.corp.example.com/proj",
+			mb:   maybeSources{maybeHgSource{url: mkurl("https://hg.corp.example.com/proj")}},
+		},
+	}
+	for _, fix := range table {
+		pd, err := dc.deduceRootPath(ctx, fix.in)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", fix.in, err)
+			continue
+		}
+		if pd.root != fix.root {
+			t.Errorf("%s: expected root %q, got %q", fix.in, fix.root, pd.root)
+		}
+		if !reflect.DeepEqual(pd.mb, fix.mb) {
+			t.Errorf("%s: unexpected sources:\n\t(GOT): %v\n\t(WNT): %v", fix.in, pd.mb, fix.mb)
+		}
+	}
+
+	for _, in := range []string{"git.corp.example.com/team", "bad.corp.example.com/proj"} {
+		if _, err := dc.deduceRootPath(ctx, in); err == nil {
+			t.Errorf("%s: expected an error", in)
+		}
+	}
+}
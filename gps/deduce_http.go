@@ -0,0 +1,165 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// HTTPConfig configures the HTTP client that retrieves go get metadata, and
+// that fetches from Go module proxies. The zero value uses http.DefaultClient.
+type HTTPConfig struct {
+	// CAFile is the path of a PEM bundle of certificate authorities to trust,
+	// in addition to those of the system.
+	CAFile string
+	// CertFile is the path of a PEM client certificate, presented to servers
+	// that request one. KeyFile is the path of its private key; if it is
+	// empty, the key is read from CertFile.
+	CertFile string
+	KeyFile  string
+	// Proxy is the URL of the proxy to send requests through. If it is empty,
+	// the proxy is chosen by the HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	// environment variables.
+	Proxy string
+	// Timeout limits the time taken by each request, including reading the
+	// response. <=0: No limit.
+	Timeout time.Duration
+	// Headers are set on the requests to particular hosts, e.g. to pass a
+	// bearer token.
+	Headers []HTTPHeader
+}
+
+// HTTPHeader is a header to set on the HTTPS requests to a host. It is not set
+// on requests that are redirected to other hosts.
+type HTTPHeader struct {
+	Host  string // The host name, without a port, e.g. "go.corp.example.com".
+	Name  string
+	Value string
+	// Insecure also sets the header on plain HTTP requests to the host, where
+	// it is sent in cleartext.
+	Insecure bool
+}
+
+func (c HTTPConfig) isZero() bool {
+	return c.CAFile == "" && c.CertFile == "" && c.KeyFile == "" && c.Proxy == "" &&
+		c.Timeout <= 0 && len(c.Headers) == 0
+}
+
+// newHTTPClient validates c, and returns a client configured by it.
+func newHTTPClient(c HTTPConfig) (*http.Client, error) {
+	if c.isZero() {
+		return http.DefaultClient, nil
+	}
+
+	// The same settings as http.DefaultTransport.
+	t := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+
+	if c.Proxy != "" {
+		u, err := url.Parse(c.Proxy)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, errors.Errorf("invalid HTTP proxy URL %q", c.Proxy)
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+
+	if c.CAFile != "" || c.CertFile != "" {
+		t.TLSClientConfig = &tls.Config{}
+	}
+	if c.CAFile != "" {
+		pem, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to read CA bundle")
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificates found in CA bundle %s", c.CAFile)
+		}
+		t.TLSClientConfig.RootCAs = pool
+	}
+	if c.CertFile != "" {
+		keyFile := c.KeyFile
+		if keyFile == "" {
+			keyFile = c.CertFile
+		}
+		cert, err := tls.LoadX509KeyPair(c.CertFile, keyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to load client certificate")
+		}
+		t.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	} else if c.KeyFile != "" {
+		return nil, errors.Errorf("client key %s has no certificate", c.KeyFile)
+	}
+
+	client := &http.Client{Transport: t}
+	if c.Timeout > 0 {
+		client.Timeout = c.Timeout
+	}
+
+	if len(c.Headers) > 0 {
+		ht := headerTransport{base: t, headers: make(map[string][]HTTPHeader)}
+		for _, h := range c.Headers {
+			if h.Host == "" || h.Name == "" {
+				return nil, errors.Errorf("HTTP header %q for host %q must have both a host and a name", h.Name, h.Host)
+			}
+			ht.headers[h.Host] = append(ht.headers[h.Host], h)
+		}
+		client.Transport = ht
+	}
+
+	return client, nil
+}
+
+// headerTransport sets headers on the requests to particular hosts. Headers
+// are only set on plain HTTP requests if they are marked insecure, so that
+// falling back to HTTP, or being redirected to it, does not leak them.
+type headerTransport struct {
+	base    http.RoundTripper
+	headers map[string][]HTTPHeader // by host
+}
+
+func (t headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var hs []HTTPHeader
+	for _, h := range t.headers[req.URL.Hostname()] {
+		if req.URL.Scheme == "https" || h.Insecure {
+			hs = append(hs, h)
+		}
+	}
+	if len(hs) == 0 {
+		return t.base.RoundTrip(req)
+	}
+
+	// A RoundTripper must not modify the request, so set the headers on a copy.
+	r := new(http.Request)
+	*r = *req
+	r.Header = make(http.Header, len(req.Header)+len(hs))
+	for k, v := range req.Header {
+		r.Header[k] = v
+	}
+	for _, h := range hs {
+		r.Header.Set(h.Name, h.Value)
+	}
+	return t.base.RoundTrip(r)
+}
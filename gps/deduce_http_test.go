@@ -0,0 +1,180 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/dep/internal/test"
+)
+
+func TestNewHTTPClientRejectsBadConfig(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempFile("empty.pem", "")
+	missing := filepath.Join(filepath.Dir(h.Path("empty.pem")), "missing.pem")
+
+	bad := []HTTPConfig{
+		{CAFile: missing},
+		{CAFile: h.Path("empty.pem")},
+		{CertFile: missing},
+		{KeyFile: h.Path("empty.pem")},
+		{Proxy: "proxy.corp:3128"},
+		{Headers: []HTTPHeader{{Name: "Authorization", Value: "Bearer t0ken"}}},
+	}
+	for _, c := range bad {
+		if _, err := newHTTPClient(c); err == nil {
+			t.Errorf("expected config %+v to be rejected", c)
+		}
+	}
+
+	if client, err := newHTTPClient(HTTPConfig{}); err != nil || client != http.DefaultClient {
+		t.Errorf("expected the zero config to use the default client, got %v, %v", client, err)
+	}
+}
+
+func TestHTTPClientMetadata(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempDir("tls")
+
+	// The server only answers clients with a certificate, and with the token.
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer t0ken" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintf(w, `<meta name="go-import" content="%s git https://git.corp/vanity">`, r.Host+"/vanity")
+	}))
+	srv.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	srv.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+
+	caFile := filepath.Join(h.Path("tls"), "ca.pem")
+	writePEM(t, caFile, "CERTIFICATE", srv.Certificate().Raw)
+	certFile, keyFile := writeClientCert(t, h.Path("tls"))
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := u.Host + "/vanity/pkg"
+
+	client, err := newHTTPClient(HTTPConfig{CAFile: caFile, Timeout: 10 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := getMetadata(context.Background(), client, path, "https"); err == nil {
+		t.Error("expected the metadata request without a client certificate to fail")
+	}
+
+	client, err = newHTTPClient(HTTPConfig{
+		CAFile:   caFile,
+		CertFile: certFile,
+		KeyFile:  keyFile,
+		Timeout:  10 * time.Second,
+		Headers:  []HTTPHeader{{Host: u.Hostname(), Name: "Authorization", Value: "Bearer t0ken"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, vcs, reporoot, err := getMetadata(context.Background(), client, path, "https")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root != u.Host+"/vanity" || vcs != "git" || reporoot != "https://git.corp/vanity" {
+		t.Errorf("unexpected metadata: %q, %q, %q", root, vcs, reporoot)
+	}
+}
+
+func TestHTTPClientHeadersNotSentOverHTTP(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("Authorization")
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, insecure := range []bool{false, true} {
+		client, err := newHTTPClient(HTTPConfig{
+			Headers: []HTTPHeader{{Host: u.Hostname(), Name: "Authorization", Value: "Bearer t0ken", Insecure: insecure}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got = ""
+		resp, err := client.Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+
+		want := ""
+		if insecure {
+			want = "Bearer t0ken"
+		}
+		if got != want {
+			t.Errorf("expected the HTTP request with insecure=%v to have the header %q, got %q", insecure, want, got)
+		}
+	}
+}
+
+// writeClientCert writes a self-signed client certificate and its key to dir,
+// and returns their paths.
+func writeClientCert(t *testing.T, dir string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "dep"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile = filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+	return certFile, keyFile
+}
+
+func writePEM(t *testing.T, path, typ string, der []byte) {
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
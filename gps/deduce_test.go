@@ -9,8 +9,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"net/http"
 	"net/url"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -390,6 +393,92 @@ var pathDeductionFixtures = map[string][]pathDeductionFixture{
 			},
 		},
 	},
+	"gitlab": {
+		{
+			in:   "gitlab.com/group/repo",
+			root: "gitlab.com/group/repo",
+			mb: maybeSources{
+				maybeGitSource{url: mkurl("https://gitlab.com/group/repo")},
+				maybeGitSource{url: mkurl("ssh://git@gitlab.com/group/repo")},
+			},
+		},
+		{
+			// Without checking upstream, the deepest root is assumed.
+			in:   "gitlab.com/group/sub/repo",
+			root: "gitlab.com/group/sub/repo",
+			mb: maybeSources{
+				maybeGitSource{url: mkurl("https://gitlab.com/group/sub/repo")},
+				maybeGitSource{url: mkurl("ssh://git@gitlab.com/group/sub/repo")},
+			},
+		},
+		{
+			in:   "gitlab.com/group/sub/sub2/repo.git/pkg",
+			root: "gitlab.com/group/sub/sub2/repo.git",
+			mb: maybeSources{
+				maybeGitSource{url: mkurl("https://gitlab.com/group/sub/sub2/repo.git")},
+				maybeGitSource{url: mkurl("ssh://git@gitlab.com/group/sub/sub2/repo.git")},
+			},
+		},
+		{
+			in:   "ssh://git@gitlab.com/group/repo",
+			root: "gitlab.com/group/repo",
+			mb: maybeSources{
+				maybeGitSource{url: mkurl("ssh://git@gitlab.com/group/repo")},
+			},
+		},
+		{
+			in:     "ssh://someuser@gitlab.com/group/repo",
+			root:   "gitlab.com/group/repo",
+			srcerr: errors.New("gitlab.com ssh must be accessed via the 'git' user; someuser was provided"),
+		},
+		{
+			in:   "gitlab.com/group",
+			rerr: errors.New("gitlab.com/group is not a valid path for a source on gitlab.com"),
+		},
+	},
+	"gitea": {
+		{
+			in:   "gitea.com/owner/repo/pkg",
+			root: "gitea.com/owner/repo",
+			mb: maybeSources{
+				maybeGitSource{url: mkurl("https://gitea.com/owner/repo")},
+				maybeGitSource{url: mkurl("ssh://git@gitea.com/owner/repo")},
+			},
+		},
+		{
+			in:   "https://codeberg.org/owner/repo",
+			root: "codeberg.org/owner/repo",
+			mb: maybeSources{
+				maybeGitSource{url: mkurl("https://codeberg.org/owner/repo")},
+			},
+		},
+		{
+			in:   "codeberg.org/owner",
+			rerr: errors.New("codeberg.org/owner is not a valid path for a source on a Gitea host"),
+		},
+	},
+	"sourcehut": {
+		{
+			in:   "git.sr.ht/~user/repo/pkg",
+			root: "git.sr.ht/~user/repo",
+			mb: maybeSources{
+				maybeGitSource{url: mkurl("https://git.sr.ht/~user/repo")},
+				maybeGitSource{url: mkurl("ssh://git@git.sr.ht/~user/repo")},
+			},
+		},
+		{
+			in:   "hg.sr.ht/~user/repo",
+			root: "hg.sr.ht/~user/repo",
+			mb: maybeSources{
+				maybeHgSource{url: mkurl("https://hg.sr.ht/~user/repo")},
+				maybeHgSource{url: mkurl("ssh://hg@hg.sr.ht/~user/repo")},
+			},
+		},
+		{
+			in:   "git.sr.ht/user/repo",
+			rerr: errors.New("git.sr.ht/user/repo is not a valid path for a source on sr.ht"),
+		},
+	},
 	"vcsext": {
 		// VCS extension-based syntax
 		{
@@ -431,6 +520,16 @@ var pathDeductionFixtures = map[string][]pathDeductionFixture{
 				maybeHgSource{url: mkurl("http://foo-bar.com/baz.hg")},
 			},
 		},
+		{
+			in:   "foobar.com/baz.svn/sub",
+			root: "foobar.com/baz.svn",
+			mb: maybeSources{
+				maybeSvnSource{url: mkurl("https://foobar.com/baz.svn")},
+				maybeSvnSource{url: mkurl("http://foobar.com/baz.svn")},
+				maybeSvnSource{url: mkurl("svn://foobar.com/baz.svn")},
+				maybeSvnSource{url: mkurl("svn+ssh://foobar.com/baz.svn")},
+			},
+		},
 		{
 			in:   "git@foobar.com:baz.git",
 			root: "foobar.com/baz.git",
@@ -459,6 +558,13 @@ var pathDeductionFixtures = map[string][]pathDeductionFixture{
 				maybeHgSource{url: mkurl("https://foobar.com/baz.hg")},
 			},
 		},
+		{
+			in:   "svn://foobar.com/baz.svn",
+			root: "foobar.com/baz.svn",
+			mb: maybeSources{
+				maybeSvnSource{url: mkurl("svn://foobar.com/baz.svn")},
+			},
+		},
 		{
 			in:     "git://foobar.com/baz.hg",
 			root:   "foobar.com/baz.hg",
@@ -524,6 +630,12 @@ func TestDeduceFromPath(t *testing.T) {
 				deducer = launchpadGitDeducer{regexp: glpRegex}
 			case "apache":
 				deducer = apacheDeducer{regexp: apacheRegex}
+			case "gitlab":
+				deducer = gitlabDeducer{regexp: glRegex}
+			case "gitea":
+				deducer = giteaDeducer{regexp: giteaRegex}
+			case "sourcehut":
+				deducer = sourcehutDeducer{regexp: srhtRegex}
 			case "vcsext":
 				deducer = vcsExtensionDeducer{regexp: vcsExtensionRegex}
 			default:
@@ -603,6 +715,108 @@ func TestDeduceFromPath(t *testing.T) {
 	t.Run("second", runSet)
 }
 
+// metadataTransport serves go get metadata naming the roots it maps import
+// paths to, and 404s for the others.
+type metadataTransport map[string]string
+
+func (mt metadataTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp := &http.Response{StatusCode: http.StatusNotFound, Header: make(http.Header), Request: req}
+	body := "not found"
+	if root, has := mt[req.URL.Host+req.URL.Path]; has {
+		resp.StatusCode = http.StatusOK
+		body = fmt.Sprintf(`<meta name="go-import" content="%s git https://%s.git">`, root, root)
+	}
+	resp.Body = ioutil.NopCloser(strings.NewReader(body))
+	return resp, nil
+}
+
+func TestDeduceNestedRoot(t *testing.T) {
+	ctx := context.Background()
+	dc := newDeductionCoordinator(newSupervisor(ctx))
+	dc.client = &http.Client{Transport: metadataTransport{
+		"gitlab.com/group/sub/repo/pkg": "gitlab.com/group/sub/repo",
+		"gitlab.com/hidden/sub/pkg":     "gitlab.com/hidden/sub",
+	}}
+	var probed []string
+	dc.probe = func(ctx context.Context, mb maybeSource) bool {
+		u := mb.URL().String()
+		probed = append(probed, u)
+		return u == "ssh://git@gitlab.com/group/sub/sub2/repo"
+	}
+
+	// The root named by the metadata is used without checking upstream.
+	pd, err := dc.deduceRootPath(ctx, "gitlab.com/group/sub/repo/pkg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pd.root != "gitlab.com/group/sub/repo" || len(probed) != 0 {
+		t.Errorf("expected the root named by the metadata, got root %q after probing %v", pd.root, probed)
+	}
+
+	// Otherwise, https is checked for every root before ssh is.
+	pd, err = dc.deduceRootPath(ctx, "gitlab.com/group/sub/sub2/repo/pkg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pd.root != "gitlab.com/group/sub/sub2/repo" {
+		t.Errorf("expected the deepest existing root, got %q", pd.root)
+	}
+	want := []string{
+		"https://gitlab.com/group/sub/sub2/repo/pkg",
+		"https://gitlab.com/group/sub/sub2/repo",
+		"https://gitlab.com/group/sub/sub2",
+		"https://gitlab.com/group/sub",
+		"ssh://git@gitlab.com/group/sub/sub2/repo/pkg",
+		"ssh://git@gitlab.com/group/sub/sub2/repo",
+	}
+	if !reflect.DeepEqual(probed, want) {
+		t.Errorf("unexpected probes:\n\t(GOT): %v\n\t(WNT): %v", probed, want)
+	}
+
+	// Other packages in the project are deduced without checking upstream.
+	probed = nil
+	if pd, err = dc.deduceRootPath(ctx, "gitlab.com/group/sub/sub2/repo/other"); err != nil {
+		t.Fatal(err)
+	}
+	if pd.root != "gitlab.com/group/sub/sub2/repo" || len(probed) != 0 {
+		t.Errorf("expected a cached deduction, got root %q after probing %v", pd.root, probed)
+	}
+
+	// If no root exists, the shallowest is used, so that the other packages
+	// beneath it are not checked again.
+	if pd, err = dc.deduceRootPath(ctx, "gitlab.com/other/sub/pkg"); err != nil {
+		t.Fatal(err)
+	}
+	if pd.root != "gitlab.com/other/sub" {
+		t.Errorf("expected the shallowest root, got %q", pd.root)
+	}
+	probed = nil
+	if pd, err = dc.deduceRootPath(ctx, "gitlab.com/other/sub/pkg2"); err != nil {
+		t.Fatal(err)
+	}
+	if pd.root != "gitlab.com/other/sub" || len(probed) != 0 {
+		t.Errorf("expected a cached deduction, got root %q after probing %v", pd.root, probed)
+	}
+
+	// Metadata naming the shallowest root, as for private projects, is only
+	// used if no root exists.
+	if pd, err = dc.deduceRootPath(ctx, "gitlab.com/hidden/sub/pkg"); err != nil {
+		t.Fatal(err)
+	}
+	if pd.root != "gitlab.com/hidden/sub" {
+		t.Errorf("expected the root named by the metadata, got %q", pd.root)
+	}
+
+	dc = newDeductionCoordinator(newSupervisor(ctx))
+	dc.offline = true
+	if _, err = dc.deduceRootPath(ctx, "gitlab.com/group/sub/pkg"); err == nil {
+		t.Error("expected an error for an ambiguous root while offline")
+	}
+	if pd, err = dc.deduceRootPath(ctx, "gitlab.com/group/sub/repo.git/pkg"); err != nil {
+		t.Errorf("unexpected error for a marked root while offline: %s", err)
+	}
+}
+
 func TestVanityDeduction(t *testing.T) {
 	if testing.Short() {
 		t.Skip("Skipping slow test in short mode")
@@ -679,3 +893,42 @@ func TestVanityDeductionSchemeMismatch(t *testing.T) {
 		t.Error("should have errored on scheme mismatch between input and go-get metadata")
 	}
 }
+
+func TestDeduceRootPathPartialClone(t *testing.T) {
+	ctx := context.Background()
+	dc := newDeductionCoordinator(newSupervisor(ctx))
+	dc.partialClone = true
+
+	pd, err := dc.deduceRootPath(ctx, "github.com/golang/dep/gps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mb := range pd.mb {
+		if m, ok := mb.(maybeGitSource); !ok || !m.partial {
+			t.Errorf("expected only partially cloned git sources, got %#v", mb)
+		}
+	}
+
+	pd, err = dc.deduceRootPath(ctx, "launchpad.net/govcstestbzrrepo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mb := range pd.mb {
+		if _, ok := mb.(maybeBzrSource); !ok {
+			t.Errorf("expected only bzr sources, got %v", pd.mb)
+		}
+	}
+
+	// Offline, partial clones left by earlier runs must not fetch anything.
+	dc = newDeductionCoordinator(newSupervisor(ctx))
+	dc.offline = true
+	pd, err = dc.deduceRootPath(ctx, "github.com/golang/dep/gps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, mb := range pd.mb {
+		if m, ok := mb.(maybeGitSource); !ok || m.partial || !m.offline {
+			t.Errorf("expected only offline git sources that are not cloned partially, got %#v", mb)
+		}
+	}
+}
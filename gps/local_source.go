@@ -0,0 +1,303 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/golang/dep/gps/pkgtree"
+	"github.com/pkg/errors"
+)
+
+// localSourceBranch is the name of the only version of a local source.
+const localSourceBranch = "local"
+
+// localSourceScheme is the scheme of the URLs returned by LocalSource. It is
+// not "file", so that repositories given as file URLs remain VCS sources.
+const localSourceScheme = "local"
+
+// LocalSource returns the source of a project that is the working tree in
+// dir, which must be an absolute path. Rather than fetching the project, a
+// SourceManager reads such a source from dir as it is; it has a single
+// version, the branch "local", whose revision is a digest of the tree.
+func LocalSource(dir string) string {
+	p := filepath.ToSlash(dir)
+	if !strings.HasPrefix(p, "/") {
+		// A Windows path, beginning with a drive letter.
+		p = "/" + p
+	}
+	return (&url.URL{Scheme: localSourceScheme, Path: p}).String()
+}
+
+// IsLocalSource reports whether source was returned by LocalSource, and if
+// so, returns the directory it is for.
+func IsLocalSource(source string) (string, bool) {
+	if !strings.HasPrefix(source, localSourceScheme+"://") {
+		return "", false
+	}
+	u, err := url.Parse(source)
+	if err != nil || (u.Host != "" && u.Host != "localhost") || u.Path == "" {
+		return "", false
+	}
+	p := u.Path
+	if runtime.GOOS == "windows" {
+		p = strings.TrimPrefix(p, "/")
+	}
+	return filepath.FromSlash(p), true
+}
+
+func (dc *deductionCoordinator) deduceLocalSource(path string) (pathDeduction, error) {
+	dir, ok := IsLocalSource(path)
+	if !ok || !filepath.IsAbs(dir) {
+		return pathDeduction{}, errors.Errorf("%q is not a local source URL of an absolute path", path)
+	}
+	u, _ := url.Parse(path)
+	return pathDeduction{
+		root: path,
+		mb:   maybeSources{maybeLocalSource{url: u, dir: dir, link: dc.linkLocal}},
+	}, nil
+}
+
+type maybeLocalSource struct {
+	url *url.URL
+	dir string
+	// link causes the project to be exported as a symlink to dir.
+	link bool
+}
+
+func (m maybeLocalSource) try(ctx context.Context, cachedir string) (source, error) {
+	if fi, err := os.Stat(m.dir); err != nil || !fi.IsDir() {
+		return nil, errors.Errorf("local source %s is not a directory", m.dir)
+	}
+	return &localSource{maybeLocalSource: m}, nil
+}
+
+func (m maybeLocalSource) URL() *url.URL {
+	return m.url
+}
+
+func (m maybeLocalSource) String() string {
+	return fmt.Sprintf("%T: %s", m, m.dir)
+}
+
+// localSource is a source that is a working tree on disk, used as it is
+// rather than fetched.
+//
+// As the tree may change at any time, it has a single version, whose
+// revision is a digest of the tree when the versions were listed. Any
+// revision is considered present, as there is only the tree to use.
+type localSource struct {
+	maybeLocalSource
+}
+
+func (*localSource) sourceType() string {
+	return "local"
+}
+
+func (s *localSource) existsLocally(ctx context.Context) bool {
+	fi, err := os.Stat(s.dir)
+	return err == nil && fi.IsDir()
+}
+
+func (s *localSource) existsUpstream(ctx context.Context) bool {
+	return s.existsLocally(ctx)
+}
+
+func (*localSource) existsCallsListVersions() bool {
+	return false
+}
+
+func (*localSource) listVersionsRequiresLocal() bool {
+	return false
+}
+
+func (s *localSource) upstreamURL() string {
+	return s.url.String()
+}
+
+func (s *localSource) localPath() string {
+	return s.dir
+}
+
+func (s *localSource) initLocal(ctx context.Context) error {
+	if !s.existsLocally(ctx) {
+		return errors.Errorf("local source %s is not a directory", s.dir)
+	}
+	return nil
+}
+
+func (s *localSource) updateLocal(ctx context.Context) error {
+	return s.initLocal(ctx)
+}
+
+func (*localSource) maybeClean(ctx context.Context) error {
+	return nil
+}
+
+func (s *localSource) listVersions(ctx context.Context) ([]PairedVersion, error) {
+	r, err := localTreeDigest(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	return []PairedVersion{NewBranch(localSourceBranch).Pair(r)}, nil
+}
+
+func (s *localSource) listLocalVersions(ctx context.Context) ([]PairedVersion, error) {
+	return s.listVersions(ctx)
+}
+
+func (*localSource) revisionPresentIn(r Revision) (bool, error) {
+	return true, nil
+}
+
+// disambiguateRevision accepts only the revision of the tree as it is now.
+func (s *localSource) disambiguateRevision(ctx context.Context, r Revision) (Revision, error) {
+	cur, err := localTreeDigest(s.dir)
+	if err != nil {
+		return "", err
+	}
+	if r != cur {
+		return "", errors.Errorf("%s is not the current revision of the local source %s, %s", r, s.dir, cur)
+	}
+	return r, nil
+}
+
+func (s *localSource) getManifestAndLock(ctx context.Context, pr ProjectRoot, r Revision, an ProjectAnalyzer) (Manifest, Lock, error) {
+	m, l, err := an.DeriveManifestAndLock(s.dir, pr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if l != nil && l != Lock(nil) {
+		l = prepLock(l)
+	}
+
+	return prepManifest(m), l, nil
+}
+
+func (s *localSource) listPackages(ctx context.Context, pr ProjectRoot, r Revision) (pkgtree.PackageTree, error) {
+	return pkgtree.ListPackages(s.dir, string(pr))
+}
+
+// exportRevisionTo copies the tree to the directory to, leaving out the
+// directories of version control systems.
+func (s *localSource) exportRevisionTo(ctx context.Context, r Revision, to string) error {
+	return filepath.Walk(s.dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(to, rel)
+
+		switch {
+		case fi.IsDir():
+			if isVCSDir(fi.Name()) && path != s.dir {
+				return filepath.SkipDir
+			}
+			return os.MkdirAll(target, 0777)
+		case fi.Mode()&os.ModeSymlink != 0:
+			dest, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(dest, target)
+		case fi.Mode().IsRegular():
+			return copyLocalFile(path, target, fi.Mode())
+		}
+		return nil
+	})
+}
+
+// exportPrunedRevisionTo exports the tree to the directory to, pruned, or,
+// if the source is linked, as a symlink to the tree, which is not pruned.
+func (s *localSource) exportPrunedRevisionTo(ctx context.Context, r Revision, pkgs []string, prune PruneOptions, to string) error {
+	if err := os.MkdirAll(filepath.Dir(to), 0777); err != nil {
+		return err
+	}
+	if s.link {
+		return os.Symlink(s.dir, to)
+	}
+
+	if err := s.exportRevisionTo(ctx, r, to); err != nil {
+		return err
+	}
+	return PruneProject(to, NewLockedProject(ProjectIdentifier{}, r, pkgs), prune)
+}
+
+func copyLocalFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func isVCSDir(name string) bool {
+	switch name {
+	case ".git", ".hg", ".bzr", ".svn":
+		return true
+	}
+	return false
+}
+
+// localTreeDigest returns a digest of the names and contents of the files in
+// dir, leaving out the directories of version control systems.
+func localTreeDigest(dir string) (Revision, error) {
+	h := sha256.New()
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			if isVCSDir(fi.Name()) && path != dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		io.WriteString(h, filepath.ToSlash(rel)+"\x00"+strconv.FormatInt(fi.Size(), 10)+"\x00")
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(h, f)
+		return err
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read the local source %s", dir)
+	}
+	return Revision(hex.EncodeToString(h.Sum(nil))), nil
+}
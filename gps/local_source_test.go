@@ -0,0 +1,143 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/golang/dep/internal/test"
+)
+
+func newLocalSourceTestSM(t *testing.T, h *test.Helper, link bool) *SourceMgr {
+	sm, err := NewSourceManager(SourceManagerConfig{
+		Cachedir:  h.Path("cache"),
+		Logger:    log.New(test.Writer{TB: t}, "", 0),
+		LinkLocal: link,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sm
+}
+
+func TestLocalSource(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempDir("cache")
+	h.TempFile("lib/lib.go", "package lib\n")
+	h.TempFile("lib/sub/sub.go", "package sub\n")
+	h.TempFile("lib/unused/unused.go", "package unused\n")
+	h.TempFile("lib/.git/HEAD", "ref: refs/heads/master\n")
+
+	id := ProjectIdentifier{ProjectRoot: "github.com/corp/lib", Source: LocalSource(h.Path("lib"))}
+	if dir, ok := IsLocalSource(id.Source); !ok || dir != h.Path("lib") {
+		t.Fatalf("expected %s to be the local source of %s, got %q, %v", id.Source, h.Path("lib"), dir, ok)
+	}
+
+	sm := newLocalSourceTestSM(t, h, false)
+	vlist, err := sm.ListVersions(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vlist) != 1 || vlist[0].String() != localSourceBranch || vlist[0].Type() != IsBranch {
+		t.Fatalf("expected only the branch %q, got %v", localSourceBranch, vlist)
+	}
+	v := vlist[0]
+
+	ptree, err := sm.ListPackages(id, v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ptree.Packages) != 3 {
+		t.Errorf("expected the 3 packages of the working tree, got %v", ptree.Packages)
+	}
+
+	to := filepath.Join(h.Path("."), "vendor", "github.com", "corp", "lib")
+	lp := NewLockedProject(id, v, []string{".", "sub"})
+	if err := sm.ExportPrunedProject(context.Background(), lp, PruneNestedVendorDirs|PruneUnusedPackages, to); err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]bool{
+		"lib.go":           true,
+		"sub/sub.go":       true,
+		"unused/unused.go": false,
+		".git/HEAD":        false,
+	} {
+		_, err := os.Stat(filepath.Join(to, filepath.FromSlash(path)))
+		if got := err == nil; got != want {
+			t.Errorf("expected %s to exist in the export: %v, got %v", path, want, got)
+		}
+	}
+	sm.Release()
+
+	// A change to the tree changes its revision.
+	h.TempFile("lib/lib.go", "package lib\n\nconst X = 1\n")
+	sm = newLocalSourceTestSM(t, h, true)
+	defer sm.Release()
+	vlist, err = sm.ListVersions(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vlist) != 1 || vlist[0].Revision() == v.(PairedVersion).Revision() {
+		t.Errorf("expected a new revision after changing the tree, got %v", vlist)
+	}
+
+	// Linked, the export is a symlink to the tree.
+	linked := filepath.Join(h.Path("."), "linked", "lib")
+	if err := sm.ExportPrunedProject(context.Background(), NewLockedProject(id, vlist[0], []string{"."}), PruneUnusedPackages, linked); err != nil {
+		t.Fatal(err)
+	}
+	if dest, err := os.Readlink(linked); err != nil || dest != h.Path("lib") {
+		t.Errorf("expected %s to link to %s, got %q, %v", linked, h.Path("lib"), dest, err)
+	}
+	if _, err := os.Stat(h.Path("lib/unused/unused.go")); err != nil {
+		t.Errorf("expected the linked tree not to be pruned: %v", err)
+	}
+}
+
+func TestLocalSourceMissing(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempDir("cache")
+
+	sm := newLocalSourceTestSM(t, h, false)
+	defer sm.Release()
+	id := ProjectIdentifier{ProjectRoot: "github.com/corp/lib", Source: LocalSource(filepath.Join(h.Path("."), "missing"))}
+	if _, err := sm.ListVersions(id); err == nil {
+		t.Error("expected a missing directory to be an error")
+	}
+	if _, err := sm.ListVersions(ProjectIdentifier{ProjectRoot: "github.com/corp/lib", Source: "local://relative/lib"}); err == nil {
+		t.Error("expected a local source URL with a host to be an error")
+	}
+}
+
+func TestFileURLsAreNotLocalSources(t *testing.T) {
+	// Only overrides with a path make local sources; a file URL, even of a
+	// working tree, is left to the VCS sources.
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempFile("lib/lib.go", "package lib\n")
+
+	dc := newDeductionCoordinator(nil)
+	u := "file://" + filepath.ToSlash(h.Path("lib"))
+	if pd, err := dc.deduceKnownPaths(context.Background(), u); err == nil {
+		for _, mb := range pd.mb {
+			if _, ok := mb.(maybeLocalSource); ok {
+				t.Errorf("expected %s not to be deduced as a local source", u)
+			}
+		}
+	}
+	pd, err := dc.deduceKnownPaths(context.Background(), LocalSource(h.Path("lib")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := pd.mb[0].(maybeLocalSource); !ok || len(pd.mb) != 1 {
+		t.Errorf("expected only a local source, got %v", pd.mb)
+	}
+}
@@ -11,10 +11,13 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"os/exec"
 	"path"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"sort"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -1053,3 +1056,105 @@ func TestSupervisor(t *testing.T) {
 		return nil
 	})
 }
+
+func TestOfflineSourceMgr(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempDir("upstream")
+	h.TempDir("cache")
+	upstream := h.Path("upstream")
+	cachedir := h.Path("cache")
+
+	git := func(dir string, args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=dep", "GIT_AUTHOR_EMAIL=dep@example.com",
+			"GIT_COMMITTER_NAME=dep", "GIT_COMMITTER_EMAIL=dep@example.com")
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %s failed: %s: %s", strings.Join(args, " "), err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	git(upstream, "init", "-q")
+	h.TempFile("upstream/foo.go", "package foo\n")
+	git(upstream, "add", ".")
+	git(upstream, "commit", "-q", "-m", "initial")
+	git(upstream, "tag", "-a", "-m", "v1.0.0", "v1.0.0")
+	rev := Revision(git(upstream, "rev-parse", "HEAD"))
+	branch := git(upstream, "rev-parse", "--abbrev-ref", "HEAD")
+
+	// Seed the cache as if github.com/example/offline had been cloned.
+	const srcURL = "https://github.com/example/offline"
+	local := sourceCachePath(cachedir, srcURL)
+	git(cachedir, "clone", "-q", upstream, local)
+	git(local, "remote", "set-url", "origin", srcURL)
+
+	// Seed the cache with the go get metadata of a vanity import path, long
+	// since expired.
+	bc, err := newBoltCache(cachedir, 0, log.New(test.Writer{TB: t}, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	(&deductionCacheBolt{boltCache: bc}).setMetadata("example.org/cached", "example.org/cached", "git", srcURL)
+	if err := bc.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	sm, err := NewSourceManager(SourceManagerConfig{
+		Cachedir:          cachedir,
+		Logger:            log.New(test.Writer{TB: t}, "", 0),
+		Offline:           true,
+		DeductionCacheAge: time.Nanosecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Release()
+
+	id := mkPI("github.com/example/offline")
+	pvs, err := sm.ListVersions(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SortPairedForUpgrade(pvs)
+	want := []PairedVersion{
+		NewVersion("v1.0.0").Pair(rev),
+		newDefaultBranch(branch).Pair(rev),
+	}
+	if !reflect.DeepEqual(pvs, want) {
+		t.Errorf("unexpected versions:\n\t(GOT): %#v\n\t(WNT): %#v", pvs, want)
+	}
+
+	if _, err := sm.ListPackages(id, NewVersion("v1.0.0").Pair(rev)); err != nil {
+		t.Errorf("expected packages to be listed from the local repository, got %s", err)
+	}
+
+	missing := Revision("0123456789012345678901234567890123456789")
+	err = sm.ExportProject(context.Background(), id, missing, h.Path("."))
+	if oe, ok := err.(*OfflineError); !ok || oe.Revision != missing || oe.Source != srcURL {
+		t.Errorf("expected an offline error for the missing revision, got %#v", err)
+	}
+
+	_, err = sm.ListVersions(mkPI("github.com/example/uncached"))
+	if oe, ok := err.(*OfflineError); !ok || oe.Source != "https://github.com/example/uncached" {
+		t.Errorf("expected an offline error for the uncached source, got %#v", err)
+	}
+
+	_, err = sm.DeduceProjectRoot("example.org/vanity/pkg")
+	if oe, ok := err.(*OfflineError); !ok || oe.Source != "example.org/vanity/pkg" {
+		t.Errorf("expected an offline error for the go get metadata, got %#v", err)
+	}
+
+	root, err := sm.DeduceProjectRoot("example.org/cached/pkg")
+	if err != nil {
+		t.Errorf("expected the cached go get metadata to be used, got %s", err)
+	} else if root != "example.org/cached" {
+		t.Errorf("expected the cached root example.org/cached, got %s", root)
+	}
+}
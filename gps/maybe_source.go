@@ -45,6 +45,10 @@ func sourceCachePath(cacheDir, sourceURL string) string {
 
 type maybeGitSource struct {
 	url *url.URL
+	// partial causes the repository to be cloned as a partial clone.
+	partial bool
+	// offline forbids fetching the files missing from a partial clone.
+	offline bool
 }
 
 func (m maybeGitSource) try(ctx context.Context, cachedir string) (source, error) {
@@ -62,7 +66,7 @@ func (m maybeGitSource) try(ctx context.Context, cachedir string) (source, error
 
 	return &gitSource{
 		baseVCSSource: baseVCSSource{
-			repo: &gitRepo{r},
+			repo: &gitRepo{GitRepo: r, partial: m.partial, offline: m.offline},
 		},
 	}, nil
 }
@@ -87,6 +91,10 @@ type maybeGopkginSource struct {
 	major uint64
 	// whether or not the source package is "unstable"
 	unstable bool
+	// partial causes the repository to be cloned as a partial clone.
+	partial bool
+	// offline forbids fetching the files missing from a partial clone.
+	offline bool
 }
 
 func (m maybeGopkginSource) try(ctx context.Context, cachedir string) (source, error) {
@@ -109,7 +117,7 @@ func (m maybeGopkginSource) try(ctx context.Context, cachedir string) (source, e
 	return &gopkginSource{
 		gitSource: gitSource{
 			baseVCSSource: baseVCSSource{
-				repo: &gitRepo{r},
+				repo: &gitRepo{GitRepo: r, partial: m.partial, offline: m.offline},
 			},
 		},
 		major:    m.major,
@@ -193,6 +201,38 @@ func (m maybeHgSource) String() string {
 	return fmt.Sprintf("%T: %s", m, ufmt(m.url))
 }
 
+type maybeSvnSource struct {
+	url *url.URL
+}
+
+func (m maybeSvnSource) try(ctx context.Context, cachedir string) (source, error) {
+	ustr := m.url.String()
+	path := sourceCachePath(cachedir, ustr)
+
+	r, err := vcs.NewSvnRepo(ustr, path)
+	if err != nil {
+		os.RemoveAll(path)
+		r, err = vcs.NewSvnRepo(ustr, path)
+		if err != nil {
+			return nil, unwrapVcsErr(err)
+		}
+	}
+
+	return &svnSource{
+		baseVCSSource: baseVCSSource{
+			repo: &svnRepo{r},
+		},
+	}, nil
+}
+
+func (m maybeSvnSource) URL() *url.URL {
+	return m.url
+}
+
+func (m maybeSvnSource) String() string {
+	return fmt.Sprintf("%T: %s", m, ufmt(m.url))
+}
+
 // borrow from stdlib
 // more useful string for debugging than fmt's struct printer
 func ufmt(u *url.URL) string {
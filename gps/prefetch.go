@@ -0,0 +1,183 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import "sync"
+
+// defaultPrefetch is the number of concurrent requests that a solver makes to
+// prefetch data when SolveParameters.Prefetch is zero.
+const defaultPrefetch = 8
+
+// prefetchBacklog is the number of prefetch requests that may wait for a
+// worker. Requests beyond it are dropped, as the solver has likely moved on.
+const prefetchBacklog = 256
+
+// prefetchRequest is a request to fetch the versions of a project, if v is nil,
+// or else its manifest, lock and packages at v.
+type prefetchRequest struct {
+	id ProjectIdentifier
+	v  Version
+}
+
+// prefetchKey identifies prefetchRequests that would fetch the same data.
+type prefetchKey struct {
+	id  ProjectIdentifier
+	typ VersionType
+	v   string
+}
+
+// prefetcher fetches the data that a solver is likely to need soon in the
+// background, so that network round trips overlap, rather than happen one at
+// a time as the solver gets to each project. At most a fixed number of
+// requests are made at once.
+//
+// The data is only used to warm the SourceManager's caches; the solver asks
+// for it again when it needs it. Prefetching therefore only affects how long
+// the solver waits for the SourceManager, and never what it decides.
+//
+// A nil *prefetcher is valid, and prefetches nothing.
+type prefetcher struct {
+	sm SourceManager
+	an ProjectAnalyzer
+	// Whether the solver will try the oldest versions first.
+	down bool
+	n    int
+
+	reqs chan prefetchRequest
+	done chan struct{}
+
+	mu   sync.Mutex
+	seen map[prefetchKey]bool
+}
+
+// newPrefetcher returns a prefetcher that makes at most n requests to sm at
+// once, or nil if n is negative.
+func newPrefetcher(sm SourceManager, an ProjectAnalyzer, down bool, n int) *prefetcher {
+	if n < 0 {
+		return nil
+	}
+	if n == 0 {
+		n = defaultPrefetch
+	}
+	return &prefetcher{
+		sm:   sm,
+		an:   an,
+		down: down,
+		n:    n,
+		seen: make(map[prefetchKey]bool),
+	}
+}
+
+// start starts the workers that fetch requested data.
+func (p *prefetcher) start() {
+	if p == nil {
+		return
+	}
+
+	p.reqs = make(chan prefetchRequest, prefetchBacklog)
+	p.done = make(chan struct{})
+	for i := 0; i < p.n; i++ {
+		go p.work()
+	}
+}
+
+// stop discards the outstanding requests. Those in progress are left to
+// finish in the background, as the solver won't wait for data it no longer
+// needs.
+func (p *prefetcher) stop() {
+	if p == nil || p.done == nil {
+		return
+	}
+	close(p.done)
+}
+
+// stopped reports whether stop has been called.
+func (p *prefetcher) stopped() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// versions requests the list of id's versions, followed by the manifest and
+// packages of the version the solver is most likely to try first.
+func (p *prefetcher) versions(id ProjectIdentifier) {
+	p.request(prefetchRequest{id: id})
+}
+
+// atom requests the manifest, lock and packages of id at v.
+func (p *prefetcher) atom(id ProjectIdentifier, v Version) {
+	if v == nil {
+		return
+	}
+	p.request(prefetchRequest{id: id, v: v})
+}
+
+func (p *prefetcher) request(r prefetchRequest) {
+	if p == nil || p.reqs == nil || p.stopped() {
+		return
+	}
+
+	k := prefetchKey{id: r.id.normalize()}
+	if r.v != nil {
+		k.typ, k.v = r.v.Type(), r.v.String()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.seen[k] {
+		return
+	}
+
+	select {
+	case p.reqs <- r:
+		p.seen[k] = true
+	default:
+		// The backlog is full; drop the request rather than block the solver.
+	}
+}
+
+func (p *prefetcher) work() {
+	for {
+		select {
+		case <-p.done:
+			return
+		case r := <-p.reqs:
+			// Both cases may be ready at once, so check again rather than
+			// fetch for a solver that has returned.
+			if p.stopped() {
+				return
+			}
+			p.fetch(r)
+		}
+	}
+}
+
+// fetch makes the calls to the SourceManager for r. Their errors are ignored,
+// as the solver encounters them again if they matter.
+func (p *prefetcher) fetch(r prefetchRequest) {
+	if r.v == nil {
+		pvl, err := p.sm.ListVersions(r.id)
+		if err != nil || len(pvl) == 0 {
+			return
+		}
+
+		vl := hidePair(pvl)
+		if p.down {
+			SortForDowngrade(vl)
+		} else {
+			SortForUpgrade(vl)
+		}
+		p.atom(r.id, vl[0])
+		return
+	}
+
+	if _, _, err := p.sm.GetManifestAndLock(r.id, r.v, p.an); err != nil || p.stopped() {
+		return
+	}
+	p.sm.ListPackages(r.id, r.v)
+}
@@ -0,0 +1,171 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/dep/gps/pkgtree"
+)
+
+// countingSM records the calls that a prefetcher makes to a
+// depspecSourceManager, and the most it makes at once.
+type countingSM struct {
+	*depspecSourceManager
+	mu       sync.Mutex
+	cur, max int
+	calls    map[string]int
+	wg       sync.WaitGroup
+}
+
+func (sm *countingSM) call(name string) func() {
+	sm.mu.Lock()
+	sm.calls[name]++
+	sm.cur++
+	if sm.cur > sm.max {
+		sm.max = sm.cur
+	}
+	sm.mu.Unlock()
+
+	// Give other requests time to pile up.
+	time.Sleep(5 * time.Millisecond)
+	return func() {
+		sm.mu.Lock()
+		sm.cur--
+		sm.mu.Unlock()
+	}
+}
+
+func (sm *countingSM) ListVersions(id ProjectIdentifier) ([]PairedVersion, error) {
+	defer sm.call("versions " + string(id.ProjectRoot))()
+	return sm.depspecSourceManager.ListVersions(id)
+}
+
+func (sm *countingSM) GetManifestAndLock(id ProjectIdentifier, v Version, an ProjectAnalyzer) (Manifest, Lock, error) {
+	defer sm.call(fmt.Sprintf("manifest %s@%s", id.ProjectRoot, v))()
+	return sm.depspecSourceManager.GetManifestAndLock(id, v, an)
+}
+
+func (sm *countingSM) ListPackages(id ProjectIdentifier, v Version) (pkgtree.PackageTree, error) {
+	defer sm.wg.Done()
+	defer sm.call(fmt.Sprintf("packages %s@%s", id.ProjectRoot, v))()
+	return sm.depspecSourceManager.ListPackages(id, v)
+}
+
+func TestPrefetcher(t *testing.T) {
+	var ds []depspec
+	var ids []ProjectIdentifier
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("p%d", i)
+		ds = append(ds, mkDepspec(name+" 1.0.0"), mkDepspec(name+" 2.0.0"))
+		ids = append(ids, mkPI(name))
+	}
+	sm := &countingSM{
+		depspecSourceManager: newdepspecSM(ds, nil),
+		calls:                make(map[string]int),
+	}
+	sm.wg.Add(len(ids))
+
+	p := newPrefetcher(sm, naiveAnalyzer{}, false, 3)
+	p.start()
+	defer p.stop()
+	for i := 0; i < 2; i++ {
+		for _, id := range ids {
+			p.versions(id)
+		}
+	}
+	sm.wg.Wait()
+
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if sm.max > 3 {
+		t.Errorf("expected at most 3 concurrent requests, got %d", sm.max)
+	}
+	for _, id := range ids {
+		// Each project is fetched once, at the version the solver would try
+		// first.
+		for _, call := range []string{
+			"versions " + string(id.ProjectRoot),
+			fmt.Sprintf("manifest %s@2.0.0", id.ProjectRoot),
+			fmt.Sprintf("packages %s@2.0.0", id.ProjectRoot),
+		} {
+			if sm.calls[call] != 1 {
+				t.Errorf("expected 1 call to %s, got %d", call, sm.calls[call])
+			}
+		}
+	}
+}
+
+// blockingSM blocks each call to ListVersions until release is closed.
+type blockingSM struct {
+	*depspecSourceManager
+	started chan struct{}
+	release chan struct{}
+	mu      sync.Mutex
+	calls   int
+}
+
+func (sm *blockingSM) ListVersions(id ProjectIdentifier) ([]PairedVersion, error) {
+	sm.mu.Lock()
+	sm.calls++
+	sm.mu.Unlock()
+	sm.started <- struct{}{}
+	<-sm.release
+	return sm.depspecSourceManager.ListVersions(id)
+}
+
+func TestPrefetcherStop(t *testing.T) {
+	var ds []depspec
+	var ids []ProjectIdentifier
+	for i := 0; i < 10; i++ {
+		name := fmt.Sprintf("p%d", i)
+		ds = append(ds, mkDepspec(name+" 1.0.0"))
+		ids = append(ids, mkPI(name))
+	}
+	sm := &blockingSM{
+		depspecSourceManager: newdepspecSM(ds, nil),
+		started:              make(chan struct{}, len(ids)),
+		release:              make(chan struct{}),
+	}
+
+	p := newPrefetcher(sm, naiveAnalyzer{}, false, 1)
+	p.start()
+	for _, id := range ids[:len(ids)-1] {
+		p.versions(id)
+	}
+	<-sm.started
+	p.stop()
+
+	// Requests made once stopped are dropped.
+	p.versions(ids[len(ids)-1])
+	if n := len(p.reqs); n != len(ids)-2 {
+		t.Errorf("expected %d queued requests, got %d", len(ids)-2, n)
+	}
+
+	// The request in progress finishes, but nothing queued is fetched.
+	close(sm.release)
+	time.Sleep(50 * time.Millisecond)
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if sm.calls != 1 {
+		t.Errorf("expected only the request in progress to be fetched, got %d calls", sm.calls)
+	}
+}
+
+func TestPrefetcherDisabled(t *testing.T) {
+	if p := newPrefetcher(newdepspecSM(nil, nil), naiveAnalyzer{}, false, -1); p != nil {
+		t.Fatalf("expected a negative concurrency to disable prefetching, got %#v", p)
+	}
+
+	// A nil prefetcher silently does nothing.
+	var p *prefetcher
+	p.start()
+	p.versions(mkPI("foo"))
+	p.atom(mkPI("foo"), NewVersion("1.0.0"))
+	p.stop()
+}
@@ -0,0 +1,511 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/golang/dep/gps/pkgtree"
+	"github.com/golang/dep/internal/fs"
+	"github.com/pkg/errors"
+)
+
+// goProxyDirect is the entry of SourceManagerConfig.GoProxy that stands for
+// the sources deduced for import paths, rather than a proxy.
+const goProxyDirect = "direct"
+
+// newGoProxies validates the entries of SourceManagerConfig.GoProxy, and
+// returns the URLs of the proxies in order, with nil in place of "direct".
+func newGoProxies(entries []string) ([]*url.URL, error) {
+	var proxies []*url.URL
+	for _, e := range entries {
+		if e == goProxyDirect {
+			proxies = append(proxies, nil)
+			continue
+		}
+		u, err := url.Parse(e)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return nil, errors.Errorf("Go module proxy %q is neither %q nor an http or https URL", e, goProxyDirect)
+		}
+		u.Path = strings.TrimSuffix(u.Path, "/")
+		proxies = append(proxies, u)
+	}
+	return proxies, nil
+}
+
+// proxied returns the maybeSources from which to fetch the project with the
+// given root, given the maybeSources deduced for it and the configured proxies.
+func (dc *deductionCoordinator) proxied(root string, mb maybeSources) maybeSources {
+	if len(dc.proxies) == 0 {
+		return mb
+	}
+	for _, m := range mb {
+		switch m.(type) {
+		case maybeArchiveSource, maybeLocalSource:
+			// Archives and local directories are not modules.
+			return mb
+		}
+	}
+
+	pmb := make(maybeSources, 0, len(dc.proxies)+len(mb))
+	for _, p := range dc.proxies {
+		if p == nil {
+			pmb = append(pmb, mb...)
+			continue
+		}
+		pmb = append(pmb, maybeProxySource{proxy: p, module: root, client: dc.client})
+	}
+	return pmb
+}
+
+type maybeProxySource struct {
+	// The base URL of the proxy.
+	proxy *url.URL
+	// The module path, which is the project root.
+	module string
+	client *http.Client
+}
+
+func (m maybeProxySource) try(ctx context.Context, cachedir string) (source, error) {
+	u := m.URL()
+	return &proxySource{
+		url:    u,
+		module: m.module,
+		path:   sourceCachePath(cachedir, u.String()),
+		client: m.client,
+	}, nil
+}
+
+// URL returns the URL of the module on the proxy, under which its versions
+// are found.
+func (m maybeProxySource) URL() *url.URL {
+	u := *m.proxy
+	p := "/" + escapeModulePath(m.module)
+	// Keep the exclamation marks of the escaped path as they are, as the go
+	// command does.
+	u.RawPath = m.proxy.EscapedPath() + p
+	u.Path += p
+	return &u
+}
+
+func (m maybeProxySource) String() string {
+	return fmt.Sprintf("%T: %s (%s)", m, m.module, ufmt(m.proxy))
+}
+
+// proxySource is a source that fetches a module from a Go module proxy, using
+// the protocol described by "go help goproxy".
+//
+// The protocol identifies versions of a module by their semantic versions,
+// rather than by the revisions of the repository they came from, so each
+// version serves as its own revision. The revisions recorded in locks are
+// therefore versions, such as "v1.2.3" or a pseudo-version.
+//
+// The versions that are needed are downloaded and extracted in a directory
+// of their own under the local path, as published versions never change.
+type proxySource struct {
+	// The URL of the module on the proxy.
+	url    *url.URL
+	module string
+	// The local directory holding the extracted versions.
+	path   string
+	client *http.Client
+}
+
+// moduleVersionRegex matches the semantic versions that identify versions of
+// a module, pseudo-versions included: "v" and all three numbers are required.
+var moduleVersionRegex = regexp.MustCompile(`^v[0-9]+\.[0-9]+\.[0-9]+(-[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?(\+[0-9A-Za-z-]+(\.[0-9A-Za-z-]+)*)?$`)
+
+// revisionRegex matches the hexadecimal revisions of a repository.
+var revisionRegex = regexp.MustCompile(`^[0-9a-f]+$`)
+
+// checkModuleVersion returns an error if v is not a version of a module, and
+// so may not be used in the paths of local directories or in proxy URLs.
+func checkModuleVersion(module, v string) error {
+	if !moduleVersionRegex.MatchString(v) {
+		return errors.Errorf("%q is not a valid version of %s", v, module)
+	}
+	return nil
+}
+
+// proxyVersionInfo is the response to a proxy's .info and @latest requests.
+type proxyVersionInfo struct {
+	Version string
+}
+
+// proxyNotFoundError is returned for a proxy's response that a module or
+// version does not exist.
+type proxyNotFoundError struct {
+	url    string
+	status string
+}
+
+func (e *proxyNotFoundError) Error() string {
+	return fmt.Sprintf("%s: %s", e.url, e.status)
+}
+
+func (*proxySource) sourceType() string {
+	return "goproxy"
+}
+
+func (s *proxySource) existsLocally(ctx context.Context) bool {
+	_, err := os.Stat(s.path)
+	return err == nil
+}
+
+func (s *proxySource) existsUpstream(ctx context.Context) bool {
+	_, err := s.listVersions(ctx)
+	return err == nil
+}
+
+func (*proxySource) existsCallsListVersions() bool {
+	return true
+}
+
+func (*proxySource) listVersionsRequiresLocal() bool {
+	return false
+}
+
+func (s *proxySource) upstreamURL() string {
+	return s.url.String()
+}
+
+func (s *proxySource) localPath() string {
+	return s.path
+}
+
+func (s *proxySource) initLocal(ctx context.Context) error {
+	return os.MkdirAll(s.path, 0777)
+}
+
+// updateLocal does nothing, as versions are downloaded when they are needed,
+// and never change.
+func (s *proxySource) updateLocal(ctx context.Context) error {
+	return s.initLocal(ctx)
+}
+
+func (*proxySource) maybeClean(ctx context.Context) error {
+	return nil
+}
+
+// listVersions lists the versions that the proxy knows of. If there are none,
+// the module may still have a latest pseudo-version, which is listed instead.
+func (s *proxySource) listVersions(ctx context.Context) ([]PairedVersion, error) {
+	body, err := s.get(ctx, "@v/list")
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var vlist []PairedVersion
+	sc := bufio.NewScanner(body)
+	for sc.Scan() {
+		f := strings.Fields(sc.Text())
+		if len(f) == 0 || !moduleVersionRegex.MatchString(f[0]) {
+			continue
+		}
+		vlist = append(vlist, NewVersion(f[0]).Pair(Revision(f[0])))
+	}
+	if err := sc.Err(); err != nil {
+		return nil, errors.Wrapf(err, "failed to read the version list of %s", s.url)
+	}
+	if len(vlist) > 0 {
+		return vlist, nil
+	}
+
+	info, err := s.info(ctx, "@latest")
+	if err != nil {
+		if _, ok := err.(*proxyNotFoundError); ok {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return []PairedVersion{NewVersion(info.Version).Pair(Revision(info.Version))}, nil
+}
+
+// listLocalVersions lists the versions that have been downloaded.
+func (s *proxySource) listLocalVersions(ctx context.Context) ([]PairedVersion, error) {
+	fis, err := ioutil.ReadDir(s.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var vlist []PairedVersion
+	for _, fi := range fis {
+		if !fi.IsDir() || strings.HasPrefix(fi.Name(), ".") {
+			continue
+		}
+		v, ok := unescapeModulePath(fi.Name())
+		if !ok || !moduleVersionRegex.MatchString(v) {
+			continue
+		}
+		vlist = append(vlist, NewVersion(v).Pair(Revision(v)))
+	}
+	return vlist, nil
+}
+
+// revisionPresentIn reports whether the proxy has the version r, downloading
+// it if it has, so that it is then present locally.
+func (s *proxySource) revisionPresentIn(r Revision) (bool, error) {
+	if err := checkModuleVersion(s.module, string(r)); err != nil {
+		return false, err
+	}
+	_, err := s.versionDir(context.TODO(), r)
+	if _, ok := err.(*proxyNotFoundError); ok {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// disambiguateRevision asks the proxy for the canonical form of r, which may
+// also be a revision of the module's repository, if the proxy supports such
+// queries.
+func (s *proxySource) disambiguateRevision(ctx context.Context, r Revision) (Revision, error) {
+	if !moduleVersionRegex.MatchString(string(r)) && !revisionRegex.MatchString(string(r)) {
+		return "", errors.Errorf("%q is neither a version of %s nor a revision", r, s.module)
+	}
+	info, err := s.info(ctx, "@v/"+escapeModulePath(string(r))+".info")
+	if err != nil {
+		return "", err
+	}
+	return Revision(info.Version), nil
+}
+
+func (s *proxySource) getManifestAndLock(ctx context.Context, pr ProjectRoot, r Revision, an ProjectAnalyzer) (Manifest, Lock, error) {
+	dir, err := s.versionDir(ctx, r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m, l, err := an.DeriveManifestAndLock(dir, pr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if l != nil && l != Lock(nil) {
+		l = prepLock(l)
+	}
+
+	return prepManifest(m), l, nil
+}
+
+func (s *proxySource) listPackages(ctx context.Context, pr ProjectRoot, r Revision) (pkgtree.PackageTree, error) {
+	dir, err := s.versionDir(ctx, r)
+	if err != nil {
+		return pkgtree.PackageTree{}, err
+	}
+	return pkgtree.ListPackages(dir, string(pr))
+}
+
+func (s *proxySource) exportRevisionTo(ctx context.Context, r Revision, to string) error {
+	dir, err := s.versionDir(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	// Only make the parent dir, as CopyDir will balk on trying to write to an
+	// empty but existing dir.
+	if err := os.MkdirAll(filepath.Dir(to), 0777); err != nil {
+		return err
+	}
+	return fs.CopyDir(dir, to)
+}
+
+// versionDir returns the directory holding version r of the module,
+// downloading and extracting it first if necessary.
+func (s *proxySource) versionDir(ctx context.Context, r Revision) (string, error) {
+	v := string(r)
+	if err := checkModuleVersion(s.module, v); err != nil {
+		return "", err
+	}
+	dir := filepath.Join(s.path, escapeModulePath(v))
+	if _, err := os.Stat(dir); err == nil {
+		return dir, nil
+	}
+
+	if err := os.MkdirAll(s.path, 0777); err != nil {
+		return "", err
+	}
+	body, err := s.get(ctx, "@v/"+escapeModulePath(v)+".zip")
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+
+	// The zip must be read from a file, and is extracted to a temporary
+	// directory, so that an interrupted download leaves nothing behind.
+	zf, err := ioutil.TempFile(s.path, ".zip-")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(zf.Name())
+	defer zf.Close()
+	if _, err := io.Copy(zf, body); err != nil {
+		return "", errors.Wrapf(err, "failed to download %s@%s from %s", s.module, v, s.url)
+	}
+
+	tmp, err := ioutil.TempDir(s.path, ".extract-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+	if err := extractModuleZip(zf.Name(), s.module+"@"+v+"/", tmp); err != nil {
+		return "", errors.Wrapf(err, "failed to extract %s@%s from %s", s.module, v, s.url)
+	}
+	if err := fs.RenameWithFallback(tmp, dir); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// info requests the version information at elem, relative to the module's
+// URL.
+func (s *proxySource) info(ctx context.Context, elem string) (proxyVersionInfo, error) {
+	body, err := s.get(ctx, elem)
+	if err != nil {
+		return proxyVersionInfo{}, err
+	}
+	defer body.Close()
+
+	var info proxyVersionInfo
+	if err := json.NewDecoder(body).Decode(&info); err != nil {
+		return proxyVersionInfo{}, errors.Wrapf(err, "failed to decode the version info from %s/%s", s.url, elem)
+	}
+	if info.Version == "" {
+		return proxyVersionInfo{}, errors.Errorf("the version info from %s/%s has no version", s.url, elem)
+	}
+	if err := checkModuleVersion(s.module, info.Version); err != nil {
+		return proxyVersionInfo{}, errors.Wrapf(err, "bad version info from %s/%s", s.url, elem)
+	}
+	return info, nil
+}
+
+// get requests elem, relative to the module's URL, returning the body of a
+// successful response, or a *proxyNotFoundError if the proxy says that it does
+// not exist.
+func (s *proxySource) get(ctx context.Context, elem string) (io.ReadCloser, error) {
+	u := s.url.String() + "/" + elem
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to build HTTP request for URL %q", u)
+	}
+	req = addAuthFromNetrc(u, req)
+
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed HTTP request to URL %q", u)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.Body, nil
+	case http.StatusNotFound, http.StatusGone:
+		resp.Body.Close()
+		return nil, &proxyNotFoundError{url: u, status: resp.Status}
+	default:
+		resp.Body.Close()
+		return nil, errors.Errorf("failed HTTP request to URL %q: %s", u, resp.Status)
+	}
+}
+
+// extractModuleZip extracts the module zip file at name to dir. The paths of
+// all files in the zip must begin with prefix, which is removed.
+func extractModuleZip(name, prefix, dir string) error {
+	zr, err := zip.OpenReader(name)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	for _, f := range zr.File {
+		if !strings.HasPrefix(f.Name, prefix) {
+			return errors.Errorf("unexpected file %s, outside of %s", f.Name, prefix)
+		}
+		rel := strings.TrimPrefix(f.Name, prefix)
+		if rel == "" || strings.HasSuffix(rel, "/") {
+			continue
+		}
+		if !isSafeArchivePath(rel) {
+			return errors.Errorf("invalid file path %s", f.Name)
+		}
+
+		target, err := archiveTarget(dir, rel)
+		if err != nil {
+			return err
+		}
+		if err := extractZipFile(f, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractZipFile(f *zip.File, target string) error {
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// escapeModulePath escapes a module path or version for use in the URLs of a
+// proxy, and in file names, by replacing each upper case letter with an
+// exclamation mark followed by its lower case form.
+func escapeModulePath(p string) string {
+	var b strings.Builder
+	for _, r := range p {
+		if 'A' <= r && r <= 'Z' {
+			b.WriteByte('!')
+			r += 'a' - 'A'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// unescapeModulePath reverses escapeModulePath, and reports whether p was
+// validly escaped.
+func unescapeModulePath(p string) (string, bool) {
+	var b strings.Builder
+	bang := false
+	for _, r := range p {
+		if r == utf8.RuneError || ('A' <= r && r <= 'Z') {
+			return "", false
+		}
+		if bang {
+			if r < 'a' || r > 'z' {
+				return "", false
+			}
+			r -= 'a' - 'A'
+			bang = false
+		} else if r == '!' {
+			bang = true
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String(), !bang
+}
@@ -0,0 +1,237 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep/internal/test"
+)
+
+// newTestGoProxy starts a Go module proxy serving the versions of a module
+// with the given files, keyed by version and then by file name.
+func newTestGoProxy(t *testing.T, module string, versions map[string]map[string]string) *httptest.Server {
+	base := "/" + escapeModulePath(module) + "/@v/"
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, base) {
+			http.NotFound(w, r)
+			return
+		}
+		file := strings.TrimPrefix(r.URL.Path, base)
+		if file == "list" {
+			for v := range versions {
+				fmt.Fprintln(w, v)
+			}
+			return
+		}
+
+		ext := filepath.Ext(file)
+		v, _ := unescapeModulePath(strings.TrimSuffix(file, ext))
+		files, ok := versions[v]
+		if !ok {
+			http.Error(w, "not found: unknown revision "+v, http.StatusGone)
+			return
+		}
+		switch ext {
+		case ".info":
+			fmt.Fprintf(w, `{"Version":%q,"Time":"2019-01-01T00:00:00Z"}`, v)
+		case ".zip":
+			var buf bytes.Buffer
+			zw := zip.NewWriter(&buf)
+			for name, content := range files {
+				f, err := zw.Create(module + "@" + v + "/" + name)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				f.Write([]byte(content))
+			}
+			if err := zw.Close(); err != nil {
+				t.Error(err)
+				return
+			}
+			w.Write(buf.Bytes())
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+var testProxyVersions = map[string]map[string]string{
+	"v1.0.0": {
+		"go.mod": "module github.com/Foo/bar\n",
+		"bar.go": "package bar\n",
+	},
+	"v1.1.0": {
+		"go.mod":     "module github.com/Foo/bar\n",
+		"bar.go":     "package bar\n\nimport _ \"github.com/Foo/bar/baz\"\n",
+		"baz/baz.go": "package baz\n",
+	},
+}
+
+func TestProxySource(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempDir("cache")
+
+	srv := newTestGoProxy(t, "github.com/Foo/bar", testProxyVersions)
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	mb := maybeProxySource{proxy: u, module: "github.com/Foo/bar", client: http.DefaultClient}
+	if got, want := mb.URL().String(), srv.URL+"/github.com/!foo/bar"; got != want {
+		t.Errorf("expected the URL of the module to be %s, got %s", want, got)
+	}
+	src, err := mb.try(ctx, h.Path("cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	vlist, err := src.listVersions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SortPairedForUpgrade(vlist)
+	want := []PairedVersion{
+		NewVersion("v1.1.0").Pair("v1.1.0"),
+		NewVersion("v1.0.0").Pair("v1.0.0"),
+	}
+	if !reflect.DeepEqual(vlist, want) {
+		t.Errorf("unexpected versions:\n\t(GOT): %v\n\t(WNT): %v", vlist, want)
+	}
+
+	if r, err := src.disambiguateRevision(ctx, "v1.0.0"); err != nil || r != "v1.0.0" {
+		t.Errorf("expected v1.0.0 to be disambiguated to itself, got %q, %v", r, err)
+	}
+	if present, err := src.revisionPresentIn("v2.0.0"); err != nil || present {
+		t.Errorf("expected v2.0.0 not to be present, got %v, %v", present, err)
+	}
+
+	// Revisions that are not versions must not reach local paths or URLs.
+	for _, r := range []Revision{"../../x", "v1.0.0/../../x", "v1.0", "1.0.0", ""} {
+		if present, err := src.revisionPresentIn(r); err == nil || present {
+			t.Errorf("expected %q to be rejected, got %v, %v", r, present, err)
+		}
+		if _, err := src.disambiguateRevision(ctx, r); err == nil {
+			t.Errorf("expected %q not to be disambiguated", r)
+		}
+		if _, err := src.listPackages(ctx, "github.com/Foo/bar", r); err == nil {
+			t.Errorf("expected packages not to be listed for %q", r)
+		}
+	}
+
+	ptree, err := src.listPackages(ctx, "github.com/Foo/bar", "v1.1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pkgs []string
+	for ip := range ptree.Packages {
+		pkgs = append(pkgs, ip)
+	}
+	sort.Strings(pkgs)
+	if want := []string{"github.com/Foo/bar", "github.com/Foo/bar/baz"}; !reflect.DeepEqual(pkgs, want) {
+		t.Errorf("unexpected packages:\n\t(GOT): %v\n\t(WNT): %v", pkgs, want)
+	}
+
+	to := filepath.Join(h.Path("."), "export")
+	if err := src.exportRevisionTo(ctx, "v1.1.0", to); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := ioutil.ReadFile(filepath.Join(to, "baz", "baz.go")); err != nil || string(b) != "package baz\n" {
+		t.Errorf("expected the export to contain baz/baz.go, got %q, %v", b, err)
+	}
+
+	// Only the version that was used has been downloaded.
+	local, err := src.(localVersionLister).listLocalVersions(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []PairedVersion{NewVersion("v1.1.0").Pair("v1.1.0")}; !reflect.DeepEqual(local, want) {
+		t.Errorf("unexpected local versions:\n\t(GOT): %v\n\t(WNT): %v", local, want)
+	}
+}
+
+func TestSourceManagerGoProxy(t *testing.T) {
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempDir("cache")
+
+	srv := newTestGoProxy(t, "github.com/Foo/bar", testProxyVersions)
+	defer srv.Close()
+
+	if _, err := NewSourceManager(SourceManagerConfig{
+		Cachedir: h.Path("cache"),
+		GoProxy:  []string{"proxy.golang.org"},
+	}); err == nil {
+		t.Fatal("expected a proxy without a scheme to be rejected")
+	}
+
+	sm, err := NewSourceManager(SourceManagerConfig{
+		Cachedir: h.Path("cache"),
+		Logger:   log.New(test.Writer{TB: t}, "", 0),
+		GoProxy:  []string{srv.URL + "/", goProxyDirect},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sm.Release()
+
+	id := mkPI("github.com/Foo/bar")
+	vlist, err := sm.ListVersions(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vlist) != 2 {
+		t.Errorf("expected the 2 versions on the proxy, got %v", vlist)
+	}
+
+	ptree, err := sm.ListPackages(id, NewVersion("v1.0.0").Pair("v1.0.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, has := ptree.Packages["github.com/Foo/bar"]; !has || len(ptree.Packages) != 1 {
+		t.Errorf("expected v1.0.0 to have only the root package, got %v", ptree.Packages)
+	}
+}
+
+func TestEscapeModulePath(t *testing.T) {
+	for p, want := range map[string]string{
+		"github.com/golang/dep":             "github.com/golang/dep",
+		"github.com/BurntSushi/toml":        "github.com/!burnt!sushi/toml",
+		"v1.0.0-RC1":                        "v1.0.0-!r!c1",
+		"github.com/Azure/azure-sdk-for-go": "github.com/!azure/azure-sdk-for-go",
+	} {
+		got := escapeModulePath(p)
+		if got != want {
+			t.Errorf("escapeModulePath(%q) = %q, want %q", p, got, want)
+		}
+		if back, ok := unescapeModulePath(got); !ok || back != p {
+			t.Errorf("unescapeModulePath(%q) = %q, %v, want %q", got, back, ok, p)
+		}
+	}
+
+	for _, bad := range []string{"github.com/Foo/bar", "trailing!", "!!x", "!1"} {
+		if _, ok := unescapeModulePath(bad); ok {
+			t.Errorf("expected %q not to unescape", bad)
+		}
+	}
+}
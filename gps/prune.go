@@ -289,7 +289,7 @@ func collectUnusedPackagesFiles(fsState filesystemState, unusedPackages map[stri
 
 	for _, path := range fsState.files {
 		// Keep preserved files.
-		if isPreservedFile(filepath.Base(path)) {
+		if IsPreservedFile(filepath.Base(path)) {
 			continue
 		}
 
@@ -346,7 +346,7 @@ func pruneNonGoFiles(fsState filesystemState) error {
 		}
 
 		// Ignore preserved files.
-		if isPreservedFile(filepath.Base(path)) {
+		if IsPreservedFile(filepath.Base(path)) {
 			continue
 		}
 
@@ -362,10 +362,10 @@ func pruneNonGoFiles(fsState filesystemState) error {
 	return nil
 }
 
-// isPreservedFile checks if the file name indicates that the file should be
+// IsPreservedFile checks if the file name indicates that the file should be
 // preserved based on licenseFilePrefixes or legalFileSubstrings.
 // This applies only to non-source files.
-func isPreservedFile(name string) bool {
+func IsPreservedFile(name string) bool {
 	if isSourceFile(name) {
 		return false
 	}
@@ -34,6 +34,10 @@ type rootdata struct {
 	// for lock.
 	chngall bool
 
+	// Flag indicating minimal versions should be selected, without regard for
+	// the preferred versions in dependencies' locks.
+	minimal bool
+
 	// A map of the project names listed in the root's lock.
 	rlm map[ProjectRoot]LockedProject
 
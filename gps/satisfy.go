@@ -22,7 +22,7 @@ func (s *solver) check(a atomWithPackages, pkgonly bool) error {
 	var err error
 	defer func() {
 		if err != nil {
-			s.traceInfo(err)
+			s.traceReject(a, pkgonly, err)
 		}
 		s.mtr.pop()
 	}()
@@ -39,13 +39,22 @@ func (s *solver) check(a atomWithPackages, pkgonly bool) error {
 		return err
 	}
 
+	var pl []string
 	var deps []completeDep
-	_, deps, err = s.getImportsAndConstraintsOf(a)
+	pl, deps, err = s.getImportsAndConstraintsOf(a)
 	if err != nil {
 		// An err here would be from the package fetcher; pass it straight back
 		return err
 	}
 
+	// Nogoods are only checked when first selecting a project, so a nogood
+	// completed by a package-only selection is found the long way.
+	if !pkgonly {
+		if err = s.checkNogoods(pa, pl); err != nil {
+			return err
+		}
+	}
+
 	// TODO(sdboyer) this deps list contains only packages not already selected
 	// from the target atom (assuming one is selected at all). It's fine for
 	// now, but won't be good enough when we get around to doing static
@@ -89,7 +98,6 @@ func (s *solver) checkAtomAllowable(pa atom) error {
 	var failparent []dependency
 	for _, dep := range deps {
 		if !dep.dep.Constraint.Matches(pa.v) {
-			s.fail(dep.depender.id)
 			failparent = append(failparent, dep)
 		}
 	}
@@ -103,6 +111,29 @@ func (s *solver) checkAtomAllowable(pa atom) error {
 	return err
 }
 
+// checkNogoods ensures that selecting an atom, along with the given packages,
+// would not complete a combination of atoms that's already known not to be
+// part of any solution.
+func (s *solver) checkNogoods(pa atom, pl []string) error {
+	for _, ng := range s.nogoods[pa.id.ProjectRoot] {
+		if !s.completes(ng, pa, pl) {
+			continue
+		}
+
+		rest := make(nogood, 0, len(ng)-1)
+		for _, awp := range ng {
+			if awp.a.id.ProjectRoot != pa.id.ProjectRoot {
+				rest = append(rest, awp)
+			}
+		}
+		return &nogoodFailure{
+			goal:   pa,
+			nogood: rest,
+		}
+	}
+	return nil
+}
+
 // checkRequiredPackagesExist ensures that all required packages enumerated by
 // existing dependencies on this atom are actually present in the atom.
 func (s *solver) checkRequiredPackagesExist(a atomWithPackages) error {
@@ -160,7 +191,6 @@ func (s *solver) checkDepsConstraintsAllowable(a atomWithPackages, cdep complete
 	var nofailsib []dependency
 	for _, sibling := range siblings {
 		if !sibling.dep.Constraint.MatchesAny(dep.Constraint) {
-			s.fail(sibling.depender.id)
 			failsib = append(failsib, sibling)
 		} else {
 			nofailsib = append(nofailsib, sibling)
@@ -182,8 +212,6 @@ func (s *solver) checkDepsDisallowsSelected(a atomWithPackages, cdep completeDep
 	dep := cdep.workingConstraint
 	selected, exists := s.sel.selected(dep.Ident)
 	if exists && !dep.Constraint.Matches(selected.a.v) {
-		s.fail(dep.Ident)
-
 		return &constraintNotAllowedFailure{
 			goal: dependency{depender: a.a, dep: cdep},
 			v:    selected.a.v,
@@ -203,12 +231,6 @@ func (s *solver) checkIdentMatches(a atomWithPackages, cdep completeDep) error {
 	dep := cdep.workingConstraint
 	if curid, has := s.sel.getIdentFor(dep.Ident.ProjectRoot); has && !curid.equiv(dep.Ident) {
 		deps := s.sel.getDependenciesOn(a.a.id)
-		// Fail all the other deps, as there's no way atom can ever be
-		// compatible with them
-		for _, d := range deps {
-			s.fail(d.depender.id)
-		}
-
 		return &sourceMismatchFailure{
 			shared:   dep.Ident.ProjectRoot,
 			sel:      deps,
@@ -235,9 +257,6 @@ func (s *solver) checkRootCaseConflicts(a atomWithPackages, cdep completeDep) er
 
 	curid, _ := s.sel.getIdentFor(current)
 	deps := s.sel.getDependenciesOn(curid)
-	for _, d := range deps {
-		s.fail(d.depender.id)
-	}
 
 	// If a project has multiple packages that import each other, we treat that
 	// as establishing a canonical case variant for the ProjectRoot. It's possible,
@@ -249,14 +268,8 @@ func (s *solver) checkRootCaseConflicts(a atomWithPackages, cdep completeDep) er
 	// a link pointing back at the canonical case variant.
 	//
 	// If this is the case, use a special failure, wrongCaseFailure, that
-	// makes a stronger statement as to the correctness of case variants.
-	//
-	// TODO(sdboyer) This approach to marking failure is less than great, as
-	// this will mark the current atom as failed, as well, causing the
-	// backtracker to work through it. While that could prove fruitful, it's
-	// quite likely just to be wasted effort. Addressing this - if that's a good
-	// idea - would entail creating another path back out of checking to enable
-	// backjumping directly to the incorrect importers.
+	// makes a stronger statement as to the correctness of case variants, and
+	// lets the backtracker jump directly to the incorrect importers.
 	if current == a.a.id.ProjectRoot {
 		return &wrongCaseFailure{
 			correct: pr,
@@ -391,6 +391,8 @@ type basicFixture struct {
 	maxAttempts int
 	// Use downgrade instead of default upgrade sorter
 	downgrade bool
+	// select minimal versions
+	minimal bool
 	// lock file simulator, if one's to be used at all
 	l fixLock
 	// solve failure expected, if any
@@ -610,6 +612,40 @@ var basicFixtures = map[string]basicFixture{
 		changeall: true,
 		downgrade: true,
 	},
+	"minimal through lock": {
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "foo >=1.0.1"),
+			mkDepspec("foo 1.0.0", "bar 1.0.0"),
+			mkDepspec("foo 1.0.1", "bar >=1.0.1"),
+			mkDepspec("foo 1.0.2", "bar 1.0.2"),
+			mkDepspec("bar 1.0.0"),
+			mkDepspec("bar 1.0.1"),
+			mkDepspec("bar 1.0.2"),
+		},
+		l: mklock(
+			"foo 1.0.2",
+			"bar 1.0.2",
+		),
+		r: mksolution(
+			"foo 1.0.1",
+			"bar 1.0.1",
+		),
+		minimal: true,
+	},
+	"minimal backtracks to next lowest": {
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "foo >=1.0.0", "baz *"),
+			mkDepspec("foo 1.0.0"),
+			mkDepspec("foo 1.1.0"),
+			mkDepspec("foo 1.2.0"),
+			mkDepspec("baz 1.0.0", "foo >=1.1.0"),
+		},
+		r: mksolution(
+			"foo 1.1.0",
+			"baz 1.0.0",
+		),
+		minimal: true,
+	},
 	"update one with only one": {
 		ds: []depspec{
 			mkDepspec("root 0.0.0", "foo *"),
@@ -1154,6 +1190,59 @@ var basicFixtures = map[string]basicFixture{
 		),
 		maxAttempts: 2,
 	},
+	// Projects selected after the one that caused a failure can't help avoid
+	// it, even if they've had failures of their own, so backtracking jumps
+	// straight past them.
+	"backjump past unrelated selections": {
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "a *", "b *"),
+			mkDepspec("a 1.0.0", "x ^1.0.0", "z *"),
+			mkDepspec("a 2.0.0", "x ^2.0.0", "z *"),
+			mkDepspec("b 1.0.0", "c *"),
+			mkDepspec("b 2.0.0", "c *"),
+			mkDepspec("b 3.0.0", "c *"),
+			mkDepspec("c 1.0.0"),
+			mkDepspec("c 2.0.0"),
+			mkDepspec("c 3.0.0", "b ^1.0.0"),
+			mkDepspec("x 1.0.0"),
+			mkDepspec("x 2.0.0"),
+			mkDepspec("z 1.0.0", "x ^1.0.0"),
+			mkDepspec("z 1.1.0", "x ^1.0.0"),
+			mkDepspec("z 1.2.0", "x ^1.0.0"),
+			mkDepspec("z 1.3.0", "x ^1.0.0"),
+		},
+		r: mksolution(
+			"a 1.0.0",
+			"b 3.0.0",
+			"c 2.0.0",
+			"x 1.0.0",
+			"z 1.3.0",
+		),
+		maxAttempts: 1,
+	},
+	// Once all the versions of h@1.0.0's dependencies have failed, the solver
+	// learns that h@1.0.0 is not part of any solution, and doesn't explore them
+	// again after backtracking further, to g.
+	"nogood learned from exhausted dependency": {
+		ds: []depspec{
+			mkDepspec("root 0.0.0", "g *", "h *"),
+			mkDepspec("g 1.0.0"),
+			mkDepspec("g 2.0.0"),
+			mkDepspec("g 3.0.0"),
+			mkDepspec("h 1.0.0", "q *"),
+			mkDepspec("h 2.0.0", "g ^1.0.0"),
+			mkDepspec("h 3.0.0", "g ^1.0.0"),
+			mkDepspec("q 1.0.0", "w ^2.0.0"),
+			mkDepspec("q 2.0.0", "w ^2.0.0"),
+			mkDepspec("q 3.0.0", "w ^2.0.0"),
+			mkDepspec("w 1.0.0"),
+		},
+		r: mksolution(
+			"g 1.0.0",
+			"h 3.0.0",
+		),
+		maxAttempts: 4,
+	},
 	// Revision enters vqueue if a dep has a constraint on that revision
 	"revision injected into vqueue": {
 		ds: []depspec{
@@ -637,6 +637,30 @@ var bimodalFixtures = map[string]bimodalFixture{
 			"b 1.0.0 foorev",
 		),
 	},
+	// Preferred versions from a dep's lock are ignored when selecting minimal
+	// versions
+	"minimal ignores prefv": {
+		ds: []depspec{
+			dsp(mkDepspec("root 0.0.0"),
+				pkg("root", "a")),
+			dsp(mkDepspec("a 1.0.0"),
+				pkg("a", "b")),
+			dsp(mkDepspec("b 1.0.0 foorev"),
+				pkg("b")),
+			dsp(mkDepspec("b 2.0.0 barrev"),
+				pkg("b")),
+		},
+		lm: map[string]fixLock{
+			"a 1.0.0": mklock(
+				"b 2.0.0 barrev",
+			),
+		},
+		r: mksolution(
+			"a 1.0.0",
+			"b 1.0.0 foorev",
+		),
+		minimal: true,
+	},
 	// Preferred versions can only work if the thing offering it has been
 	// selected, or at least marked in the unselected queue
 	"prefv only works if depper is selected": {
@@ -1305,6 +1329,8 @@ type bimodalFixture struct {
 	maxAttempts int
 	// Use downgrade instead of default upgrade sorter
 	downgrade bool
+	// select minimal versions
+	minimal bool
 	// lock file simulator, if one's to be used at all
 	l fixLock
 	// map of locks for deps, if any. keys should be of the form:
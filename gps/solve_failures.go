@@ -9,8 +9,165 @@ import (
 	"fmt"
 	"sort"
 	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// SolveFailure is implemented by the errors that the solver returns to explain
+// why it failed. Tools can use Report to inspect or encode a failure, rather
+// than parse the prose of Error.
+type SolveFailure interface {
+	error
+	// Report describes the failure, and any failures that led to it.
+	Report() FailureReport
+}
+
+// The kinds of FailureReport.
+const (
+	FailureNoVersions                = "no-versions"
+	FailureCaseMismatch              = "case-mismatch"
+	FailureWrongCase                 = "wrong-case"
+	FailureDisjointConstraint        = "disjoint-constraint"
+	FailureConstraintNotAllowed      = "constraint-not-allowed"
+	FailureVersionNotAllowed         = "version-not-allowed"
+	FailureMissingSource             = "missing-source"
+	FailureBadOptions                = "bad-options"
+	FailureSourceMismatch            = "source-mismatch"
+	FailureProblemPackages           = "problem-packages"
+	FailureDependencyProblemPackages = "dependency-problem-packages"
+	FailureNonexistentRevision       = "nonexistent-revision"
+	FailureNogood                    = "nogood"
+	FailureLimit                     = "limit"
+	FailureOther                     = "other"
 )
 
+// FailureReport is a structured description of a solve failure, suitable for
+// encoding as JSON. Which fields are set depends on the Kind of failure.
+type FailureReport struct {
+	// Kind is one of the Failure* constants. FailureOther is used for errors
+	// that are not SolveFailures, such as those from a SourceManager.
+	Kind string `json:"Kind"`
+	// Message is the prose description of the failure, from Error.
+	Message string `json:"Message"`
+	// Atom is the project at a version that could not be introduced into the
+	// solution.
+	Atom *AtomReport `json:"Atom,omitempty"`
+	// Project is the project that the failure concerns: the one for which no
+	// version could be found, or the dependency of Atom that caused it to be
+	// rejected.
+	Project string `json:"Project,omitempty"`
+	// Source is the source that Atom requires Project to come from, if it
+	// differs from SelectedSource.
+	Source string `json:"Source,omitempty"`
+	// SelectedSource is the source of Project established by the current
+	// selection.
+	SelectedSource string `json:"SelectedSource,omitempty"`
+	// CanonicalRoot is the casing of Project that is already established, or
+	// that its own packages use.
+	CanonicalRoot string `json:"CanonicalRoot,omitempty"`
+	// Version is the version of Project that is involved: the one selected,
+	// the one rejected, or a missing revision.
+	Version string `json:"Version,omitempty"`
+	// Constraint is the constraint on Project that caused the failure.
+	Constraint string `json:"Constraint,omitempty"`
+	// Conflicts are the dependencies already in the solution that conflict
+	// with Atom.
+	Conflicts []DependencyReport `json:"Conflicts,omitempty"`
+	// Compatible are the dependencies on Project already in the solution that
+	// do not conflict with Atom.
+	Compatible []DependencyReport `json:"Compatible,omitempty"`
+	// Packages are the packages of Project that are missing or have errors.
+	Packages []PackageReport `json:"Packages,omitempty"`
+	// Nogood is the combination of selected projects that, together with
+	// Atom, was already found not to be part of any solution.
+	Nogood []AtomReport `json:"Nogood,omitempty"`
+	// Attempts are the versions of Project that were tried, in order, and the
+	// failures that rejected them.
+	Attempts []AttemptReport `json:"Attempts,omitempty"`
+	// Deepest is the largest partial solution that the solver reached before
+	// it gave up.
+	Deepest []AtomReport `json:"Deepest,omitempty"`
+	// Frequent are the failures that the solver encountered most often before
+	// it gave up.
+	Frequent []FrequentFailure `json:"Frequent,omitempty"`
+}
+
+// AtomReport describes a project at a particular version.
+type AtomReport struct {
+	Project string `json:"Project"`
+	Source  string `json:"Source,omitempty"`
+	Version string `json:"Version,omitempty"`
+	// Root is true for the root project, which has no version.
+	Root bool `json:"Root,omitempty"`
+}
+
+// DependencyReport describes a dependency of one project on another.
+type DependencyReport struct {
+	Depender   AtomReport `json:"Depender"`
+	Project    string     `json:"Project"`
+	Source     string     `json:"Source,omitempty"`
+	Constraint string     `json:"Constraint"`
+	Packages   []string   `json:"Packages,omitempty"`
+}
+
+// PackageReport describes a package that is missing or has errors.
+type PackageReport struct {
+	Path string `json:"Path"`
+	// Error describes the problem with the package, or is empty if it is
+	// missing.
+	Error string `json:"Error,omitempty"`
+	// RequiredBy are the projects that require the package, if known.
+	RequiredBy []AtomReport `json:"RequiredBy,omitempty"`
+}
+
+// AttemptReport describes a version of a project that the solver tried, and
+// the failure that rejected it.
+type AttemptReport struct {
+	Version string        `json:"Version"`
+	Failure FailureReport `json:"Failure"`
+}
+
+// ReportFailure describes err, which may wrap a SolveFailure. Errors that are
+// not SolveFailures are reported with Kind FailureOther.
+func ReportFailure(err error) FailureReport {
+	if sf, ok := errors.Cause(err).(SolveFailure); ok {
+		r := sf.Report()
+		r.Message = err.Error()
+		return r
+	}
+	return FailureReport{Kind: FailureOther, Message: err.Error()}
+}
+
+func atomReport(a atom) *AtomReport {
+	r := &AtomReport{
+		Project: string(a.id.ProjectRoot),
+		Source:  a.id.Source,
+	}
+	if a.v == rootRev || a.v == nil {
+		r.Root = true
+	} else {
+		r.Version = a.v.String()
+	}
+	return r
+}
+
+func dependencyReports(deps []dependency) []DependencyReport {
+	if len(deps) == 0 {
+		return nil
+	}
+	rs := make([]DependencyReport, len(deps))
+	for i, d := range deps {
+		rs[i] = DependencyReport{
+			Depender:   *atomReport(d.depender),
+			Project:    string(d.dep.Ident.ProjectRoot),
+			Source:     d.dep.Ident.Source,
+			Constraint: d.dep.Constraint.String(),
+			Packages:   d.dep.pl,
+		}
+	}
+	return rs
+}
+
 func a2vs(a atom) string {
 	if a.v == rootRev || a.v == nil {
 		return "(root)"
@@ -60,6 +217,22 @@ func (e *noVersionError) traceString() string {
 	return buf.String()
 }
 
+func (e *noVersionError) Report() FailureReport {
+	r := FailureReport{
+		Kind:    FailureNoVersions,
+		Message: e.Error(),
+		Project: string(e.pn.ProjectRoot),
+		Source:  e.pn.Source,
+	}
+	for _, f := range e.fails {
+		r.Attempts = append(r.Attempts, AttemptReport{
+			Version: f.v.String(),
+			Failure: ReportFailure(f.f),
+		})
+	}
+	return r
+}
+
 // caseMismatchFailure occurs when there are import paths that differ only by
 // case. The compiler disallows this case.
 type caseMismatchFailure struct {
@@ -103,6 +276,17 @@ func (e *caseMismatchFailure) traceString() string {
 	return buf.String()
 }
 
+func (e *caseMismatchFailure) Report() FailureReport {
+	return FailureReport{
+		Kind:          FailureCaseMismatch,
+		Message:       e.Error(),
+		Atom:          atomReport(e.goal.depender),
+		Project:       string(e.goal.dep.Ident.ProjectRoot),
+		CanonicalRoot: string(e.current),
+		Conflicts:     dependencyReports(e.failsib),
+	}
+}
+
 // wrongCaseFailure occurs when one or more projects - A, B, ... - depend on
 // another project - Z - with an incorrect case variant, as indicated by the
 // case variant used internally by Z to reference its own packages.
@@ -147,6 +331,17 @@ func (e *wrongCaseFailure) traceString() string {
 	return buf.String()
 }
 
+func (e *wrongCaseFailure) Report() FailureReport {
+	return FailureReport{
+		Kind:          FailureWrongCase,
+		Message:       e.Error(),
+		Atom:          atomReport(e.goal.depender),
+		Project:       string(e.goal.dep.Ident.ProjectRoot),
+		CanonicalRoot: string(e.correct),
+		Conflicts:     dependencyReports(e.badcase),
+	}
+}
+
 // disjointConstraintFailure occurs when attempting to introduce an atom that
 // itself has an acceptable version, but one of its dependency constraints is
 // disjoint with one or more dependency constraints already active for that
@@ -219,6 +414,19 @@ func (e *disjointConstraintFailure) traceString() string {
 	return buf.String()
 }
 
+func (e *disjointConstraintFailure) Report() FailureReport {
+	return FailureReport{
+		Kind:       FailureDisjointConstraint,
+		Message:    e.Error(),
+		Atom:       atomReport(e.goal.depender),
+		Project:    string(e.goal.dep.Ident.ProjectRoot),
+		Source:     e.goal.dep.Ident.Source,
+		Constraint: e.goal.dep.Constraint.String(),
+		Conflicts:  dependencyReports(e.failsib),
+		Compatible: dependencyReports(e.nofailsib),
+	}
+}
+
 // Indicates that an atom could not be introduced because one of its dep
 // constraints does not admit the currently-selected version of the target
 // project.
@@ -251,6 +459,18 @@ func (e *constraintNotAllowedFailure) traceString() string {
 	)
 }
 
+func (e *constraintNotAllowedFailure) Report() FailureReport {
+	return FailureReport{
+		Kind:       FailureConstraintNotAllowed,
+		Message:    e.Error(),
+		Atom:       atomReport(e.goal.depender),
+		Project:    string(e.goal.dep.Ident.ProjectRoot),
+		Source:     e.goal.dep.Ident.Source,
+		Version:    e.v.String(),
+		Constraint: e.goal.dep.Constraint.String(),
+	}
+}
+
 // versionNotAllowedFailure describes a failure where an atom is rejected
 // because its version is not allowed by current constraints.
 //
@@ -300,6 +520,18 @@ func (e *versionNotAllowedFailure) traceString() string {
 	return buf.String()
 }
 
+func (e *versionNotAllowedFailure) Report() FailureReport {
+	return FailureReport{
+		Kind:       FailureVersionNotAllowed,
+		Message:    e.Error(),
+		Atom:       atomReport(e.goal),
+		Project:    string(e.goal.id.ProjectRoot),
+		Version:    e.goal.v.String(),
+		Constraint: e.c.String(),
+		Conflicts:  dependencyReports(e.failparent),
+	}
+}
+
 type missingSourceFailure struct {
 	goal ProjectIdentifier
 	prob string
@@ -309,12 +541,25 @@ func (e *missingSourceFailure) Error() string {
 	return fmt.Sprintf(e.prob, e.goal)
 }
 
+func (e *missingSourceFailure) Report() FailureReport {
+	return FailureReport{
+		Kind:    FailureMissingSource,
+		Message: e.Error(),
+		Project: string(e.goal.ProjectRoot),
+		Source:  e.goal.Source,
+	}
+}
+
 type badOptsFailure string
 
 func (e badOptsFailure) Error() string {
 	return string(e)
 }
 
+func (e badOptsFailure) Report() FailureReport {
+	return FailureReport{Kind: FailureBadOptions, Message: e.Error()}
+}
+
 type sourceMismatchFailure struct {
 	// The ProjectRoot over which there is disagreement about where it should be
 	// sourced from
@@ -352,6 +597,18 @@ func (e *sourceMismatchFailure) traceString() string {
 	return buf.String()
 }
 
+func (e *sourceMismatchFailure) Report() FailureReport {
+	return FailureReport{
+		Kind:           FailureSourceMismatch,
+		Message:        e.Error(),
+		Atom:           atomReport(e.prob),
+		Project:        string(e.shared),
+		Source:         e.mismatch,
+		SelectedSource: e.current,
+		Conflicts:      dependencyReports(e.sel),
+	}
+}
+
 type errDeppers struct {
 	err     error
 	deppers []atom
@@ -445,6 +702,34 @@ func (e *checkeeHasProblemPackagesFailure) traceString() string {
 	return buf.String()
 }
 
+func (e *checkeeHasProblemPackagesFailure) Report() FailureReport {
+	r := FailureReport{
+		Kind:    FailureProblemPackages,
+		Message: e.Error(),
+		Atom:    atomReport(e.goal),
+		Project: string(e.goal.id.ProjectRoot),
+		Version: e.goal.v.String(),
+	}
+
+	pkgs := make([]string, 0, len(e.failpkg))
+	for pkg := range e.failpkg {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+	for _, pkg := range pkgs {
+		errdep := e.failpkg[pkg]
+		pr := PackageReport{Path: pkg}
+		if errdep.err != nil {
+			pr.Error = errdep.err.Error()
+		}
+		for _, a := range errdep.deppers {
+			pr.RequiredBy = append(pr.RequiredBy, *atomReport(a))
+		}
+		r.Packages = append(r.Packages, pr)
+	}
+	return r
+}
+
 // depHasProblemPackagesFailure indicates that the goal dependency was rejected
 // because there were problems with one or more of the packages the dependency
 // requires in the atom currently selected for that dependency. (This failure
@@ -545,6 +830,32 @@ func (e *depHasProblemPackagesFailure) traceString() string {
 	return buf.String()
 }
 
+func (e *depHasProblemPackagesFailure) Report() FailureReport {
+	r := FailureReport{
+		Kind:       FailureDependencyProblemPackages,
+		Message:    e.Error(),
+		Atom:       atomReport(e.goal.depender),
+		Project:    string(e.goal.dep.Ident.ProjectRoot),
+		Source:     e.goal.dep.Ident.Source,
+		Version:    e.v.String(),
+		Constraint: e.goal.dep.Constraint.String(),
+	}
+
+	pkgs := make([]string, 0, len(e.prob))
+	for pkg := range e.prob {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+	for _, pkg := range pkgs {
+		pr := PackageReport{Path: pkg}
+		if err := e.prob[pkg]; err != nil {
+			pr.Error = err.Error()
+		}
+		r.Packages = append(r.Packages, pr)
+	}
+	return r
+}
+
 // nonexistentRevisionFailure indicates that a revision constraint was specified
 // for a given project, but that that revision does not exist in the source
 // repository.
@@ -570,3 +881,57 @@ func (e *nonexistentRevisionFailure) traceString() string {
 		e.goal.dep.Ident,
 	)
 }
+
+func (e *nonexistentRevisionFailure) Report() FailureReport {
+	return FailureReport{
+		Kind:    FailureNonexistentRevision,
+		Message: e.Error(),
+		Atom:    atomReport(e.goal.depender),
+		Project: string(e.goal.dep.Ident.ProjectRoot),
+		Source:  e.goal.dep.Ident.Source,
+		Version: string(e.r),
+	}
+}
+
+// nogoodFailure indicates that an atom would complete a combination of atoms
+// that the solver already found not to be part of any solution, while
+// exploring an earlier selection.
+type nogoodFailure struct {
+	// goal is the atom that would complete the nogood.
+	goal atom
+	// nogood is the rest of the nogood, all of which is currently selected.
+	nogood nogood
+}
+
+func (e *nogoodFailure) Error() string {
+	if len(e.nogood) == 0 {
+		return fmt.Sprintf("Could not introduce %s, as no solution was found with it", a2vs(e.goal))
+	}
+	return fmt.Sprintf(
+		"Could not introduce %s, as no solution was found with it and %s",
+		a2vs(e.goal),
+		e.nogood,
+	)
+}
+
+func (e *nogoodFailure) traceString() string {
+	if len(e.nogood) == 0 {
+		return fmt.Sprintf("%s already failed", a2vs(e.goal))
+	}
+	return fmt.Sprintf("%s with %s already failed", a2vs(e.goal), e.nogood)
+}
+
+func (e *nogoodFailure) Report() FailureReport {
+	r := FailureReport{
+		Kind:    FailureNogood,
+		Message: e.Error(),
+		Atom:    atomReport(e.goal),
+		Project: string(e.goal.id.ProjectRoot),
+		Source:  e.goal.id.Source,
+		Version: e.goal.v.String(),
+	}
+	for _, awp := range e.nogood {
+		r.Nogood = append(r.Nogood, *atomReport(awp.a))
+	}
+	return r
+}
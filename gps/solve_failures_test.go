@@ -0,0 +1,115 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestReportFailure(t *testing.T) {
+	fix := basicFixtures["no version that matches requirement"]
+	err := errors.Wrap(fix.fail, "solving failure")
+
+	root := AtomReport{Project: "root", Root: true}
+	notAllowed := func(v string) AttemptReport {
+		f := fix.fail.(*noVersionError).fails
+		var e error
+		for _, fv := range f {
+			if fv.v.String() == v {
+				e = fv.f
+			}
+		}
+		return AttemptReport{
+			Version: v,
+			Failure: FailureReport{
+				Kind:       FailureVersionNotAllowed,
+				Message:    e.Error(),
+				Atom:       &AtomReport{Project: "foo", Version: v},
+				Project:    "foo",
+				Version:    v,
+				Constraint: "^1.0.0",
+				Conflicts: []DependencyReport{
+					{Depender: root, Project: "foo", Constraint: "^1.0.0", Packages: []string{"foo"}},
+				},
+			},
+		}
+	}
+	want := FailureReport{
+		Kind:     FailureNoVersions,
+		Message:  err.Error(),
+		Project:  "foo",
+		Attempts: []AttemptReport{notAllowed("2.1.3"), notAllowed("2.0.0")},
+	}
+
+	got := ReportFailure(err)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("unexpected report:\n\t(GOT): %#v\n\t(WNT): %#v", got, want)
+	}
+
+	// The report survives a round trip through JSON.
+	b, err := json.Marshal(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded FailureReport
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, want) {
+		t.Errorf("unexpected report after decoding %s:\n\t(GOT): %#v\n\t(WNT): %#v", b, decoded, want)
+	}
+
+	other := errors.New("source is unavailable")
+	if got := ReportFailure(other); got.Kind != FailureOther || got.Message != other.Error() {
+		t.Errorf("unexpected report for a non-SolveFailure error: %#v", got)
+	}
+}
+
+func TestSolveReturnsSolveFailure(t *testing.T) {
+	fix := basicFixtures["disjoint constraints"]
+	params := SolveParameters{
+		RootDir:         string(fix.ds[0].n),
+		RootPackageTree: fix.rootTree(),
+		Manifest:        fix.rootmanifest(),
+		ProjectAnalyzer: naiveAnalyzer{},
+	}
+	_, err := fixSolve(params, newdepspecSM(fix.ds, nil), t)
+	if _, ok := err.(SolveFailure); !ok {
+		t.Fatalf("expected a SolveFailure, got %T: %s", err, err)
+	}
+
+	r := ReportFailure(err)
+	if r.Kind != FailureNoVersions || r.Project != "foo" || len(r.Attempts) == 0 {
+		t.Fatalf("unexpected report: %#v", r)
+	}
+	if f := r.Attempts[0].Failure; f.Kind != FailureDisjointConstraint || f.Project != "shared" || len(f.Conflicts) == 0 {
+		t.Errorf("unexpected report of the first attempt: %#v", f)
+	}
+}
+
+func TestReportNogoodFailure(t *testing.T) {
+	err := &nogoodFailure{
+		goal: mkAtom("h 1.0.0"),
+		nogood: nogood{
+			{a: mkAtom("g 3.0.0"), pl: []string{"g"}},
+		},
+	}
+
+	want := FailureReport{
+		Kind:    FailureNogood,
+		Message: err.Error(),
+		Atom:    &AtomReport{Project: "h", Version: "1.0.0"},
+		Project: "h",
+		Version: "1.0.0",
+		Nogood:  []AtomReport{{Project: "g", Version: "3.0.0"}},
+	}
+	if got := ReportFailure(err); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected report:\n\t(GOT): %#v\n\t(WNT): %#v", got, want)
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// The limits on a solve run that a LimitError may report.
+const (
+	LimitAttempts = "attempts"
+	LimitTimeout  = "timeout"
+)
+
+// maxFrequentFailures is the number of failures that a LimitError reports.
+const maxFrequentFailures = 5
+
+// LimitError is returned by Solve when it gives up because it reached
+// SolveParameters.MaxAttempts or Timeout, before it either found a solution or
+// proved that there is none.
+//
+// It describes how far the solver got, so that the dependencies that it was
+// struggling with can be identified.
+type LimitError struct {
+	// Limit is the limit that was reached: LimitAttempts or LimitTimeout.
+	Limit    string
+	Attempts int
+	Elapsed  time.Duration
+	// Deepest is the largest partial solution reached: the projects, other
+	// than the root, that were selected at the time, in order of selection.
+	Deepest []AtomReport
+	// Frequent are the failures that the solver encountered most often while
+	// selecting versions, most frequent first.
+	Frequent []FrequentFailure
+}
+
+// FrequentFailure describes a kind of failure concerning a project, and how
+// often it occurred.
+type FrequentFailure struct {
+	Kind    string `json:"Kind"`
+	Project string `json:"Project"`
+	Count   int    `json:"Count"`
+	// Message is the prose description of the most recent such failure.
+	Message string `json:"Message"`
+}
+
+func (e *LimitError) Error() string {
+	var buf bytes.Buffer
+	switch e.Limit {
+	case LimitAttempts:
+		fmt.Fprintf(&buf, "gave up solving after %d attempts, in %s", e.Attempts, e.Elapsed.Round(time.Millisecond))
+	default:
+		fmt.Fprintf(&buf, "gave up solving after %s, and %d attempts", e.Elapsed.Round(time.Millisecond), e.Attempts)
+	}
+
+	fmt.Fprintf(&buf, "\nThe deepest partial solution reached had %d projects", len(e.Deepest))
+	if len(e.Deepest) > 0 {
+		fmt.Fprintf(&buf, ", the last selected being %s", e.Deepest[len(e.Deepest)-1].Project)
+		if v := e.Deepest[len(e.Deepest)-1].Version; v != "" {
+			fmt.Fprintf(&buf, "@%s", v)
+		}
+	}
+
+	if len(e.Frequent) > 0 {
+		fmt.Fprintf(&buf, "\nThe most frequent failures were:")
+		for _, f := range e.Frequent {
+			fmt.Fprintf(&buf, "\n\t(%d times) %s", f.Count, f.Message)
+		}
+	}
+	return buf.String()
+}
+
+func (e *LimitError) traceString() string {
+	return fmt.Sprintf("reached the %s limit after %d attempts", e.Limit, e.Attempts)
+}
+
+func (e *LimitError) Report() FailureReport {
+	return FailureReport{
+		Kind:     FailureLimit,
+		Message:  e.Error(),
+		Deepest:  e.Deepest,
+		Frequent: e.Frequent,
+	}
+}
+
+// failureKey groups the failures that a solver counts for a LimitError.
+type failureKey struct {
+	kind, project string
+}
+
+// limited reports whether the solve has any limits, and so whether it needs
+// to keep track of the diagnostics for a LimitError.
+func (s *solver) limited() bool {
+	return s.maxAttempts > 0 || s.timeout > 0
+}
+
+// noteFailure counts err, a failure to select an atom, towards the failures
+// reported by a LimitError.
+func (s *solver) noteFailure(err error) {
+	if !s.limited() {
+		return
+	}
+
+	r := ReportFailure(err)
+	k := failureKey{kind: r.Kind, project: r.Project}
+	if s.failures == nil {
+		s.failures = make(map[failureKey]*FrequentFailure)
+	}
+	f, has := s.failures[k]
+	if !has {
+		f = &FrequentFailure{Kind: r.Kind, Project: r.Project}
+		s.failures[k] = f
+	}
+	f.Count++
+	f.Message = r.Message
+}
+
+// noteSelection records the current selection as the deepest partial solution,
+// if it is.
+func (s *solver) noteSelection() {
+	if !s.limited() {
+		return
+	}
+
+	n := 0
+	for _, sel := range s.sel.projects[1:] {
+		if sel.first {
+			n++
+		}
+	}
+	if n <= len(s.deepest) {
+		return
+	}
+
+	s.deepest = make([]AtomReport, 0, n)
+	for _, sel := range s.sel.projects[1:] {
+		if sel.first {
+			s.deepest = append(s.deepest, *atomReport(sel.a.a))
+		}
+	}
+}
+
+// checkAttempts returns a LimitError if the solve may not backtrack again.
+func (s *solver) checkAttempts() error {
+	if s.maxAttempts > 0 && s.attempts >= s.maxAttempts {
+		return s.limitError(LimitAttempts)
+	}
+	return nil
+}
+
+// checkTimeout returns a LimitError if the solve has run out of time.
+func (s *solver) checkTimeout() error {
+	if s.timeout > 0 && time.Since(s.start) >= s.timeout {
+		return s.limitError(LimitTimeout)
+	}
+	return nil
+}
+
+func (s *solver) limitError(limit string) *LimitError {
+	e := &LimitError{
+		Limit:    limit,
+		Attempts: s.attempts,
+		Elapsed:  time.Since(s.start),
+		Deepest:  s.deepest,
+	}
+
+	for _, f := range s.failures {
+		e.Frequent = append(e.Frequent, *f)
+	}
+	sort.Slice(e.Frequent, func(i, j int) bool {
+		if e.Frequent[i].Count != e.Frequent[j].Count {
+			return e.Frequent[i].Count > e.Frequent[j].Count
+		}
+		if e.Frequent[i].Project != e.Frequent[j].Project {
+			return e.Frequent[i].Project < e.Frequent[j].Project
+		}
+		return e.Frequent[i].Kind < e.Frequent[j].Kind
+	})
+	if len(e.Frequent) > maxFrequentFailures {
+		e.Frequent = e.Frequent[:maxFrequentFailures]
+	}
+	return e
+}
@@ -0,0 +1,70 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"testing"
+	"time"
+)
+
+func limitFixtureParams(name string) (SolveParameters, *depspecSourceManager) {
+	fix := basicFixtures[name]
+	params := SolveParameters{
+		RootDir:         string(fix.ds[0].n),
+		RootPackageTree: fix.rootTree(),
+		Manifest:        fix.rootmanifest(),
+		ProjectAnalyzer: naiveAnalyzer{},
+	}
+	return params, newdepspecSM(fix.ds, nil)
+}
+
+func TestSolveMaxAttempts(t *testing.T) {
+	params, sm := limitFixtureParams("nogood learned from exhausted dependency")
+	params.MaxAttempts = 1
+	_, err := fixSolve(params, sm, t)
+	le, ok := err.(*LimitError)
+	if !ok {
+		t.Fatalf("expected a *LimitError, got %T: %v", err, err)
+	}
+	if le.Limit != LimitAttempts || le.Attempts != 1 {
+		t.Errorf("expected to give up after 1 attempt, got %s after %d", le.Limit, le.Attempts)
+	}
+	if len(le.Deepest) < 2 {
+		t.Errorf("expected a partial solution of at least 2 projects, got %v", le.Deepest)
+	}
+	if len(le.Frequent) == 0 {
+		t.Fatal("expected the failures encountered to be reported")
+	}
+	for k := 1; k < len(le.Frequent); k++ {
+		if le.Frequent[k].Count > le.Frequent[k-1].Count {
+			t.Errorf("expected the most frequent failures first, got %v", le.Frequent)
+		}
+	}
+
+	r := ReportFailure(err)
+	if r.Kind != FailureLimit || len(r.Deepest) != len(le.Deepest) || len(r.Frequent) != len(le.Frequent) {
+		t.Errorf("unexpected report: %#v", r)
+	}
+
+	// Enough attempts allow the same solve to succeed.
+	params, sm = limitFixtureParams("nogood learned from exhausted dependency")
+	params.MaxAttempts = basicFixtures["nogood learned from exhausted dependency"].maxAttempts
+	if _, err := fixSolve(params, sm, t); err != nil {
+		t.Errorf("expected a solution within %d attempts, got %v", params.MaxAttempts, err)
+	}
+}
+
+func TestSolveTimeout(t *testing.T) {
+	params, sm := limitFixtureParams("nogood learned from exhausted dependency")
+	params.Timeout = time.Nanosecond
+	_, err := fixSolve(params, sm, t)
+	le, ok := err.(*LimitError)
+	if !ok {
+		t.Fatalf("expected a *LimitError, got %T: %v", err, err)
+	}
+	if le.Limit != LimitTimeout || le.Elapsed < params.Timeout {
+		t.Errorf("expected to give up after the timeout, got %s after %s", le.Limit, le.Elapsed)
+	}
+}
@@ -71,6 +71,7 @@ func solveBasicsAndCheck(fix basicFixture, t *testing.T) (res Solution, err erro
 		Manifest:        fix.rootmanifest(),
 		Lock:            dummyLock{},
 		Downgrade:       fix.downgrade,
+		Minimal:         fix.minimal,
 		ChangeAll:       fix.changeall,
 		ToChange:        fix.changelist,
 		ProjectAnalyzer: naiveAnalyzer{},
@@ -117,6 +118,7 @@ func solveBimodalAndCheck(fix bimodalFixture, t *testing.T) (res Solution, err e
 		Manifest:        fix.rootmanifest(),
 		Lock:            dummyLock{},
 		Downgrade:       fix.downgrade,
+		Minimal:         fix.minimal,
 		ChangeAll:       fix.changeall,
 		ProjectAnalyzer: naiveAnalyzer{},
 	}
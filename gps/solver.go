@@ -13,6 +13,7 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/armon/go-radix"
 	"github.com/golang/dep/gps/paths"
@@ -92,11 +93,51 @@ type SolveParameters struct {
 	// typical case.
 	Downgrade bool
 
+	// Minimal indicates that the solver should select the minimal versions of
+	// projects that satisfy all the constraints on them, e.g. to check that the
+	// lower bounds of those constraints are accurate. It implies Downgrade, and
+	// goes further:
+	//
+	//  - The root lock is ignored, as if ChangeAll were set, so that the
+	//    solution depends only on the constraints.
+	//  - The versions that dependencies' locks prefer are ignored.
+	//
+	// Versions are tried in the order of SortForDowngrade, so the lowest
+	// semver version that satisfies the constraints is selected, and other
+	// kinds of versions only if none does. That includes projects with no
+	// constraints at all, which get their lowest version. Where constraints
+	// that are only discovered later rule out a selected version, the solver
+	// backtracks to the next lowest, as it would otherwise to the next highest.
+	Minimal bool
+
 	// TraceLogger is the logger to use for generating trace output. If set, the
 	// solver will generate informative trace output as it moves through the
 	// solving process.
 	TraceLogger *log.Logger
 
+	// Tracer, if set, receives events describing each step the solver takes,
+	// for tools to process.
+	Tracer Tracer
+
+	// Prefetch is the maximum number of concurrent requests that the solver
+	// makes to the SourceManager in the background, to fetch the version
+	// lists, manifests and packages it is likely to need next. This overlaps
+	// their network round trips, without affecting the solution. 0: A default
+	// of 8. <0: Nothing is prefetched.
+	Prefetch int
+
+	// MaxAttempts is the maximum number of times that the solver may
+	// backtrack. If it needs to backtrack again, it gives up, and returns a
+	// *LimitError. <=0: No limit.
+	MaxAttempts int
+
+	// Timeout is how long the solver may run for. Once it has passed, the
+	// solver gives up, and returns a *LimitError. <=0: No limit.
+	//
+	// The solver checks the time between its steps, so it may overrun by as
+	// long as a single request to the SourceManager takes.
+	Timeout time.Duration
+
 	// stdLibFn is the function to use to recognize standard library import paths.
 	// Only overridden for tests. Defaults to paths.IsStandardImportPath if nil.
 	stdLibFn func(string) bool
@@ -115,12 +156,29 @@ type solver struct {
 	// starts moving forward again.
 	attempts int
 
+	// The limits on this solve, from SolveParameters, and the time it started.
+	maxAttempts int
+	timeout     time.Duration
+	start       time.Time
+
+	// The diagnostics reported by a LimitError, if the solve has limits: the
+	// deepest partial solution reached, and counts of the failures seen.
+	deepest  []AtomReport
+	failures map[failureKey]*FrequentFailure
+
 	// Logger used exclusively for trace output, or nil to suppress.
 	tl *log.Logger
 
+	// Receives trace events, or nil to suppress.
+	tr Tracer
+
 	// The function to use to recognize standard library import paths.
 	stdLibFn func(string) bool
 
+	// Fetches data the solver is likely to need soon in the background, or nil
+	// if prefetching is disabled.
+	pf *prefetcher
+
 	// A bridge to the standard SourceManager. The adapter does some local
 	// caching of pre-sorted version lists, as well as translation between the
 	// full-on ProjectIdentifiers that the solver deals with and the simplified
@@ -154,6 +212,10 @@ type solver struct {
 	// added to an existing project.
 	vqs []*versionQueue
 
+	// Nogoods learned over the course of this solve, indexed by the roots of
+	// the projects they contain.
+	nogoods map[ProjectRoot][]nogood
+
 	// Contains data and constraining information from the root project
 	rd rootdata
 
@@ -193,7 +255,8 @@ func (params SolveParameters) toRootdata() (rootdata, error) {
 		rpt:     params.RootPackageTree.Copy(),
 		chng:    make(map[ProjectRoot]struct{}),
 		rlm:     make(map[ProjectRoot]LockedProject),
-		chngall: params.ChangeAll,
+		chngall: params.ChangeAll || params.Minimal,
+		minimal: params.Minimal,
 		dir:     params.RootDir,
 		an:      params.ProjectAnalyzer,
 	}
@@ -286,16 +349,21 @@ func Prepare(params SolveParameters, sm SourceManager) (Solver, error) {
 
 	s := &solver{
 		tl:       params.TraceLogger,
+		tr:       params.Tracer,
 		stdLibFn: params.stdLibFn,
 		rd:       rd,
+		pf:       newPrefetcher(sm, params.ProjectAnalyzer, params.Downgrade || params.Minimal, params.Prefetch),
+
+		maxAttempts: params.MaxAttempts,
+		timeout:     params.Timeout,
 	}
 
 	// Set up the bridge and ensure the root dir is in good, working order
 	// before doing anything else.
 	if params.mkBridgeFn == nil {
-		s.b = mkBridge(s, sm, params.Downgrade)
+		s.b = mkBridge(s, sm, params.Downgrade || params.Minimal)
 	} else {
-		s.b = params.mkBridgeFn(s, sm, params.Downgrade)
+		s.b = params.mkBridgeFn(s, sm, params.Downgrade || params.Minimal)
 	}
 	err = s.b.verifyRootDir(params.RootDir)
 	if err != nil {
@@ -431,6 +499,10 @@ func (s *solver) Solve(ctx context.Context) (Solution, error) {
 
 	// Set up a metrics object
 	s.mtr = newMetrics()
+	s.start = time.Now()
+
+	s.pf.start()
+	defer s.pf.stop()
 
 	// Prime the queues with the root project
 	if err := s.selectRoot(); err != nil {
@@ -452,6 +524,7 @@ func (s *solver) Solve(ctx context.Context) (Solution, error) {
 		// Convert ProjectAtoms into LockedProjects
 		soln.p = make([]LockedProject, 0, len(all))
 		for pa, pl := range all {
+			pa.v = s.b.resolveProvisional(pa.id, pa.v)
 			lp := pa2lp(pa, pl)
 			// Pass back the original inputlp directly if it Eqs what was
 			// selected.
@@ -483,6 +556,9 @@ func (s *solver) solve(ctx context.Context) (map[atom]map[string]struct{}, error
 			return nil, ctx.Err()
 		default:
 		}
+		if err := s.checkTimeout(); err != nil {
+			return nil, err
+		}
 
 		bmi, has := s.nextUnselected()
 
@@ -507,7 +583,7 @@ func (s *solver) solve(ctx context.Context) (map[atom]map[string]struct{}, error
 				s.mtr.pop()
 				// Err means a failure somewhere down the line; try backtracking.
 				s.traceStartBacktrack(bmi, err, false)
-				success, berr := s.backtrack(ctx)
+				success, berr := s.backtrack(ctx, s.conflictsOf(bmi.id, queue))
 				if berr != nil {
 					err = berr
 				} else if success {
@@ -562,8 +638,14 @@ func (s *solver) solve(ctx context.Context) (map[atom]map[string]struct{}, error
 			if err != nil {
 				s.mtr.pop()
 				// Err means a failure somewhere down the line; try backtracking.
+				// The packages can't be added as long as the project is
+				// selected at its current version, and its dependers need them.
 				s.traceStartBacktrack(bmi, err, true)
-				success, berr := s.backtrack(ctx)
+				cs := s.conflictsOf(bmi.id, nil)
+				s.addCulprit(cs, bmi.id.ProjectRoot)
+				s.addCulprits(cs, err)
+				s.noteFailure(err)
+				success, berr := s.backtrack(ctx, cs)
 				if berr != nil {
 					err = berr
 				} else if success {
@@ -625,12 +707,9 @@ func (s *solver) selectRoot() error {
 	}
 
 	for _, dep := range deps {
-		// If we have no lock, or if this dep isn't in the lock, then prefetch
-		// it. See longer explanation in selectAtom() for how we benefit from
-		// parallelism here.
-		if s.rd.needVersionsFor(dep.Ident.ProjectRoot) {
-			go s.b.SyncSourceFor(dep.Ident)
-		}
+		// Prefetch what we'll need to select the dep. See longer explanation
+		// in selectAtom() for how we benefit from parallelism here.
+		s.prefetchDep(dep.Ident, nil)
 
 		s.sel.pushDep(dependency{depender: awp.a, dep: dep})
 		// Add all to unselected queue
@@ -824,7 +903,7 @@ func (s *solver) createVersionQueue(bmi bimodalIdentifier) (*versionQueue, error
 	}
 
 	var prefv Version
-	if bmi.fromRoot {
+	if bmi.fromRoot && !s.rd.minimal {
 		// If this bmi came from the root, then we want to search through things
 		// with a dependency on it in order to see if any have a lock that might
 		// express a prefv
@@ -865,7 +944,8 @@ func (s *solver) createVersionQueue(bmi bimodalIdentifier) (*versionQueue, error
 		//}
 
 	} else {
-		// Otherwise, just use the preferred version expressed in the bmi
+		// Otherwise, just use the preferred version expressed in the bmi,
+		// which is never set when selecting minimal versions
 		prefv = bmi.prefv
 	}
 
@@ -908,6 +988,9 @@ func (s *solver) createVersionQueue(bmi bimodalIdentifier) (*versionQueue, error
 // The satisfiability checks triggered from here are constrained to operate only
 // on those dependencies induced by the list of packages given in the second
 // parameter.
+//
+// The projects responsible for each failed version are added to the queue's
+// conflict set.
 func (s *solver) findValidVersion(q *versionQueue, pl []string) error {
 	if nil == q.current() {
 		// this case should not be reachable, but reflects improper solver state
@@ -919,18 +1002,25 @@ func (s *solver) findValidVersion(q *versionQueue, pl []string) error {
 
 	for {
 		cur := q.current()
-		s.traceInfo("try %s@%s", q.id, cur)
-		err := s.check(atomWithPackages{
+		// Fetch the next version while checking this one, in case it fails.
+		if len(q.pi) > 1 {
+			s.pf.atom(q.id, q.pi[1])
+		}
+		awp := atomWithPackages{
 			a: atom{
 				id: q.id,
 				v:  cur,
 			},
 			pl: pl,
-		}, false)
+		}
+		s.traceAttempt(awp.a, awp.pl)
+		err := s.check(awp, false)
 		if err == nil {
 			// we have a good version, can return safely
 			return nil
 		}
+		s.addCulprits(q.conflicts, err)
+		s.noteFailure(err)
 
 		if q.advance(err) != nil {
 			// Error on advance, have to bail out
@@ -942,8 +1032,6 @@ func (s *solver) findValidVersion(q *versionQueue, pl []string) error {
 		}
 	}
 
-	s.fail(s.sel.getDependenciesOn(q.id)[0].depender.id)
-
 	// Return a compound error of all the new errors encountered during this
 	// attempt to find a new, valid version
 	return &noVersionError{
@@ -987,6 +1075,12 @@ func (s *solver) getLockVersionIfValid(id ProjectIdentifier) (Version, error) {
 		}
 	}
 
+	// A working tree may have changed since it was locked, so the revision
+	// in the lock can't be relied on.
+	if _, local := IsLocalSource(id.Source); local {
+		return nil, nil
+	}
+
 	lp, exists := s.rd.rlm[id.ProjectRoot]
 	if !exists {
 		return nil, nil
@@ -1005,46 +1099,57 @@ func (s *solver) getLockVersionIfValid(id ProjectIdentifier) (Version, error) {
 
 // backtrack works backwards from the current failed solution to find the next
 // solution to try.
-func (s *solver) backtrack(ctx context.Context) (bool, error) {
+//
+// Rather than working through the selections one at a time, it jumps directly
+// back to the most recent one in cs, the conflict set of the failure, as
+// choosing other versions of the projects selected since cannot avoid it. If
+// that project has no more versions to try either, it jumps back again from
+// there, with the combined conflict set. Each conflict set is also learned as
+// a nogood, so the same dead end is not explored again.
+func (s *solver) backtrack(ctx context.Context, cs conflictSet) (bool, error) {
 	if len(s.vqs) == 0 {
 		// nothing to backtrack to
 		return false, nil
 	}
+	if err := s.checkAttempts(); err != nil {
+		return false, err
+	}
 
 	donechan := ctx.Done()
 	s.mtr.push("backtrack")
 	defer s.mtr.pop()
 	for {
-		for {
-			select {
-			case <-donechan:
-				return false, ctx.Err()
-			default:
-			}
+		select {
+		case <-donechan:
+			return false, ctx.Err()
+		default:
+		}
+		if err := s.checkTimeout(); err != nil {
+			return false, err
+		}
 
-			if len(s.vqs) == 0 {
-				// no more versions, nowhere further to backtrack
-				return false, nil
-			}
-			if s.vqs[len(s.vqs)-1].failed {
+		s.learn(cs)
+
+		// Find the most recently selected project responsible for the
+		// failure.
+		target := len(s.vqs) - 1
+		for ; target >= 0; target-- {
+			if _, has := cs[s.vqs[target].id.ProjectRoot]; has {
 				break
 			}
+		}
+		if target < 0 {
+			// The failure is down to the root project alone, so there's
+			// nowhere further to backtrack.
+			return false, nil
+		}
+		s.traceBackjump(s.vqs[target], len(s.vqs)-1-target)
 
+		// Pop the projects selected since, along with their queues.
+		for len(s.vqs) > target+1 {
 			s.vqs, s.vqs[len(s.vqs)-1] = s.vqs[:len(s.vqs)-1], nil
-
-			// Pop selections off until we get to a project.
-			var proj bool
-			var awp atomWithPackages
-			for !proj {
-				var err error
-				awp, proj, err = s.unselectLast()
-				if err != nil {
-					if !contextCanceledOrSMReleased(err) {
-						panic(fmt.Sprintf("canary - should only have been able to get a context cancellation or SM release, got %T %s", err, err))
-					}
-					return false, err
-				}
-				s.traceBacktrack(awp.bmi(), !proj)
+			if _, err := s.unselectProject(); err != nil {
+				return false, err
 			}
 		}
 
@@ -1053,24 +1158,23 @@ func (s *solver) backtrack(ctx context.Context) (bool, error) {
 
 		// Walk back to the next project. This may entail walking through some
 		// package-only selections.
-		var proj bool
-		var awp atomWithPackages
-		for !proj {
-			var err error
-			awp, proj, err = s.unselectLast()
-			if err != nil {
-				if !contextCanceledOrSMReleased(err) {
-					panic(fmt.Sprintf("canary - should only have been able to get a context cancellation or SM release, got %T %s", err, err))
-				}
-				return false, err
-			}
-			s.traceBacktrack(awp.bmi(), !proj)
+		awp, err := s.unselectProject()
+		if err != nil {
+			return false, err
 		}
 
 		if !q.id.eq(awp.a.id) {
 			panic("canary - version queue stack and selected project stack are misaligned")
 		}
 
+		// The rest of the conflict set is now part of the reason the current
+		// version of this project failed.
+		for pr := range cs {
+			if pr != q.id.ProjectRoot {
+				q.conflicts[pr] = struct{}{}
+			}
+		}
+
 		// Advance the queue past the current version, which we know is bad
 		// TODO(sdboyer) is it feasible to make available the failure reason here?
 		if q.advance(nil) == nil && !q.isExhausted() {
@@ -1089,24 +1193,38 @@ func (s *solver) backtrack(ctx context.Context) (bool, error) {
 					}
 					return false, err
 				}
-				break
+				s.attempts++
+				return true, nil
 			}
 		}
 
-		s.traceBacktrack(awp.bmi(), false)
+		s.traceBacktrack(awp.bmi())
 
 		// No solution found; continue backtracking after popping the queue
-		// we just inspected off the list
+		// we just inspected off the list, from the conflict set of its
+		// project.
 		// GC-friendly pop pointer elem in slice
 		s.vqs, s.vqs[len(s.vqs)-1] = s.vqs[:len(s.vqs)-1], nil
+		cs = s.conflictsOf(q.id, q)
 	}
+}
 
-	// Backtracking was successful if loop ended before running out of versions
-	if len(s.vqs) == 0 {
-		return false, nil
+// unselectProject pops selections off until it pops that of a project, rather
+// than just some of its packages, and returns it.
+func (s *solver) unselectProject() (atomWithPackages, error) {
+	for {
+		awp, proj, err := s.unselectLast()
+		if err != nil {
+			if !contextCanceledOrSMReleased(err) {
+				panic(fmt.Sprintf("canary - should only have been able to get a context cancellation or SM release, got %T %s", err, err))
+			}
+			return atomWithPackages{}, err
+		}
+		s.traceUnselect(awp, !proj)
+		if proj {
+			return awp, nil
+		}
 	}
-	s.attempts++
-	return true, nil
 }
 
 func (s *solver) nextUnselected() (bimodalIdentifier, bool) {
@@ -1187,23 +1305,6 @@ func (s *solver) unselectedComparator(i, j int) bool {
 	return iname.Less(jname)
 }
 
-func (s *solver) fail(id ProjectIdentifier) {
-	// TODO(sdboyer) does this need updating, now that we have non-project package
-	// selection?
-
-	// skip if the root project
-	if !s.rd.isRoot(id.ProjectRoot) {
-		// just look for the first (oldest) one; the backtracker will necessarily
-		// traverse through and pop off any earlier ones
-		for _, vq := range s.vqs {
-			if vq.id.eq(id) {
-				vq.failed = true
-				return
-			}
-		}
-	}
-}
-
 // selectAtom pulls an atom into the selection stack, alongside some of
 // its contained packages. New resultant dependency requirements are added to
 // the unselected priority queue.
@@ -1229,6 +1330,9 @@ func (s *solver) selectAtom(a atomWithPackages, pkgonly bool) error {
 	// selection stack
 	a.pl = pl
 	s.sel.pushSelection(a, pkgonly)
+	if !pkgonly {
+		s.noteSelection()
+	}
 
 	// If this atom has a lock, pull it out so that we can potentially inject
 	// preferred versions into any bmis we enqueue
@@ -1238,7 +1342,7 @@ func (s *solver) selectAtom(a atomWithPackages, pkgonly bool) error {
 	// queue consumption time?
 	_, l, _ := s.b.GetManifestAndLock(a.a.id, a.a.v, s.rd.an)
 	var lmap map[ProjectIdentifier]Version
-	if l != nil {
+	if l != nil && !s.rd.minimal {
 		lmap = make(map[ProjectIdentifier]Version)
 		for _, lp := range l.Projects() {
 			lmap[lp.Ident()] = lp.Version()
@@ -1252,10 +1356,8 @@ func (s *solver) selectAtom(a atomWithPackages, pkgonly bool) error {
 		if s.rd.isRoot(dep.Ident.ProjectRoot) {
 			continue
 		}
-		// If this is dep isn't in the lock, do some prefetching. (If it is, we
-		// might be able to get away with zero network activity for it, so don't
-		// prefetch). This provides an opportunity for some parallelism wins, on
-		// two fronts:
+		// Prefetch what we'll need to select the dep. This provides an
+		// opportunity for some parallelism wins, on two fronts:
 		//
 		// 1. Because this loop may have multiple deps in it, we could end up
 		// simultaneously fetching both in the background while solving proceeds
@@ -1265,9 +1367,7 @@ func (s *solver) selectAtom(a atomWithPackages, pkgonly bool) error {
 		// few microseconds before blocking later. Best case, the dep doesn't
 		// come up next, but some other dep comes up that wasn't prefetched, and
 		// both fetches proceed in parallel.
-		if s.rd.needVersionsFor(dep.Ident.ProjectRoot) {
-			go s.b.SyncSourceFor(dep.Ident)
-		}
+		s.prefetchDep(dep.Ident, lmap[dep.Ident])
 
 		s.sel.pushDep(dependency{depender: a.a, dep: dep})
 		// Go through all the packages introduced on this dep, selecting only
@@ -1308,6 +1408,22 @@ func (s *solver) selectAtom(a atomWithPackages, pkgonly bool) error {
 	return nil
 }
 
+// prefetchDep prefetches the data the solver is likely to need when it selects
+// the dep with the given id, the preferred version of which is prefv, if any.
+func (s *solver) prefetchDep(id ProjectIdentifier, prefv Version) {
+	if s.rd.isRoot(id.ProjectRoot) {
+		return
+	}
+	if !s.rd.needVersionsFor(id.ProjectRoot) {
+		// The locked version will be tried first, and with luck, that's all
+		// we'll need.
+		s.pf.atom(id, s.rd.rlm[id.ProjectRoot].Version())
+		return
+	}
+	s.pf.atom(id, prefv)
+	s.pf.versions(id)
+}
+
 func (s *solver) unselectLast() (atomWithPackages, bool, error) {
 	s.mtr.push("unselect")
 	defer s.mtr.pop()
@@ -9,6 +9,7 @@ import (
 	"context"
 	"fmt"
 	"log"
+	"strings"
 	"sync"
 
 	"github.com/golang/dep/gps/pkgtree"
@@ -69,6 +70,7 @@ type sourceCoordinator struct {
 	cachedir   string
 	cache      sourceCache
 	logger     *log.Logger
+	offline    bool // never contact upstream; see SourceManagerConfig.Offline
 }
 
 // newSourceCoordinator returns a new sourceCoordinator.
@@ -227,8 +229,20 @@ func (sc *sourceCoordinator) getSourceGatewayFor(ctx context.Context, id Project
 		}
 		src, err := m.try(ctx, sc.cachedir)
 		if err == nil {
-			cache := sc.cache.newSingleSourceCache(id)
-			srcGate, err = newSourceGateway(ctx, src, sc.supervisor, sc.cachedir, cache)
+			var cache singleSourceCache
+			switch src.(type) {
+			case *proxySource:
+				// The revisions of a proxy are module versions, which must
+				// not be mixed up with those of the project's repository.
+				cache = sc.cache.newSingleSourceCache(ProjectIdentifier{ProjectRoot: id.ProjectRoot, Source: src.upstreamURL()})
+			case *localSource:
+				// A working tree may change from one run to the next, so
+				// nothing about it is kept in the persistent cache.
+				cache = newMemoryCache()
+			default:
+				cache = sc.cache.newSingleSourceCache(id)
+			}
+			srcGate, err = newSourceGateway(ctx, src, sc.supervisor, sc.cachedir, cache, sc.offline)
 			if err == nil {
 				sc.srcs[url] = srcGate
 				break
@@ -237,8 +251,16 @@ func (sc *sourceCoordinator) getSourceGatewayFor(ctx context.Context, id Project
 		errs = append(errs, err)
 	}
 	if srcGate == nil {
-		doReturn(nil, errs)
-		return nil, errs
+		var err error = errs
+		if len(errs) > 0 {
+			if _, ok := errs[0].(*OfflineError); ok {
+				// None of the candidates exist locally. Report just the
+				// first, rather than the same failure for each of them.
+				err = errs[0]
+			}
+		}
+		doReturn(nil, err)
+		return nil, err
 	}
 
 	// Record the name -> URL mapping, making sure that we also get the
@@ -268,11 +290,13 @@ type sourceGateway struct {
 	cache    singleSourceCache
 	mu       sync.Mutex // global lock, serializes all behaviors
 	suprvsr  *supervisor
+	offline  bool // upstream must not be contacted
 }
 
 // newSourceGateway returns a new gateway for src. If the source exists locally,
-// the local state may be cleaned, otherwise we ping upstream.
-func newSourceGateway(ctx context.Context, src source, superv *supervisor, cachedir string, cache singleSourceCache) (*sourceGateway, error) {
+// the local state may be cleaned, otherwise we ping upstream. If offline is true,
+// the gateway serves only what is available locally.
+func newSourceGateway(ctx context.Context, src source, superv *supervisor, cachedir string, cache singleSourceCache, offline bool) (*sourceGateway, error) {
 	var state sourceState
 	local := src.existsLocally(ctx)
 	if local {
@@ -288,6 +312,7 @@ func newSourceGateway(ctx context.Context, src source, superv *supervisor, cache
 		cachedir: cachedir,
 		cache:    cache,
 		suprvsr:  superv,
+		offline:  offline,
 	}
 
 	if !local {
@@ -301,7 +326,12 @@ func newSourceGateway(ctx context.Context, src source, superv *supervisor, cache
 
 func (sg *sourceGateway) syncLocal(ctx context.Context) error {
 	sg.mu.Lock()
-	err := sg.require(ctx, sourceExistsLocally|sourceHasLatestLocally)
+	wanted := sourceExistsLocally | sourceHasLatestLocally
+	if sg.offline {
+		// The local repository is as up to date as it can get.
+		wanted = sourceExistsLocally
+	}
+	err := sg.require(ctx, wanted)
 	sg.mu.Unlock()
 	return err
 }
@@ -349,6 +379,8 @@ func (sg *sourceGateway) exportVersionTo(ctx context.Context, v Version, to stri
 			err = sg.suprvsr.do(ctx, sg.src.upstreamURL(), ctExportTree, func(ctx context.Context) error {
 				return sg.src.exportRevisionTo(ctx, r, to)
 			})
+		} else {
+			err = withMissingRevision(err, r)
 		}
 	}
 
@@ -420,7 +452,7 @@ func (sg *sourceGateway) getManifestAndLock(ctx context.Context, pr ProjectRoot,
 		// situations like this
 		err = sg.require(ctx, sourceHasLatestLocally)
 		if err != nil {
-			return nil, nil, err
+			return nil, nil, withMissingRevision(err, r)
 		}
 
 		err = sg.suprvsr.do(ctx, label, ctGetManifestAndLock, func(ctx context.Context) error {
@@ -473,7 +505,7 @@ func (sg *sourceGateway) listPackages(ctx context.Context, pr ProjectRoot, v Ver
 		// situations like this
 		err = sg.require(ctx, sourceHasLatestLocally)
 		if err != nil {
-			return pkgtree.PackageTree{}, err
+			return pkgtree.PackageTree{}, withMissingRevision(err, r)
 		}
 
 		err = sg.suprvsr.do(ctx, label, ctListPackages, func(ctx context.Context) error {
@@ -507,7 +539,7 @@ func (sg *sourceGateway) convertToRevision(ctx context.Context, v Version) (Revi
 	// intentionally by the caller, not automatically here.
 	r, has := sg.cache.toRevision(v)
 	if has {
-		return r, nil
+		return sg.resolveRevision(ctx, r)
 	}
 
 	if sg.srcState&sourceHasLatestVersionList != 0 {
@@ -528,7 +560,42 @@ func (sg *sourceGateway) convertToRevision(ctx context.Context, v Version) (Revi
 		return "", fmt.Errorf("version %q does not exist in source", v)
 	}
 
-	return r, nil
+	return sg.resolveRevision(ctx, r)
+}
+
+// resolveRevision returns the real revision for r, if it is provisional, and
+// records it as the revision of the versions paired with r from then on.
+//
+// caller must hold sg.mu.
+func (sg *sourceGateway) resolveRevision(ctx context.Context, r Revision) (Revision, error) {
+	ps, ok := sg.src.(sourceProvisionalRevisions)
+	if !ok || !isProvisionalRevision(r) {
+		return r, nil
+	}
+
+	err := sg.require(ctx, sourceExistsLocally)
+	if err != nil {
+		return "", err
+	}
+
+	var real Revision
+	err = sg.suprvsr.do(ctx, sg.src.upstreamURL(), ctSourceFetch, func(ctx context.Context) error {
+		real, err = ps.resolveRevision(ctx, r)
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if pvs, has := sg.cache.getAllVersions(); has {
+		for k, pv := range pvs {
+			if pv.Revision() == r {
+				pvs[k] = pv.Unpair().Pair(real)
+			}
+		}
+		sg.cache.setVersionMap(pvs)
+	}
+	return real, nil
 }
 
 func (sg *sourceGateway) listVersions(ctx context.Context) ([]PairedVersion, error) {
@@ -569,6 +636,41 @@ func (sg *sourceGateway) revisionPresentIn(ctx context.Context, r Revision) (boo
 	return present, err
 }
 
+// localPathWith returns the path of the local repository, after making sure
+// that it exists and holds each of revs.
+func (sg *sourceGateway) localPathWith(ctx context.Context, revs []Revision) (string, error) {
+	sg.mu.Lock()
+	defer sg.mu.Unlock()
+
+	if err := sg.require(ctx, sourceExistsLocally); err != nil {
+		return "", err
+	}
+
+	for _, r := range revs {
+		present, err := sg.src.revisionPresentIn(r)
+		if err != nil {
+			return "", err
+		}
+		if present {
+			continue
+		}
+
+		if sg.srcState&sourceHasLatestLocally == 0 {
+			if err := sg.require(ctx, sourceHasLatestLocally); err != nil {
+				return "", withMissingRevision(err, r)
+			}
+			if present, err = sg.src.revisionPresentIn(r); err != nil {
+				return "", err
+			}
+		}
+		if !present {
+			return "", errors.Errorf("revision %s is not present in %s", r, sg.src.upstreamURL())
+		}
+	}
+
+	return sg.src.localPath(), nil
+}
+
 func (sg *sourceGateway) disambiguateRevision(ctx context.Context, r Revision) (Revision, error) {
 	sg.mu.Lock()
 	defer sg.mu.Unlock()
@@ -584,6 +686,13 @@ func (sg *sourceGateway) disambiguateRevision(ctx context.Context, r Revision) (
 // sourceExistsUpstream verifies that the source exists upstream and that the
 // upstreamURL has not changed and returns any additional sourceState, or an error.
 func (sg *sourceGateway) sourceExistsUpstream(ctx context.Context) (sourceState, error) {
+	if sg.offline {
+		// The best we can do is to trust a local repository.
+		if !sg.src.existsLocally(ctx) {
+			return 0, sg.offlineError("clone")
+		}
+		return sourceExistsLocally, nil
+	}
 	if sg.src.existsCallsListVersions() {
 		return sg.loadLatestVersionList(ctx)
 	}
@@ -598,6 +707,9 @@ func (sg *sourceGateway) sourceExistsUpstream(ctx context.Context) (sourceState,
 
 // initLocal initializes the source locally and returns the resulting sourceState.
 func (sg *sourceGateway) initLocal(ctx context.Context) (sourceState, error) {
+	if sg.offline {
+		return 0, sg.offlineError("clone")
+	}
 	if err := sg.suprvsr.do(ctx, sg.src.sourceType(), ctSourceInit, func(ctx context.Context) error {
 		err := sg.src.initLocal(ctx)
 		return errors.Wrapf(err, "failed to fetch source for %s", sg.src.upstreamURL())
@@ -610,6 +722,10 @@ func (sg *sourceGateway) initLocal(ctx context.Context) (sourceState, error) {
 // loadLatestVersionList loads the latest version list, possibly ensuring the source
 // exists locally first, and returns the resulting sourceState.
 func (sg *sourceGateway) loadLatestVersionList(ctx context.Context) (sourceState, error) {
+	if sg.offline {
+		return sg.loadLocalVersionList(ctx)
+	}
+
 	var addlState sourceState
 	if sg.src.listVersionsRequiresLocal() && !sg.src.existsLocally(ctx) {
 		as, err := sg.initLocal(ctx)
@@ -630,6 +746,44 @@ func (sg *sourceGateway) loadLatestVersionList(ctx context.Context) (sourceState
 	return addlState | sourceHasLatestVersionList, nil
 }
 
+// localVersionLister is implemented by sources whose listVersions contacts
+// upstream, but that can also list the versions their local repository knows of.
+type localVersionLister interface {
+	listLocalVersions(context.Context) ([]PairedVersion, error)
+}
+
+// loadLocalVersionList loads the version list from the local repository, for
+// use in offline mode, and returns the resulting sourceState.
+func (sg *sourceGateway) loadLocalVersionList(ctx context.Context) (sourceState, error) {
+	if !sg.src.existsLocally(ctx) {
+		return 0, sg.offlineError("list versions of")
+	}
+
+	list := sg.src.listVersions
+	if lvl, ok := sg.src.(localVersionLister); ok {
+		list = lvl.listLocalVersions
+	} else if !sg.src.listVersionsRequiresLocal() {
+		return 0, sg.offlineError("list versions of")
+	}
+
+	var pvl []PairedVersion
+	if err := sg.suprvsr.do(ctx, sg.src.sourceType(), ctListVersions, func(ctx context.Context) error {
+		var err error
+		pvl, err = list(ctx)
+		return errors.Wrapf(err, "failed to list local versions for %s", sg.src.upstreamURL())
+	}); err != nil {
+		return 0, err
+	}
+	sg.cache.setVersionMap(pvl)
+	return sourceExistsLocally | sourceHasLatestVersionList, nil
+}
+
+// offlineError returns the error for an operation on the source that cannot be
+// done without contacting upstream.
+func (sg *sourceGateway) offlineError(op string) error {
+	return &OfflineError{Source: sg.src.upstreamURL(), Op: op}
+}
+
 // require ensures the sourceGateway has the wanted sourceState, fetching more
 // data if necessary. Returns an error if the state could not be reached.
 // caller must hold sg.mu
@@ -655,6 +809,10 @@ func (sg *sourceGateway) require(ctx context.Context, wanted sourceState) (err e
 					addlState, err = sg.loadLatestVersionList(ctx)
 				}
 			case sourceHasLatestLocally:
+				if sg.offline {
+					err = sg.offlineError("fetch")
+					break
+				}
 				err = sg.suprvsr.do(ctx, sg.src.sourceType(), ctSourceFetch, func(ctx context.Context) error {
 					return sg.src.updateLocal(ctx)
 				})
@@ -683,6 +841,7 @@ type source interface {
 	existsLocally(context.Context) bool
 	existsUpstream(context.Context) bool
 	upstreamURL() string
+	localPath() string
 	initLocal(context.Context) error
 	updateLocal(context.Context) error
 	// maybeClean is a no-op when the underlying source does not support cleaning.
@@ -706,3 +865,19 @@ type sourceFastPrune interface {
 	source
 	exportPrunedRevisionTo(context.Context, Revision, []string, PruneOptions, string) error
 }
+
+// provisionalRevisionPrefix begins the revisions that a source pairs with
+// versions whose real revisions are costly to learn, until they are used.
+const provisionalRevisionPrefix = "provisional:"
+
+func isProvisionalRevision(r Revision) bool {
+	return strings.HasPrefix(string(r), provisionalRevisionPrefix)
+}
+
+// sourceProvisionalRevisions is implemented by sources that pair versions with
+// provisional revisions. The sourceGateway resolves them to real ones before
+// they are used, so the source itself is never passed one.
+type sourceProvisionalRevisions interface {
+	source
+	resolveRevision(context.Context, Revision) (Revision, error)
+}
@@ -529,3 +529,161 @@ func (s *singleSourceCacheBolt) updateRevBucket(rev Revision, update func(b *bol
 		return update(b)
 	})
 }
+
+// deductionCacheBolt persists the go get metadata retrieved for import paths in
+// a boltCache, so that later runs need not retrieve it again. Its methods are
+// safe for concurrent use, and for use on a nil *deductionCacheBolt, which
+// caches nothing.
+//
+// The metadata for each root has a top-level bucket, prefixed so as not to
+// clash with the buckets of sources:
+//
+//	Bucket: "#<root>"
+//	Sub-Bucket: "d<timestamp>"
+//	Keys/Values: "r": root, "v": vcs type, "u": repo URL
+type deductionCacheBolt struct {
+	*boltCache
+	epoch   int64 // getMetadata will not return values older than this unix timestamp
+	refresh bool  // getMetadata never returns values, but setMetadata still stores them
+	owned   bool  // the boltCache is not shared with a sourceCache
+}
+
+// cacheDeductionName returns the name of the top-level bucket for the go get
+// metadata of root.
+func cacheDeductionName(root string) []byte {
+	return append([]byte{cacheDeductionPrefix}, root...)
+}
+
+// getMetadata returns the go get metadata cached for the longest prefix of
+// path, and whether there was any.
+func (c *deductionCacheBolt) getMetadata(path string) (root, vcs, reporoot string, ok bool) {
+	if c == nil || c.refresh {
+		return "", "", "", false
+	}
+
+	err := c.db.View(func(tx *bolt.Tx) error {
+		for p := path; p != ""; {
+			if b := tx.Bucket(cacheDeductionName(p)); b != nil {
+				if d := cacheFindLatestValid(b, cacheDeduction, c.epoch); d != nil {
+					root = string(d.Get(cacheKeyRoot))
+					vcs = string(d.Get(cacheKeyVCS))
+					reporoot = string(d.Get(cacheKeyRepoURL))
+					ok = strings.HasPrefix(path, root)
+					return nil
+				}
+			}
+			i := strings.LastIndex(p, "/")
+			if i < 0 {
+				break
+			}
+			p = p[:i]
+		}
+		return nil
+	})
+	if err != nil {
+		c.logger.Println(errors.Wrapf(err, "failed to get go get metadata for %q from cache", path))
+		return "", "", "", false
+	}
+	return root, vcs, reporoot, ok
+}
+
+// setMetadata caches the go get metadata retrieved for path.
+func (c *deductionCacheBolt) setMetadata(path, root, vcs, reporoot string) {
+	if c == nil {
+		return
+	}
+
+	// Metadata is looked up by the prefixes of paths, so key it by its root,
+	// unless that is not a path prefix.
+	key := root
+	if !isPathPrefixOrEqual(root, path) {
+		key = path
+	}
+	err := c.db.Update(func(tx *bolt.Tx) error {
+		name := cacheDeductionName(key)
+		b, err := tx.CreateBucketIfNotExists(name)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create bucket: %s", name)
+		}
+		if err := cachePrefixDelete(b, cacheDeduction); err != nil {
+			return err
+		}
+		d, err := b.CreateBucket(cacheTimestampedKey(cacheDeduction, time.Now()))
+		if err != nil {
+			return err
+		}
+		if err := d.Put(cacheKeyRoot, []byte(root)); err != nil {
+			return err
+		}
+		if err := d.Put(cacheKeyVCS, []byte(vcs)); err != nil {
+			return err
+		}
+		return d.Put(cacheKeyRepoURL, []byte(reporoot))
+	})
+	if err != nil {
+		c.logger.Println(errors.Wrapf(err, "failed to cache go get metadata for %q", path))
+	}
+}
+
+// copyBoltSources copies the top-level buckets of the named sources from src
+// into dst, replacing any that dst already holds. A nil names copies every
+// source in src. It returns the number of sources copied; buckets of cached go
+// get metadata are copied like those of sources, but not counted.
+func copyBoltSources(dst, src *bolt.DB, names [][]byte) (int, error) {
+	var n int
+	err := src.View(func(stx *bolt.Tx) error {
+		if names == nil {
+			err := stx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+				names = append(names, append([]byte(nil), name...))
+				return nil
+			})
+			if err != nil {
+				return errors.Wrap(err, "failed to list buckets")
+			}
+		}
+
+		return dst.Update(func(dtx *bolt.Tx) error {
+			for _, name := range names {
+				sb := stx.Bucket(name)
+				if sb == nil {
+					continue
+				}
+				if dtx.Bucket(name) != nil {
+					if err := dtx.DeleteBucket(name); err != nil {
+						return errors.Wrapf(err, "failed to delete bucket: %s", name)
+					}
+				}
+				db, err := dtx.CreateBucket(name)
+				if err != nil {
+					return errors.Wrapf(err, "failed to create bucket: %s", name)
+				}
+				if err := copyBoltBucket(db, sb); err != nil {
+					return errors.Wrapf(err, "failed to copy bucket: %s", name)
+				}
+				if name[0] != cacheDeductionPrefix {
+					n++
+				}
+			}
+			return nil
+		})
+	})
+	return n, err
+}
+
+// copyBoltBucket recursively copies the contents of src into the empty bucket
+// dst.
+func copyBoltBucket(dst, src *bolt.Bucket) error {
+	if err := dst.SetSequence(src.Sequence()); err != nil {
+		return err
+	}
+	return src.ForEach(func(k, v []byte) error {
+		if v != nil {
+			return dst.Put(append([]byte(nil), k...), append([]byte(nil), v...))
+		}
+		sub, err := dst.CreateBucket(append([]byte(nil), k...))
+		if err != nil {
+			return err
+		}
+		return copyBoltBucket(sub, src.Bucket(k))
+	})
+}
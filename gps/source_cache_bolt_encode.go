@@ -31,9 +31,14 @@ var (
 	cacheKeyRequired     = []byte("r")
 	cacheKeyRevision     = cacheKeyRequired
 	cacheKeyTestImport   = []byte("t")
-
-	cacheRevision = byte('r')
-	cacheVersion  = byte('v')
+	cacheKeyRoot         = cacheKeyRequired
+	cacheKeyRepoURL      = []byte("u")
+	cacheKeyVCS          = []byte("v")
+
+	cacheDeduction       = byte('d')
+	cacheDeductionPrefix = byte('#')
+	cacheRevision        = byte('r')
+	cacheVersion         = byte('v')
 )
 
 // propertiesFromCache returns a new ProjectRoot and ProjectProperties with the fields from m.
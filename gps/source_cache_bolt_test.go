@@ -310,3 +310,49 @@ func TestBoltCacheTimeout(t *testing.T) {
 		}
 	}
 }
+
+func TestDeductionCacheBolt(t *testing.T) {
+	cpath, err := ioutil.TempDir("", "deductioncache")
+	if err != nil {
+		t.Fatalf("Failed to create temp cache dir: %s", err)
+	}
+	bc, err := newBoltCache(cpath, 0, log.New(test.Writer{TB: t}, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bc.close()
+
+	c := &deductionCacheBolt{boltCache: bc}
+	c.setMetadata("example.org/vanity/pkg", "example.org/vanity", "git", "https://git.example.org/vanity")
+
+	for _, path := range []string{"example.org/vanity", "example.org/vanity/pkg", "example.org/vanity/other/pkg"} {
+		root, vcs, reporoot, ok := c.getMetadata(path)
+		if !ok {
+			t.Errorf("expected cached metadata for %s", path)
+			continue
+		}
+		if root != "example.org/vanity" || vcs != "git" || reporoot != "https://git.example.org/vanity" {
+			t.Errorf("unexpected metadata for %s: %q, %q, %q", path, root, vcs, reporoot)
+		}
+	}
+	for _, path := range []string{"example.org", "example.org/vanityfoo", "example.com/vanity"} {
+		if _, _, _, ok := c.getMetadata(path); ok {
+			t.Errorf("expected no cached metadata for %s", path)
+		}
+	}
+
+	expired := &deductionCacheBolt{boltCache: bc, epoch: time.Now().Add(time.Hour).Unix()}
+	if _, _, _, ok := expired.getMetadata("example.org/vanity/pkg"); ok {
+		t.Error("expected metadata older than the epoch to be ignored")
+	}
+	refresh := &deductionCacheBolt{boltCache: bc, refresh: true}
+	if _, _, _, ok := refresh.getMetadata("example.org/vanity/pkg"); ok {
+		t.Error("expected cached metadata to be ignored when refreshing")
+	}
+
+	var none *deductionCacheBolt
+	none.setMetadata("example.org/vanity", "example.org/vanity", "git", "https://git.example.org/vanity")
+	if _, _, _, ok := none.getMetadata("example.org/vanity"); ok {
+		t.Error("expected a nil cache to hold nothing")
+	}
+}
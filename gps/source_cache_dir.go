@@ -0,0 +1,369 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Masterminds/vcs"
+	"github.com/boltdb/bolt"
+	"github.com/pkg/errors"
+)
+
+// CachedSource describes a source repository that a SourceMgr has cloned into
+// its cache directory.
+type CachedSource struct {
+	Path string // Absolute path of the local repository.
+	URL  string // Remote URL the repository was cloned from, if known.
+	Type string // The VCS type - "git", "hg", "bzr" or "svn" - or empty if it cannot be detected.
+	Size int64  // Bytes used on disk.
+	// Partial is true for a git partial clone, which lacks the contents of
+	// the files that have not been needed yet.
+	Partial bool
+	// LastUsed is the latest modification time of the repository's VCS
+	// metadata, which changes whenever the source is fetched or checked out.
+	LastUsed time.Time
+}
+
+// CachedSources lists the source repositories held in the cache directory,
+// ordered by path.
+//
+// Together with the other methods for cached sources, it allows tools to
+// manage the cache directory while holding the SourceMgr's lock on it.
+// RemoveCachedSource and VerifyCachedSource should not be used on sources that
+// the SourceMgr has already been asked about.
+func (sm *SourceMgr) CachedSources() ([]CachedSource, error) {
+	if atomic.LoadInt32(&sm.releasing) == 1 {
+		return nil, ErrSourceManagerIsReleased
+	}
+
+	dir := filepath.Join(sm.cachedir, "sources")
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read source cache directory %s", dir)
+	}
+
+	srcs := make([]CachedSource, 0, len(fis))
+	for _, fi := range fis {
+		if !fi.IsDir() {
+			continue
+		}
+
+		cs, err := newCachedSource(filepath.Join(dir, fi.Name()), fi)
+		if err != nil {
+			return nil, err
+		}
+		srcs = append(srcs, cs)
+	}
+
+	sort.Slice(srcs, func(i, j int) bool {
+		return srcs[i].Path < srcs[j].Path
+	})
+	return srcs, nil
+}
+
+// CachedSourceFor makes sure that the source for id is in the cache directory,
+// cloning it or fetching from upstream only if needed to make the provided
+// revisions present locally, and describes it.
+func (sm *SourceMgr) CachedSourceFor(ctx context.Context, id ProjectIdentifier, revs ...Revision) (CachedSource, error) {
+	if atomic.LoadInt32(&sm.releasing) == 1 {
+		return CachedSource{}, ErrSourceManagerIsReleased
+	}
+
+	srcg, err := sm.srcCoord.getSourceGatewayFor(ctx, id)
+	if err != nil {
+		return CachedSource{}, err
+	}
+
+	path, err := srcg.localPathWith(ctx, revs)
+	if err != nil {
+		return CachedSource{}, err
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return CachedSource{}, errors.Wrapf(err, "failed to stat %s", path)
+	}
+	return newCachedSource(path, fi)
+}
+
+// PersistentCachePath returns the path of the file backing the persistent
+// metadata cache. It only exists if a SourceMgr has been created with a
+// positive CacheAge or DeductionCacheAge for the same cache directory.
+func (sm *SourceMgr) PersistentCachePath() string {
+	return filepath.Join(sm.cachedir, boltCacheFilename)
+}
+
+// ExportPersistentCache writes the persistent cache entries for the provided
+// projects, including the go get metadata cached for their roots, to a new
+// cache file at path, and returns how many of the projects had entries. Nothing
+// is written if there is no persistent cache.
+func (sm *SourceMgr) ExportPersistentCache(ids []ProjectIdentifier, path string) (int, error) {
+	if atomic.LoadInt32(&sm.releasing) == 1 {
+		return 0, ErrSourceManagerIsReleased
+	}
+
+	names := make([][]byte, 0, 2*len(ids))
+	for _, id := range ids {
+		names = append(names, []byte(id.normalizedSource()), cacheDeductionName(string(id.ProjectRoot)))
+	}
+
+	var n int
+	err := sm.withPersistentCache(false, func(src *bolt.DB) error {
+		dst, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second})
+		if err != nil {
+			return errors.Wrapf(err, "failed to create cache file %q", path)
+		}
+		n, err = copyBoltSources(dst, src, names)
+		if cerr := dst.Close(); err == nil {
+			err = cerr
+		}
+		return err
+	})
+	return n, err
+}
+
+// ImportPersistentCache copies every entry in the cache file at path, as
+// written by ExportPersistentCache, into the persistent cache, replacing any
+// that it already holds for the same sources. It returns the number of sources
+// imported.
+func (sm *SourceMgr) ImportPersistentCache(path string) (int, error) {
+	if atomic.LoadInt32(&sm.releasing) == 1 {
+		return 0, ErrSourceManagerIsReleased
+	}
+
+	src, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second, ReadOnly: true})
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to open cache file %q", path)
+	}
+	defer src.Close()
+
+	var n int
+	err = sm.withPersistentCache(true, func(dst *bolt.DB) error {
+		n, err = copyBoltSources(dst, src, nil)
+		return err
+	})
+	return n, err
+}
+
+// withPersistentCache calls fn with the persistent cache database. If sm has
+// not opened it, it is opened just for the call, and created if create is
+// true; otherwise fn is not called if it does not exist.
+func (sm *SourceMgr) withPersistentCache(create bool, fn func(*bolt.DB) error) error {
+	if mc, ok := sm.srcCoord.cache.(*multiCache); ok {
+		if bc, ok := mc.disk.(*boltCache); ok {
+			return fn(bc.db)
+		}
+	}
+	if dc := sm.deduceCoord.cache; dc != nil {
+		return fn(dc.db)
+	}
+
+	path := sm.PersistentCachePath()
+	if _, err := os.Stat(path); os.IsNotExist(err) && !create {
+		return nil
+	}
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return errors.Wrapf(err, "failed to open BoltDB cache file %q", path)
+	}
+	defer db.Close()
+	return fn(db)
+}
+
+// CompleteCachedSource fetches the contents of all the files missing from the
+// cached source if it is a partial clone, and makes it a full clone, so that
+// it can be copied elsewhere and used without its upstream. It returns the
+// updated description of the source.
+func (sm *SourceMgr) CompleteCachedSource(ctx context.Context, cs CachedSource) (CachedSource, error) {
+	if atomic.LoadInt32(&sm.releasing) == 1 {
+		return cs, ErrSourceManagerIsReleased
+	}
+	if !cs.Partial {
+		return cs, nil
+	}
+	if sm.srcCoord.offline {
+		return cs, errors.Errorf("%s is a partial clone, and cannot be completed offline", cs.Path)
+	}
+
+	if err := completePartialGitClone(ctx, cs.Path); err != nil {
+		return cs, errors.Wrapf(err, "failed to complete the partial clone %s", cs.Path)
+	}
+	fi, err := os.Stat(cs.Path)
+	if err != nil {
+		return cs, errors.Wrapf(err, "failed to stat %s", cs.Path)
+	}
+	return newCachedSource(cs.Path, fi)
+}
+
+// RemoveCachedSource deletes the local repository of the cached source, so
+// that it is cloned again the next time it is needed.
+func (sm *SourceMgr) RemoveCachedSource(cs CachedSource) error {
+	if atomic.LoadInt32(&sm.releasing) == 1 {
+		return ErrSourceManagerIsReleased
+	}
+
+	dir := filepath.Join(sm.cachedir, "sources")
+	if filepath.Dir(cs.Path) != dir {
+		return errors.Errorf("%s is not a source in the cache directory %s", cs.Path, dir)
+	}
+	return errors.Wrapf(os.RemoveAll(cs.Path), "failed to remove %s", cs.Path)
+}
+
+// ErrCachedSourceUnverifiable is returned by VerifyCachedSource for cached
+// sources that have no integrity checks to run: svn working copies, whose
+// data is held upstream, and the downloads of archive and module proxy
+// sources.
+var ErrCachedSourceUnverifiable = errors.New("the cached source cannot be verified")
+
+// VerifyCachedSource runs the integrity checks of the cached source's VCS on
+// its local repository. It returns an error describing the problem if the
+// repository is corrupt, or is not a repository at all, or
+// ErrCachedSourceUnverifiable if there are no checks to run.
+func (sm *SourceMgr) VerifyCachedSource(ctx context.Context, cs CachedSource) error {
+	if atomic.LoadInt32(&sm.releasing) == 1 {
+		return ErrSourceManagerIsReleased
+	}
+
+	var args []string
+	switch vcs.Type(cs.Type) {
+	case vcs.Git:
+		args = []string{"git", "fsck", "--no-progress", "--no-dangling"}
+	case vcs.Hg:
+		args = []string{"hg", "verify", "--quiet"}
+	case vcs.Bzr:
+		args = []string{"bzr", "check"}
+	case vcs.Svn:
+		return ErrCachedSourceUnverifiable
+	default:
+		if isDownloadedSource(cs.Path) {
+			return ErrCachedSourceUnverifiable
+		}
+		return errors.Errorf("%s is not a recognized repository", cs.Path)
+	}
+
+	cmd := commandContext(ctx, args[0], args[1:]...)
+	cmd.SetDir(cs.Path)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return vcs.NewLocalError("repository failed integrity checks", errors.Wrapf(err, "command failed: %v", args), string(out))
+	}
+	return nil
+}
+
+// isDownloadedSource reports whether dir holds the downloads of an archive or
+// module proxy source: archives extracted into directories named by their
+// digests, the files recording those digests, and modules extracted into
+// directories named by their versions.
+func isDownloadedSource(dir string) bool {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil || len(fis) == 0 {
+		return false
+	}
+	for _, fi := range fis {
+		name := fi.Name()
+		switch {
+		case strings.HasPrefix(name, "."):
+			// Left by an interrupted download.
+		case !fi.IsDir():
+			if !strings.HasSuffix(name, ".digest") {
+				return false
+			}
+		case len(name) == 64 && revisionRegex.MatchString(name):
+		default:
+			v, ok := unescapeModulePath(name)
+			if !ok || !moduleVersionRegex.MatchString(v) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// newCachedSource describes the local repository at path, whose directory has
+// the FileInfo fi.
+func newCachedSource(path string, fi os.FileInfo) (CachedSource, error) {
+	cs := CachedSource{
+		Path:     path,
+		LastUsed: fi.ModTime(),
+	}
+	if typ, err := vcs.DetectVcsFromFS(path); err == nil {
+		cs.Type = string(typ)
+		cs.URL = cachedSourceRemote(typ, path)
+		cs.Partial = typ == vcs.Git && isPartialGitClone(context.TODO(), path)
+		if t := latestModTime(filepath.Join(path, "."+cs.Type)); t.After(cs.LastUsed) {
+			cs.LastUsed = t
+		}
+	}
+
+	var err error
+	if cs.Size, err = dirSize(path); err != nil {
+		return cs, errors.Wrapf(err, "failed to compute size of %s", path)
+	}
+	return cs, nil
+}
+
+// cachedSourceRemote returns the remote URL configured for the local
+// repository at path, or an empty string if it cannot be determined.
+func cachedSourceRemote(typ vcs.Type, path string) string {
+	var r vcs.Repo
+	var err error
+	switch typ {
+	case vcs.Git:
+		r, err = vcs.NewGitRepo("", path)
+	case vcs.Hg:
+		r, err = vcs.NewHgRepo("", path)
+	case vcs.Bzr:
+		r, err = vcs.NewBzrRepo("", path)
+	case vcs.Svn:
+		r, err = vcs.NewSvnRepo("", path)
+	default:
+		return ""
+	}
+	if err != nil {
+		return ""
+	}
+	return r.Remote()
+}
+
+// latestModTime returns the latest modification time of dir and its
+// immediate entries.
+func latestModTime(dir string) time.Time {
+	var latest time.Time
+	if fi, err := os.Stat(dir); err == nil {
+		latest = fi.ModTime()
+	}
+	fis, _ := ioutil.ReadDir(dir)
+	for _, fi := range fis {
+		if fi.ModTime().After(latest) {
+			latest = fi.ModTime()
+		}
+	}
+	return latest
+}
+
+// dirSize returns the total size of the regular files beneath dir.
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.Mode().IsRegular() {
+			size += fi.Size()
+		}
+		return nil
+	})
+	return size, err
+}
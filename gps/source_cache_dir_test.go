@@ -0,0 +1,144 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/dep/internal/test"
+)
+
+func TestCachedSources(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	sm, clean := mkNaiveSM(t)
+	defer clean()
+
+	sources := filepath.Join(sm.cachedir, "sources")
+	gitDir := filepath.Join(sources, "https---example.com-foo")
+	if out, err := exec.Command("git", "init", "-q", gitDir).CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %s: %s", err, out)
+	}
+	if out, err := exec.Command("git", "-C", gitDir, "remote", "add", "origin", "https://example.com/foo").CombinedOutput(); err != nil {
+		t.Fatalf("git remote add failed: %s: %s", err, out)
+	}
+
+	junkDir := filepath.Join(sources, "https---example.com-junk")
+	if err := os.Mkdir(junkDir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(junkDir, "file"), []byte("12345"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	srcs, err := sm.CachedSources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(srcs) != 2 {
+		t.Fatalf("expected 2 cached sources, got %d", len(srcs))
+	}
+
+	git, junk := srcs[0], srcs[1]
+	if git.Path != gitDir || git.Type != "git" || git.URL != "https://example.com/foo" {
+		t.Errorf("unexpected git source: %+v", git)
+	}
+	if git.Size == 0 || git.LastUsed.IsZero() {
+		t.Errorf("expected git source to have a size and last use time: %+v", git)
+	}
+	if junk.Path != junkDir || junk.Type != "" || junk.URL != "" || junk.Size != 5 {
+		t.Errorf("unexpected junk source: %+v", junk)
+	}
+
+	ctx := context.Background()
+	if err := sm.VerifyCachedSource(ctx, git); err != nil {
+		t.Errorf("expected git source to verify, got %s", err)
+	}
+	if err := sm.VerifyCachedSource(ctx, junk); err == nil || err == ErrCachedSourceUnverifiable {
+		t.Errorf("expected junk source to be corrupt, got %v", err)
+	}
+
+	archiveDir := filepath.Join(sources, "https---example.com-archive.tgz")
+	digest := strings.Repeat("0123456789abcdef", 4)
+	if err := os.MkdirAll(filepath.Join(archiveDir, digest), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(archiveDir, "v1.0.0.digest"), []byte(archiveRevisionPrefix+digest+"\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := sm.VerifyCachedSource(ctx, CachedSource{Path: archiveDir}); err != ErrCachedSourceUnverifiable {
+		t.Errorf("expected archive source to be unverifiable, got %v", err)
+	}
+
+	if err := sm.RemoveCachedSource(CachedSource{Path: sm.cachedir}); err == nil {
+		t.Error("expected removal of a path outside the sources directory to fail")
+	}
+	if err := sm.RemoveCachedSource(junk); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(junkDir); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed", junkDir)
+	}
+}
+
+func TestPersistentCacheExportImport(t *testing.T) {
+	from, cleanFrom := mkNaiveSM(t)
+	defer cleanFrom()
+	to, cleanTo := mkNaiveSM(t)
+	defer cleanTo()
+
+	foo := ProjectIdentifier{ProjectRoot: "example.com/foo"}
+	bar := ProjectIdentifier{ProjectRoot: "example.com/bar"}
+	pvs := []PairedVersion{NewVersion("v1.0.0").Pair("rev1"), NewBranch("master").Pair("rev2")}
+
+	bc, err := newBoltCache(from.cachedir, 0, log.New(test.Writer{TB: t}, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bc.newSingleSourceCache(foo).setVersionMap(pvs)
+	bc.newSingleSourceCache(bar).setVersionMap(pvs)
+	if err := bc.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	bundle := filepath.Join(from.cachedir, "export.db")
+	n, err := from.ExportPersistentCache([]ProjectIdentifier{foo, {ProjectRoot: "example.com/none"}}, bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("expected entries for 1 source to be exported, got %d", n)
+	}
+
+	if n, err = to.ImportPersistentCache(bundle); err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("expected entries for 1 source to be imported, got %d", n)
+	}
+
+	bc, err = newBoltCache(to.cachedir, 0, log.New(test.Writer{TB: t}, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bc.close()
+
+	got, ok := bc.newSingleSourceCache(foo).getAllVersions()
+	if !ok || len(got) != len(pvs) {
+		t.Errorf("expected the versions of %s to be imported, got %v", foo, got)
+	}
+	if got, ok := bc.newSingleSourceCache(bar).getAllVersions(); ok {
+		t.Errorf("expected no versions of %s to be imported, got %v", bar, got)
+	}
+}
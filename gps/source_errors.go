@@ -5,6 +5,8 @@
 package gps
 
 import (
+	"fmt"
+
 	"github.com/Masterminds/vcs"
 	"github.com/pkg/errors"
 )
@@ -33,3 +35,32 @@ func unwrapVcsErr(err error) error {
 	}
 	return errors.Wrap(cause, msg)
 }
+
+// OfflineError is returned by a SourceManager in offline mode for operations
+// that cannot be completed without contacting upstream.
+type OfflineError struct {
+	// Source is the URL of the source, or the import path whose source could
+	// not be deduced.
+	Source string
+	// Op describes the operation that was needed, e.g. "clone" or "fetch".
+	Op string
+	// Revision is the revision that is missing from the local repository, if
+	// the operation was needed to retrieve it.
+	Revision Revision
+}
+
+func (e *OfflineError) Error() string {
+	if e.Revision != "" {
+		return fmt.Sprintf("revision %s is not present locally in %s, and cannot %s it while offline", e.Revision, e.Source, e.Op)
+	}
+	return fmt.Sprintf("cannot %s %s while offline", e.Op, e.Source)
+}
+
+// withMissingRevision records r as the revision that an *OfflineError failed to
+// retrieve. Other errors are returned unchanged.
+func withMissingRevision(err error, r Revision) error {
+	if oe, ok := err.(*OfflineError); ok {
+		oe.Revision = r
+	}
+	return err
+}
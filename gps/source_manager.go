@@ -190,6 +190,43 @@ type SourceManagerConfig struct {
 	Cachedir       string        // Where to store local instances of upstream sources.
 	Logger         *log.Logger   // Optional info/warn logger. Discards if nil.
 	DisableLocking bool          // True if the SourceManager should NOT use a lock file to protect the Cachedir from multiple processes.
+	// Offline prevents the SourceManager from contacting upstream sources,
+	// including for go get metadata. Only local repositories in the Cachedir
+	// and the persistent cache, regardless of its age, are used; operations
+	// that need more return an *OfflineError.
+	Offline bool
+	// URLRewrites are rules for rewriting the URLs that sources are fetched
+	// from, e.g. to use a mirror. They apply to every source, including those
+	// of transitive dependencies.
+	URLRewrites []URLRewrite
+	// Deducers are rules for deducing the sources of import paths on hosts
+	// that gps does not know about, in addition to its built-in ones.
+	Deducers []CustomDeducer
+	// DeductionCacheAge is the maximum valid age of the go get metadata kept
+	// in the persistent cache. <=0: Don't cache it. Offline, it is used
+	// regardless of its age.
+	DeductionCacheAge time.Duration
+	// RefreshDeductions causes cached go get metadata to be retrieved again,
+	// replacing what the persistent cache holds. It is ignored when Offline.
+	RefreshDeductions bool
+	// GoProxy lists the Go module proxies to fetch projects from, in the
+	// order they are tried, like the GOPROXY environment variable of the go
+	// command. Each entry is the base URL of a proxy, or "direct" to fetch
+	// from the sources deduced for import paths. If it is empty, only those
+	// sources are used.
+	GoProxy []string
+	// LinkLocal causes projects whose source is a working tree on disk, as
+	// returned by LocalSource, to be exported as symlinks to it, rather than
+	// as pruned copies of it.
+	LinkLocal bool
+	// PartialClone causes git sources to be cloned as partial clones, without
+	// the contents of files, which are fetched from upstream as revisions are
+	// checked out or exported. If upstream does not support partial clones,
+	// they are cloned in full.
+	PartialClone bool
+	// HTTP configures the client that retrieves go get metadata, and that
+	// fetches from Go module proxies.
+	HTTP HTTPConfig
 }
 
 // NewSourceManager produces an instance of gps's built-in SourceManager.
@@ -212,7 +249,24 @@ func NewSourceManager(c SourceManagerConfig) (*SourceMgr, error) {
 		c.Logger = log.New(ioutil.Discard, "", 0)
 	}
 
-	err := fs.EnsureDir(filepath.Join(c.Cachedir, "sources"), 0777)
+	rewrites, err := newURLRewrites(c.URLRewrites)
+	if err != nil {
+		return nil, err
+	}
+	customDeducers, err := newCustomDeducers(c.Deducers)
+	if err != nil {
+		return nil, err
+	}
+	client, err := newHTTPClient(c.HTTP)
+	if err != nil {
+		return nil, err
+	}
+	proxies, err := newGoProxies(c.GoProxy)
+	if err != nil {
+		return nil, err
+	}
+
+	err = fs.EnsureDir(filepath.Join(c.Cachedir, "sources"), 0777)
 	if err != nil {
 		return nil, err
 	}
@@ -292,26 +346,55 @@ func NewSourceManager(c SourceManagerConfig) (*SourceMgr, error) {
 	ctx, cf := context.WithCancel(context.TODO())
 	superv := newSupervisor(ctx)
 	deducer := newDeductionCoordinator(superv)
+	deducer.offline = c.Offline
+	deducer.rewrites = rewrites
+	deducer.client = client
+	deducer.proxies = proxies
+	deducer.linkLocal = c.LinkLocal
+	deducer.partialClone = c.PartialClone
+	for prefix, d := range customDeducers {
+		deducer.deducext.Insert(prefix, d)
+	}
 
 	var sc sourceCache
-	if c.CacheAge > 0 {
-		// Try to open the BoltDB cache from disk.
-		epoch := time.Now().Add(-c.CacheAge).Unix()
+	if c.CacheAge > 0 || c.DeductionCacheAge > 0 || c.Offline {
+		// Try to open the BoltDB cache from disk. Offline, stale data is
+		// better than none.
+		var epoch, deductionEpoch int64
+		if !c.Offline {
+			now := time.Now()
+			epoch = now.Add(-c.CacheAge).Unix()
+			deductionEpoch = now.Add(-c.DeductionCacheAge).Unix()
+		}
 		boltCache, err := newBoltCache(c.Cachedir, epoch, c.Logger)
 		if err != nil {
 			c.Logger.Println(errors.Wrapf(err, "failed to open persistent cache %q", c.Cachedir))
 		} else {
-			sc = newMultiCache(memoryCache{}, boltCache)
+			shared := c.CacheAge > 0 || c.Offline
+			if shared {
+				sc = newMultiCache(memoryCache{}, boltCache)
+			}
+			if c.DeductionCacheAge > 0 || c.Offline {
+				deducer.cache = &deductionCacheBolt{
+					boltCache: boltCache,
+					epoch:     deductionEpoch,
+					refresh:   c.RefreshDeductions && !c.Offline,
+					owned:     !shared,
+				}
+			}
 		}
 	}
 
+	srcCoord := newSourceCoordinator(superv, deducer, c.Cachedir, sc, c.Logger)
+	srcCoord.offline = c.Offline
+
 	sm := &SourceMgr{
 		cachedir:    c.Cachedir,
 		lf:          lockfile,
 		suprvsr:     superv,
 		cancelAll:   cf,
 		deduceCoord: deducer,
-		srcCoord:    newSourceCoordinator(superv, deducer, c.Cachedir, sc, c.Logger),
+		srcCoord:    srcCoord,
 		qch:         make(chan struct{}),
 	}
 
@@ -416,8 +499,10 @@ func (sm *SourceMgr) Release() {
 		sm.cancelAll()
 		sm.suprvsr.wait()
 
-		// Close the source coordinator.
+		// Close the source coordinator, and the deduction coordinator's
+		// cache, if it has its own.
 		sm.srcCoord.close()
+		sm.deduceCoord.close()
 
 		// Close the file handle for the lock file and remove it from disk
 		sm.lf.Unlock()
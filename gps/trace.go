@@ -21,7 +21,50 @@ const (
 	innerIndent   = "  "
 )
 
+// tracing reports whether the solver has a TraceLogger or a Tracer to report
+// its progress to.
+func (s *solver) tracing() bool {
+	return s.tl != nil || s.tr != nil
+}
+
+// traceEvent sends e to the Tracer, if there is one.
+func (s *solver) traceEvent(e TraceEvent) {
+	if s.tr == nil {
+		return
+	}
+	e.Depth = len(s.sel.projects)
+	s.tr.Trace(e)
+}
+
+// atomEvent returns a TraceEvent of the given type, concerning a.
+func atomEvent(typ string, a atomWithPackages) TraceEvent {
+	return TraceEvent{
+		Type:     typ,
+		Project:  string(a.a.id.ProjectRoot),
+		Source:   a.a.id.Source,
+		Version:  a.a.v.String(),
+		Packages: a.pl,
+	}
+}
+
+// bmiEvent returns a TraceEvent of the given type, concerning bmi.
+func bmiEvent(typ string, bmi bimodalIdentifier) TraceEvent {
+	return TraceEvent{
+		Type:     typ,
+		Project:  string(bmi.id.ProjectRoot),
+		Source:   bmi.id.Source,
+		Packages: bmi.pl,
+	}
+}
+
 func (s *solver) traceCheckPkgs(bmi bimodalIdentifier) {
+	if !s.tracing() {
+		return
+	}
+
+	e := bmiEvent(TraceVisit, bmi)
+	e.PkgOnly = true
+	s.traceEvent(e)
 	if s.tl == nil {
 		return
 	}
@@ -31,6 +74,13 @@ func (s *solver) traceCheckPkgs(bmi bimodalIdentifier) {
 }
 
 func (s *solver) traceCheckQueue(q *versionQueue, bmi bimodalIdentifier, cont bool, offset int) {
+	if !s.tracing() {
+		return
+	}
+
+	e := bmiEvent(TraceVisit, bmi)
+	e.Versions, e.Continue = len(q.pi), cont
+	s.traceEvent(e)
 	if s.tl == nil {
 		return
 	}
@@ -59,6 +109,15 @@ func (s *solver) traceCheckQueue(q *versionQueue, bmi bimodalIdentifier, cont bo
 // traceStartBacktrack is called with the bmi that first failed, thus initiating
 // backtracking
 func (s *solver) traceStartBacktrack(bmi bimodalIdentifier, err error, pkgonly bool) {
+	if !s.tracing() {
+		return
+	}
+
+	e := bmiEvent(TraceBacktrack, bmi)
+	e.PkgOnly = pkgonly
+	r := ReportFailure(err)
+	e.Failure = &r
+	s.traceEvent(e)
 	if s.tl == nil {
 		return
 	}
@@ -74,13 +133,39 @@ func (s *solver) traceStartBacktrack(bmi bimodalIdentifier, err error, pkgonly b
 	s.tl.Printf("%s\n", tracePrefix(msg, prefix, prefix))
 }
 
-// traceBacktrack is called when a package or project is poppped off during
+// traceUnselect is called when a package or project is popped off during
 // backtracking
-func (s *solver) traceBacktrack(bmi bimodalIdentifier, pkgonly bool) {
+func (s *solver) traceUnselect(awp atomWithPackages, pkgonly bool) {
+	if !s.tracing() {
+		return
+	}
+
+	e := atomEvent(TraceUnselect, awp)
+	e.PkgOnly = pkgonly
+	s.traceEvent(e)
 	if s.tl == nil {
 		return
 	}
 
+	s.traceBacktrackMsg(awp.bmi(), pkgonly)
+}
+
+// traceBacktrack is called when backtracking continues past a project, as
+// there are no more versions of it to try
+func (s *solver) traceBacktrack(bmi bimodalIdentifier) {
+	if !s.tracing() {
+		return
+	}
+
+	s.traceEvent(bmiEvent(TraceBacktrack, bmi))
+	if s.tl == nil {
+		return
+	}
+
+	s.traceBacktrackMsg(bmi, false)
+}
+
+func (s *solver) traceBacktrackMsg(bmi bimodalIdentifier, pkgonly bool) {
 	var msg string
 	if pkgonly {
 		msg = fmt.Sprintf("%s backtrack: popped %v pkgs from %s", backChar, len(bmi.pl), bmi.id)
@@ -92,8 +177,42 @@ func (s *solver) traceBacktrack(bmi bimodalIdentifier, pkgonly bool) {
 	s.tl.Printf("%s\n", tracePrefix(msg, prefix, prefix))
 }
 
+// traceBackjump is called when backtracking jumps back to the selection of q's
+// project, skipping over n more recent projects that did not cause the failure.
+func (s *solver) traceBackjump(q *versionQueue, n int) {
+	if !s.tracing() || n == 0 {
+		return
+	}
+
+	s.traceEvent(TraceEvent{
+		Type:    TraceBackjump,
+		Project: string(q.id.ProjectRoot),
+		Source:  q.id.Source,
+		Skipped: n,
+	})
+	if s.tl == nil {
+		return
+	}
+
+	msg := fmt.Sprintf("%s backjump: skip %v projects back to %s", backChar, n, q.id)
+	prefix := getprei(len(s.sel.projects))
+	s.tl.Printf("%s\n", tracePrefix(msg, prefix, prefix))
+}
+
 // Called just once after solving has finished, whether success or not
 func (s *solver) traceFinish(sol solution, err error) {
+	if !s.tracing() {
+		return
+	}
+
+	e := TraceEvent{Type: TraceFinish, Attempts: s.attempts}
+	if err == nil {
+		e.Projects = len(sol.Projects())
+	} else {
+		r := ReportFailure(err)
+		e.Failure = &r
+	}
+	s.traceEvent(e)
 	if s.tl == nil {
 		return
 	}
@@ -111,6 +230,11 @@ func (s *solver) traceFinish(sol solution, err error) {
 
 // traceSelectRoot is called just once, when the root project is selected
 func (s *solver) traceSelectRoot(ptree pkgtree.PackageTree, cdeps []completeDep) {
+	if !s.tracing() {
+		return
+	}
+
+	s.traceEvent(TraceEvent{Type: TraceSelectRoot, Project: s.rd.rpt.ImportRoot})
 	if s.tl == nil {
 		return
 	}
@@ -134,6 +258,13 @@ func (s *solver) traceSelectRoot(ptree pkgtree.PackageTree, cdeps []completeDep)
 
 // traceSelect is called when an atom is successfully selected
 func (s *solver) traceSelect(awp atomWithPackages, pkgonly bool) {
+	if !s.tracing() {
+		return
+	}
+
+	e := atomEvent(TraceSelect, awp)
+	e.PkgOnly = pkgonly
+	s.traceEvent(e)
 	if s.tl == nil {
 		return
 	}
@@ -149,6 +280,30 @@ func (s *solver) traceSelect(awp atomWithPackages, pkgonly bool) {
 	s.tl.Printf("%s\n", tracePrefix(msg, prefix, prefix))
 }
 
+// traceAttempt is called when the solver checks whether a could be selected
+func (s *solver) traceAttempt(a atom, pl []string) {
+	if !s.tracing() {
+		return
+	}
+
+	s.traceEvent(atomEvent(TraceAttempt, atomWithPackages{a: a, pl: pl}))
+	s.traceInfo("try %s@%s", a.id, a.v)
+}
+
+// traceReject is called when a check of awp fails with err
+func (s *solver) traceReject(awp atomWithPackages, pkgonly bool, err error) {
+	if !s.tracing() {
+		return
+	}
+
+	e := atomEvent(TraceReject, awp)
+	e.PkgOnly = pkgonly
+	r := ReportFailure(err)
+	e.Failure = &r
+	s.traceEvent(e)
+	s.traceInfo(err)
+}
+
 func (s *solver) traceInfo(args ...interface{}) {
 	if s.tl == nil {
 		return
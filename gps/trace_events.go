@@ -0,0 +1,128 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// A Tracer receives events describing the steps a solver takes, as it takes
+// them. Unlike the output of SolveParameters.TraceLogger, which is meant to be
+// read by people, the events are meant to be processed by tools, for example
+// to visualize a solve, or to compare two of them.
+type Tracer interface {
+	Trace(TraceEvent)
+}
+
+// The types of TraceEvent.
+const (
+	// TraceSelectRoot is the first event of every solve. Project is the
+	// import root of the root project.
+	TraceSelectRoot = "select-root"
+	// TraceVisit is sent when the solver starts to look for a version of
+	// Project that satisfies the current selection, or, if PkgOnly, to check
+	// that Packages can be added to its selected version.
+	TraceVisit = "visit"
+	// TraceAttempt is sent when the solver checks whether Project at Version
+	// could be selected.
+	TraceAttempt = "attempt"
+	// TraceReject is sent when a check fails, with the reason in Failure.
+	TraceReject = "reject"
+	// TraceSelect is sent when Project at Version is selected, or, if
+	// PkgOnly, Packages are added to it.
+	TraceSelect = "select"
+	// TraceBacktrack is sent when no version of Project can be selected, or,
+	// if PkgOnly, Packages can't be added to it, so the solver backtracks. It
+	// is sent again for each project that backtracking then moves past, as
+	// none of its remaining versions can be selected either. Failure is the
+	// reason, when backtracking starts.
+	TraceBacktrack = "backtrack"
+	// TraceUnselect is sent when the solver undoes a selection while
+	// backtracking.
+	TraceUnselect = "unselect"
+	// TraceBackjump is sent when the solver backtracks straight to the
+	// selection of Project, skipping over as many as Skipped more recent
+	// selections, as they did not cause the failure.
+	TraceBackjump = "backjump"
+	// TraceFinish is the last event of every solve. If it failed, Failure
+	// describes why; otherwise Projects is the number of projects in the
+	// solution.
+	TraceFinish = "finish"
+)
+
+// TraceEvent describes a step a solver took. Which fields are set depends on
+// the Type of event.
+type TraceEvent struct {
+	// Type is one of the Trace* constants.
+	Type string `json:"Type"`
+	// Depth is the number of selections, including that of the root project,
+	// in effect when the event occurred.
+	Depth   int    `json:"Depth"`
+	Project string `json:"Project,omitempty"`
+	Source  string `json:"Source,omitempty"`
+	Version string `json:"Version,omitempty"`
+	// Packages are the packages of Project that the event concerns.
+	Packages []string `json:"Packages,omitempty"`
+	// PkgOnly is true if the event concerns packages of a project that is
+	// already selected, rather than its selection.
+	PkgOnly bool `json:"PkgOnly,omitempty"`
+	// Versions is the number of versions of Project left to try, for
+	// TraceVisit. More may be found later if Project's version list has not
+	// been fully loaded yet.
+	Versions int `json:"Versions,omitempty"`
+	// Continue is true for a TraceVisit to a project whose selection was
+	// undone by backtracking, to try the rest of its versions.
+	Continue bool `json:"Continue,omitempty"`
+	// Skipped is the number of selections that a TraceBackjump skips.
+	Skipped int `json:"Skipped,omitempty"`
+	// Failure is the reason for a TraceReject or TraceBacktrack, or for the
+	// failure of the solve, for TraceFinish.
+	Failure *FailureReport `json:"Failure,omitempty"`
+	// Projects is the number of projects in the solution, for TraceFinish.
+	Projects int `json:"Projects,omitempty"`
+	// Attempts is the number of times the solver backtracked, for
+	// TraceFinish.
+	Attempts int `json:"Attempts,omitempty"`
+}
+
+// JSONTracer is a Tracer that writes each event to an io.Writer as a line of
+// JSON.
+type JSONTracer struct {
+	mu  sync.Mutex
+	w   *bufio.Writer
+	enc *json.Encoder
+	err error
+}
+
+// NewJSONTracer returns a JSONTracer that writes to w. Writes are buffered;
+// Flush must be called once the solve is done.
+func NewJSONTracer(w io.Writer) *JSONTracer {
+	bw := bufio.NewWriter(w)
+	return &JSONTracer{w: bw, enc: json.NewEncoder(bw)}
+}
+
+// Trace writes e. Once a write has failed, further events are discarded, and
+// the error is returned by Flush.
+func (t *JSONTracer) Trace(e TraceEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err == nil {
+		t.err = t.enc.Encode(e)
+	}
+}
+
+// Flush writes any buffered events, and returns the first error encountered
+// while writing them, if any.
+func (t *JSONTracer) Flush() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err == nil {
+		t.err = t.w.Flush()
+	}
+	return t.err
+}
@@ -0,0 +1,105 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+type recordingTracer []TraceEvent
+
+func (t *recordingTracer) Trace(e TraceEvent) {
+	*t = append(*t, e)
+}
+
+func TestTraceEvents(t *testing.T) {
+	fix := basicFixtures["nogood learned from exhausted dependency"]
+	var tr recordingTracer
+	params := SolveParameters{
+		RootDir:         string(fix.ds[0].n),
+		RootPackageTree: fix.rootTree(),
+		Manifest:        fix.rootmanifest(),
+		ProjectAnalyzer: naiveAnalyzer{},
+		Tracer:          &tr,
+	}
+	if _, err := fixSolve(params, newdepspecSM(fix.ds, nil), t); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tr) < 2 || tr[0].Type != TraceSelectRoot || tr[len(tr)-1].Type != TraceFinish {
+		t.Fatalf("expected the events to begin with %s and end with %s, got %v", TraceSelectRoot, TraceFinish, tr)
+	}
+	if fin := tr[len(tr)-1]; fin.Failure != nil || fin.Projects != 2 || fin.Attempts == 0 {
+		t.Errorf("unexpected %s event: %#v", TraceFinish, fin)
+	}
+
+	// Replaying the selections gives the solution, and the depth of each.
+	sel := map[string]string{}
+	depth := 1
+	counts := map[string]int{}
+	for _, e := range tr {
+		counts[e.Type]++
+		switch e.Type {
+		case TraceSelect:
+			depth++
+			if e.Depth != depth {
+				t.Errorf("expected a depth of %d after %s %s@%s, got %d", depth, e.Type, e.Project, e.Version, e.Depth)
+			}
+			sel[e.Project] = e.Version
+		case TraceUnselect:
+			depth--
+			if e.Depth != depth {
+				t.Errorf("expected a depth of %d after %s %s@%s, got %d", depth, e.Type, e.Project, e.Version, e.Depth)
+			}
+			delete(sel, e.Project)
+		case TraceReject:
+			if e.Failure == nil || e.Version == "" {
+				t.Errorf("expected a %s event to have a version and a failure, got %#v", e.Type, e)
+			}
+		}
+	}
+	if want := map[string]string{"g": "1.0.0", "h": "3.0.0"}; !reflect.DeepEqual(sel, want) {
+		t.Errorf("expected the selections to replay to %v, got %v", want, sel)
+	}
+	for _, typ := range []string{TraceVisit, TraceAttempt, TraceReject, TraceBacktrack, TraceUnselect} {
+		if counts[typ] == 0 {
+			t.Errorf("expected at least one %s event", typ)
+		}
+	}
+}
+
+func TestJSONTracer(t *testing.T) {
+	events := []TraceEvent{
+		{Type: TraceSelectRoot, Depth: 1, Project: "root"},
+		{Type: TraceReject, Depth: 2, Project: "foo", Version: "1.0.0", Failure: &FailureReport{Kind: FailureVersionNotAllowed}},
+		{Type: TraceFinish, Depth: 2, Projects: 1},
+	}
+
+	var buf bytes.Buffer
+	tr := NewJSONTracer(&buf)
+	for _, e := range events {
+		tr.Trace(e)
+	}
+	if err := tr.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []TraceEvent
+	sc := bufio.NewScanner(&buf)
+	for sc.Scan() {
+		var e TraceEvent
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			t.Fatalf("line %d: %s", len(got)+1, err)
+		}
+		got = append(got, e)
+	}
+	if !reflect.DeepEqual(got, events) {
+		t.Errorf("unexpected events:\n\t(GOT): %#v\n\t(WNT): %#v", got, events)
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// URLRewrite is a rule for rewriting the URLs that sources are fetched from,
+// similar to git's url.<base>.insteadOf. Import paths and project roots are
+// unaffected; only the URL that is cloned and fetched changes.
+type URLRewrite struct {
+	// From is the prefix of the URLs to rewrite. If it has a scheme, as in
+	// "https://github.com/", it is matched against whole URLs. Otherwise, as in
+	// "github.com/", it is matched against the host and path of URLs of any
+	// scheme.
+	From string
+	// To replaces the matched prefix, and must begin with a scheme, as in
+	// "https://git-mirror.corp/github.com/".
+	To string
+}
+
+// urlRewrites is a set of URLRewrite rules, which are applied to the
+// maybeSources of path deductions. When several rules match a URL, the one with
+// the longest From wins.
+type urlRewrites []URLRewrite
+
+func newURLRewrites(rules []URLRewrite) (urlRewrites, error) {
+	for _, r := range rules {
+		if r.From == "" {
+			return nil, errors.Errorf("URL rewrite to %q has no prefix to rewrite", r.To)
+		}
+		if u, err := url.Parse(r.To); err != nil || u.Scheme == "" {
+			return nil, errors.Errorf("URL rewrite of %q must be to an absolute URL, not %q", r.From, r.To)
+		}
+	}
+	return urlRewrites(rules), nil
+}
+
+// rewriteURL returns the rewritten form of u, or u itself if no rule matches.
+func (rules urlRewrites) rewriteURL(u *url.URL) (*url.URL, error) {
+	full := u.String()
+	hostPath := u.Host + u.Path
+
+	var best URLRewrite
+	var rest string
+	for _, r := range rules {
+		if len(r.From) <= len(best.From) {
+			continue
+		}
+		s := hostPath
+		if strings.Contains(r.From, "://") {
+			s = full
+		}
+		if strings.HasPrefix(s, r.From) {
+			best, rest = r, s[len(r.From):]
+		}
+	}
+	if best.From == "" {
+		return u, nil
+	}
+
+	nu, err := url.Parse(best.To + rest)
+	if err != nil {
+		return nil, errors.Wrapf(err, "rewriting %s to %s gave an invalid URL", full, best.To+rest)
+	}
+	return nu, nil
+}
+
+// rewrite applies the rules to the upstream URLs of mbs. Sources that end up
+// with the same URL as an earlier one are dropped.
+func (rules urlRewrites) rewrite(mbs maybeSources) (maybeSources, error) {
+	seen := make(map[string]bool, len(mbs))
+	out := make(maybeSources, 0, len(mbs))
+	for _, mb := range mbs {
+		var err error
+		switch m := mb.(type) {
+		case maybeGitSource:
+			m.url, err = rules.rewriteURL(m.url)
+			mb = m
+		case maybeGopkginSource:
+			m.url, err = rules.rewriteURL(m.url)
+			mb = m
+		case maybeBzrSource:
+			m.url, err = rules.rewriteURL(m.url)
+			mb = m
+		case maybeHgSource:
+			m.url, err = rules.rewriteURL(m.url)
+			mb = m
+		case maybeSvnSource:
+			m.url, err = rules.rewriteURL(m.url)
+			mb = m
+		case maybeArchiveSource:
+			if m.url, err = rules.rewriteURL(m.url); err == nil && m.index != nil {
+				m.index, err = rules.rewriteURL(m.index)
+			}
+			mb = m
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		// maybeGopkginSource reports its gopkg.in URL, so use the String form,
+		// which includes the actual upstream URL, as the key.
+		key := mb.String()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		out = append(out, mb)
+	}
+	return out, nil
+}
@@ -0,0 +1,128 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gps
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestNewURLRewrites(t *testing.T) {
+	bad := [][]URLRewrite{
+		{{From: "", To: "https://mirror.corp/"}},
+		{{From: "github.com/", To: "mirror.corp/github.com/"}},
+		{{From: "github.com/", To: ""}},
+	}
+	for _, rules := range bad {
+		if _, err := newURLRewrites(rules); err == nil {
+			t.Errorf("expected rules %v to be rejected", rules)
+		}
+	}
+}
+
+func TestURLRewritesRewrite(t *testing.T) {
+	rules, err := newURLRewrites([]URLRewrite{
+		{From: "github.com/", To: "https://git-mirror.corp/github.com/"},
+		{From: "github.com/sdboyer/", To: "ssh://git@git-mirror.corp/sdboyer/"},
+		{From: "https://bitbucket.org/", To: "https://bb-mirror.corp/"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	table := []struct {
+		in   maybeSources
+		want maybeSources
+	}{
+		{
+			// All schemes are rewritten to the mirror, and then folded together.
+			in: maybeSources{
+				maybeGitSource{url: mkurl("https://github.com/golang/dep")},
+				maybeGitSource{url: mkurl("ssh://git@github.com/golang/dep")},
+				maybeGitSource{url: mkurl("git://github.com/golang/dep")},
+				maybeGitSource{url: mkurl("http://github.com/golang/dep")},
+			},
+			want: maybeSources{
+				maybeGitSource{url: mkurl("https://git-mirror.corp/github.com/golang/dep")},
+			},
+		},
+		{
+			// The longest matching prefix wins.
+			in: maybeSources{
+				maybeGitSource{url: mkurl("https://github.com/sdboyer/gps")},
+			},
+			want: maybeSources{
+				maybeGitSource{url: mkurl("ssh://git@git-mirror.corp/sdboyer/gps")},
+			},
+		},
+		{
+			// A prefix with a scheme only matches that scheme.
+			in: maybeSources{
+				maybeHgSource{url: mkurl("https://bitbucket.org/foo/bar")},
+				maybeHgSource{url: mkurl("ssh://hg@bitbucket.org/foo/bar")},
+			},
+			want: maybeSources{
+				maybeHgSource{url: mkurl("https://bb-mirror.corp/foo/bar")},
+				maybeHgSource{url: mkurl("ssh://hg@bitbucket.org/foo/bar")},
+			},
+		},
+		{
+			// The gopkg.in path is kept; only the upstream URL changes.
+			in: maybeSources{
+				maybeGopkginSource{opath: "gopkg.in/yaml.v2", url: mkurl("https://github.com/go-yaml/yaml"), major: 2},
+			},
+			want: maybeSources{
+				maybeGopkginSource{opath: "gopkg.in/yaml.v2", url: mkurl("https://git-mirror.corp/github.com/go-yaml/yaml"), major: 2},
+			},
+		},
+		{
+			// The index of an archive source is fetched from the mirror too.
+			in: maybeSources{
+				maybeArchiveSource{url: mkurl("https://bitbucket.org/foo/sdk-{version}.zip"), format: archiveZip, index: mkurl("https://bitbucket.org/foo/index")},
+			},
+			want: maybeSources{
+				maybeArchiveSource{url: mkurl("https://bb-mirror.corp/foo/sdk-%7Bversion%7D.zip"), format: archiveZip, index: mkurl("https://bb-mirror.corp/foo/index")},
+			},
+		},
+		{
+			in: maybeSources{
+				maybeGitSource{url: mkurl("https://golang.org/x/net")},
+			},
+			want: maybeSources{
+				maybeGitSource{url: mkurl("https://golang.org/x/net")},
+			},
+		},
+	}
+
+	for _, fix := range table {
+		got, err := rules.rewrite(fix.in)
+		if err != nil {
+			t.Errorf("unexpected error rewriting %v: %s", fix.in, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, fix.want) {
+			t.Errorf("rewrite(%v):\n\t(GOT): %v\n\t(WNT): %v", fix.in, got, fix.want)
+		}
+	}
+}
+
+func TestDeduceRootPathRewritesURLs(t *testing.T) {
+	ctx := context.Background()
+	dc := newDeductionCoordinator(newSupervisor(ctx))
+	dc.rewrites = urlRewrites{{From: "github.com/", To: "https://git-mirror.corp/github.com/"}}
+
+	pd, err := dc.deduceRootPath(ctx, "github.com/golang/dep/gps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pd.root != "github.com/golang/dep" {
+		t.Errorf("expected the root to be unaffected by rewriting, got %q", pd.root)
+	}
+	want := maybeSources{maybeGitSource{url: mkurl("https://git-mirror.corp/github.com/golang/dep")}}
+	if !reflect.DeepEqual(pd.mb, want) {
+		t.Errorf("unexpected sources:\n\t(GOT): %v\n\t(WNT): %v", pd.mb, want)
+	}
+}
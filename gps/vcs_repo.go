@@ -38,6 +38,14 @@ type ensureCleaner interface {
 
 type gitRepo struct {
 	*vcs.GitRepo
+	// partial causes the repository to be cloned as a partial clone, without
+	// the contents of files, which git fetches as they are needed.
+	partial bool
+	// offline forbids fetching the files missing from a partial clone.
+	offline bool
+	// isPartial records whether the local clone is a partial clone, once it
+	// is known; it may have been cloned by an earlier run, or imported.
+	isPartial *bool
 }
 
 func newVcsRemoteErrorOr(err error, args []string, out, msg string) error {
@@ -55,23 +63,172 @@ func newVcsLocalErrorOr(err error, args []string, out, msg string) error {
 }
 
 func (r *gitRepo) get(ctx context.Context) error {
+	r.isPartial = nil
+	if r.partial {
+		// Servers that do not support filters send everything, so a failure
+		// here is most likely a git too old to know about partial clones. In
+		// any case, fall back to a full clone.
+		err := r.clone(ctx, "--filter=blob:none")
+		if err == nil || ctx.Err() != nil {
+			return err
+		}
+		if err := os.RemoveAll(r.LocalPath()); err != nil {
+			return err
+		}
+	}
+	return r.clone(ctx)
+}
+
+func (r *gitRepo) clone(ctx context.Context, args ...string) error {
+	args = append([]string{"clone", "--recursive", "-v", "--progress"}, args...)
+	cmd := commandContext(ctx, "git", append(args, r.Remote(), r.LocalPath())...)
+	// Ensure no prompting for PWs
+	cmd.SetEnv(append([]string{"GIT_ASKPASS=", "GIT_TERMINAL_PROMPT=0"}, os.Environ()...))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return newVcsRemoteErrorOr(err, cmd.Args(), string(out),
+			"unable to get repository")
+	}
+
+	return nil
+}
+
+// partialClone reports whether the local clone is a partial clone, whatever
+// the partial setting of r.
+func (r *gitRepo) partialClone(ctx context.Context) bool {
+	if r.isPartial == nil {
+		partial := isPartialGitClone(ctx, r.LocalPath())
+		r.isPartial = &partial
+	}
+	return *r.isPartial
+}
+
+// prefetch fetches the contents of the files of rev that are missing from a
+// partial clone, in a single batch. Left to itself, git fetches them one at a
+// time as they are read. If the repository is offline, they cannot be
+// fetched at all.
+func (r *gitRepo) prefetch(ctx context.Context, rev string) error {
+	if !r.partialClone(ctx) {
+		return nil
+	}
+
+	missing, err := missingGitObjects(ctx, r.LocalPath(), rev+"^{tree}")
+	if err != nil || len(missing) == 0 {
+		return err
+	}
+	if r.offline {
+		return errors.Errorf("files of %s are missing from the partial clone of %s, and cannot be fetched offline", rev, r.Remote())
+	}
+	return fetchGitObjects(ctx, r.LocalPath(), r.RemoteLocation, missing)
+}
+
+// readEnv returns the environment for git commands that read the files of a
+// revision, which must not fetch any that are missing while offline; nil
+// stands for the environment of dep itself.
+func (r *gitRepo) readEnv(ctx context.Context) []string {
+	if !r.offline || !r.partialClone(ctx) {
+		return nil
+	}
+	return append(os.Environ(), "GIT_NO_LAZY_FETCH=1")
+}
+
+// missingGitObjects lists the objects reachable from revs that are missing
+// from the partial clone in dir, one per line.
+func missingGitObjects(ctx context.Context, dir string, revs ...string) ([]byte, error) {
+	cmd := commandContext(ctx, "git", append([]string{"rev-list", "--objects", "--missing=print"}, revs...)...)
+	cmd.SetDir(dir)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, newVcsLocalErrorOr(err, cmd.Args(), string(out), "unable to list missing objects")
+	}
+
+	var missing bytes.Buffer
+	for _, line := range bytes.Split(out, []byte("\n")) {
+		if bytes.HasPrefix(line, []byte("?")) {
+			missing.Write(line[1:])
+			missing.WriteByte('\n')
+		}
+	}
+	return missing.Bytes(), nil
+}
+
+// fetchGitObjects fetches the objects listed in missing, one per line, from
+// remote into the partial clone in dir, in a single batch.
+func fetchGitObjects(ctx context.Context, dir, remote string, missing []byte) error {
 	cmd := commandContext(
 		ctx,
 		"git",
-		"clone",
-		"--recursive",
-		"-v",
-		"--progress",
-		r.Remote(),
-		r.LocalPath(),
+		"-c", "fetch.negotiationAlgorithm=noop",
+		"fetch",
+		"--no-tags",
+		"--no-write-fetch-head",
+		"--recurse-submodules=no",
+		"--filter=blob:none",
+		"--stdin",
+		remote,
 	)
+	cmd.SetDir(dir)
 	// Ensure no prompting for PWs
 	cmd.SetEnv(append([]string{"GIT_ASKPASS=", "GIT_TERMINAL_PROMPT=0"}, os.Environ()...))
+	cmd.Cmd.Stdin = bytes.NewReader(missing)
 	if out, err := cmd.CombinedOutput(); err != nil {
-		return newVcsRemoteErrorOr(err, cmd.Args(), string(out),
-			"unable to get repository")
+		return newVcsRemoteErrorOr(err, cmd.Args(), string(out), "unable to fetch missing objects")
+	}
+	return nil
+}
+
+// partialCloneConfig holds the configuration keys that make a git repository
+// a partial clone; older versions of git use extensions.partialclone.
+var partialCloneConfig = []string{"remote.origin.promisor", "remote.origin.partialclonefilter", "extensions.partialclone"}
+
+// gitConfig returns the value of key in the configuration of the git
+// repository in dir, and whether it is set.
+func gitConfig(ctx context.Context, dir, key string) (string, bool) {
+	cmd := commandContext(ctx, "git", "config", "--get", key)
+	cmd.SetDir(dir)
+	out, err := cmd.CombinedOutput()
+	return strings.TrimSpace(string(out)), err == nil
+}
+
+// isPartialGitClone reports whether the git repository in dir is a partial
+// clone.
+func isPartialGitClone(ctx context.Context, dir string) bool {
+	if v, _ := gitConfig(ctx, dir, "remote.origin.promisor"); v == "true" {
+		return true
+	}
+	_, has := gitConfig(ctx, dir, "extensions.partialclone")
+	return has
+}
+
+// completePartialGitClone fetches everything missing from the partial clone
+// in dir, and then turns it into a full clone, so that it does not depend on
+// its remote anymore.
+func completePartialGitClone(ctx context.Context, dir string) error {
+	missing, err := missingGitObjects(ctx, dir, "--all")
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		if err := fetchGitObjects(ctx, dir, "origin", missing); err != nil {
+			return err
+		}
+		if missing, err = missingGitObjects(ctx, dir, "--all"); err != nil {
+			return err
+		}
+		if len(missing) > 0 {
+			return errors.Errorf("objects are still missing from the partial clone in %s after fetching them", dir)
+		}
 	}
 
+	for _, key := range partialCloneConfig {
+		if _, has := gitConfig(ctx, dir, key); !has {
+			continue
+		}
+		cmd := commandContext(ctx, "git", "config", "--unset", key)
+		cmd.SetDir(dir)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			return newVcsLocalErrorOr(err, cmd.Args(), string(out), "unable to unset "+key)
+		}
+	}
 	return nil
 }
 
@@ -95,8 +252,15 @@ func (r *gitRepo) fetch(ctx context.Context) error {
 }
 
 func (r *gitRepo) updateVersion(ctx context.Context, v string) error {
+	// checkout would fetch the files missing from a partial clone one at a
+	// time.
+	if err := r.prefetch(ctx, v); err != nil {
+		return err
+	}
+
 	cmd := commandContext(ctx, "git", "checkout", v)
 	cmd.SetDir(r.LocalPath())
+	cmd.SetEnv(r.readEnv(ctx))
 	if out, err := cmd.CombinedOutput(); err != nil {
 		return newVcsLocalErrorOr(err, cmd.Args(), string(out),
 			"unable to update checked out version")
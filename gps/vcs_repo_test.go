@@ -226,7 +226,7 @@ func testGitRepo(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	repo := &gitRepo{rep}
+	repo := &gitRepo{GitRepo: rep}
 
 	// Do an initial clone.
 	err = repo.get(ctx)
@@ -7,7 +7,10 @@ package gps
 import (
 	"bytes"
 	"context"
+	"encoding/xml"
 	"fmt"
+	"io/ioutil"
+	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -47,6 +50,10 @@ func (bs *baseVCSSource) upstreamURL() string {
 	return bs.repo.Remote()
 }
 
+func (bs *baseVCSSource) localPath() string {
+	return bs.repo.LocalPath()
+}
+
 func (bs *baseVCSSource) disambiguateRevision(ctx context.Context, r Revision) (Revision, error) {
 	ci, err := bs.repo.CommitInfo(string(r))
 	if err != nil {
@@ -176,9 +183,20 @@ func (s *gitSource) exportRevisionTo(ctx context.Context, rev Revision, to strin
 	// could have an err here...but it's hard to imagine how?
 	defer fs.RenameWithFallback(bak, idx)
 
+	// checkout-index would fetch the files missing from a partial clone one
+	// at a time.
+	var env []string
+	if gr, ok := r.(*gitRepo); ok {
+		if err := gr.prefetch(ctx, rev.String()); err != nil {
+			return err
+		}
+		env = gr.readEnv(ctx)
+	}
+
 	{
 		cmd := commandContext(ctx, "git", "read-tree", rev.String())
 		cmd.SetDir(r.LocalPath())
+		cmd.SetEnv(env)
 		if out, err := cmd.CombinedOutput(); err != nil {
 			return errors.Wrap(err, string(out))
 		}
@@ -198,6 +216,7 @@ func (s *gitSource) exportRevisionTo(ctx context.Context, rev Revision, to strin
 	{
 		cmd := commandContext(ctx, "git", "checkout-index", "-a", "--prefix="+to)
 		cmd.SetDir(r.LocalPath())
+		cmd.SetEnv(env)
 		if out, err := cmd.CombinedOutput(); err != nil {
 			return errors.Wrap(err, string(out))
 		}
@@ -214,7 +233,7 @@ func (*gitSource) existsCallsListVersions() bool {
 	return true
 }
 
-func (s *gitSource) listVersions(ctx context.Context) (vlist []PairedVersion, err error) {
+func (s *gitSource) listVersions(ctx context.Context) ([]PairedVersion, error) {
 	r := s.repo
 
 	cmd := commandContext(ctx, "git", "ls-remote", r.Remote())
@@ -235,6 +254,58 @@ func (s *gitSource) listVersions(ctx context.Context) (vlist []PairedVersion, er
 		return nil, errors.Wrap(err, string(out))
 	}
 
+	return s.parseRefList(out)
+}
+
+// listLocalVersions lists the versions known to the local repository, as of
+// the last time it was fetched from upstream, without contacting upstream.
+func (s *gitSource) listLocalVersions(ctx context.Context) ([]PairedVersion, error) {
+	r := s.repo
+
+	cmd := commandContext(ctx, "git", "for-each-ref", "--format=%(objectname) %(*objectname) %(refname)", "refs/remotes/origin", "refs/tags")
+	cmd.SetDir(r.LocalPath())
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, errors.Wrap(err, string(out))
+	}
+
+	// Rewrite the refs into the form that ls-remote would have reported them in
+	// upstream: remote-tracking branches become branches, the remote HEAD goes
+	// first, and annotated tags are followed by their peeled revision.
+	var head []byte
+	var refs bytes.Buffer
+	for _, line := range bytes.Split(bytes.TrimSpace(out), []byte("\n")) {
+		fields := bytes.Fields(line)
+		var obj, peeled, name []byte
+		switch len(fields) {
+		case 2:
+			obj, name = fields[0], fields[1]
+		case 3:
+			obj, peeled, name = fields[0], fields[1], fields[2]
+		default:
+			continue
+		}
+
+		switch {
+		case bytes.Equal(name, []byte("refs/remotes/origin/HEAD")):
+			head = obj
+		case bytes.HasPrefix(name, []byte("refs/remotes/origin/")):
+			fmt.Fprintf(&refs, "%s\trefs/heads/%s\n", obj, name[len("refs/remotes/origin/"):])
+		case bytes.HasPrefix(name, []byte("refs/tags/")):
+			fmt.Fprintf(&refs, "%s\t%s\n", obj, name)
+			if peeled != nil {
+				fmt.Fprintf(&refs, "%s\t%s^{}\n", peeled, name)
+			}
+		}
+	}
+	if head != nil {
+		return s.parseRefList(append([]byte(fmt.Sprintf("%s\tHEAD\n", head)), refs.Bytes()...))
+	}
+	return s.parseRefList(refs.Bytes())
+}
+
+// parseRefList converts the output of git ls-remote into a version list.
+func (s *gitSource) parseRefList(out []byte) (vlist []PairedVersion, err error) {
 	all := bytes.Split(bytes.TrimSpace(out), []byte("\n"))
 	if len(all) == 1 && len(all[0]) == 0 {
 		return nil, fmt.Errorf("no data returned from ls-remote")
@@ -371,8 +442,20 @@ func (s *gopkginSource) listVersions(ctx context.Context) ([]PairedVersion, erro
 	if err != nil {
 		return nil, err
 	}
+	return s.filterVersions(ovlist), nil
+}
 
-	// Apply gopkg.in's filtering rules
+func (s *gopkginSource) listLocalVersions(ctx context.Context) ([]PairedVersion, error) {
+	ovlist, err := s.gitSource.listLocalVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.filterVersions(ovlist), nil
+}
+
+// filterVersions applies gopkg.in's filtering rules to the versions of the
+// underlying repository.
+func (s *gopkginSource) filterVersions(ovlist []PairedVersion) []PairedVersion {
 	vlist := make([]PairedVersion, len(ovlist))
 	k := 0
 	var dbranch int // index of branch to be marked default
@@ -445,7 +528,7 @@ func (s *gopkginSource) listVersions(ctx context.Context) ([]PairedVersion, erro
 		vlist = append(vlist, defaultBranch)
 	}
 
-	return vlist, nil
+	return vlist
 }
 
 // bzrSource is a generic bzr repository implementation that should work with
@@ -653,3 +736,318 @@ func (s *hgSource) listVersions(ctx context.Context) ([]PairedVersion, error) {
 
 	return vlist, nil
 }
+
+// svnSource is a subversion repository implementation for repositories with
+// the conventional trunk, tags and branches layout, or, lacking it, a single
+// line of development at the root.
+//
+// As subversion is centralized, no working copy is kept. Versions are listed
+// from upstream, and each revision is exported into its own directory under
+// the local path when it is first needed. Revisions are of the form path@rev,
+// such as tags/v1.0.0@42: a directory of the repository, as of the revision in
+// which it last changed.
+type svnSource struct {
+	baseVCSSource
+}
+
+var svnRevisionRE = regexp.MustCompile(`^[0-9]+$`)
+
+// svnRevision returns the revision of the directory at path, "." being the
+// root, as of revision rev.
+func svnRevision(path, rev string) Revision {
+	return Revision(path + "@" + rev)
+}
+
+// splitSvnRevision splits r into the path and revision number it is made of.
+// The path is empty if r is a bare revision number.
+func splitSvnRevision(r Revision) (path, rev string) {
+	i := strings.LastIndex(string(r), "@")
+	if i < 0 {
+		return "", string(r)
+	}
+	return string(r[:i]), string(r[i+1:])
+}
+
+func (s *svnSource) existsLocally(ctx context.Context) bool {
+	_, err := os.Stat(s.repo.LocalPath())
+	return err == nil
+}
+
+func (s *svnSource) initLocal(ctx context.Context) error {
+	return os.MkdirAll(s.repo.LocalPath(), 0777)
+}
+
+// updateLocal does nothing, as revisions are exported when they are needed.
+func (s *svnSource) updateLocal(ctx context.Context) error {
+	return s.initLocal(ctx)
+}
+
+func (s *svnSource) svnCmd(ctx context.Context, args ...string) cmd {
+	return commandContext(ctx, "svn", append([]string{"--non-interactive"}, args...)...)
+}
+
+// svnURL returns the URL of the directory at path, "." being the root, as of
+// revision rev.
+func (s *svnSource) svnURL(path, rev string) string {
+	u := strings.TrimSuffix(s.repo.Remote(), "/")
+	if path != "." {
+		u += "/" + (&url.URL{Path: path}).EscapedPath()
+	}
+	return u + "@" + rev
+}
+
+type svnEntry struct {
+	Kind   string `xml:"kind,attr"`
+	Name   string `xml:"name"`
+	Commit struct {
+		Revision string `xml:"revision,attr"`
+	} `xml:"commit"`
+}
+
+// svnNotFoundError is returned for a directory or revision that does not
+// exist in the repository.
+type svnNotFoundError struct {
+	url string
+	out string
+}
+
+func (e *svnNotFoundError) Error() string {
+	return fmt.Sprintf("%s does not exist: %s", e.url, strings.TrimSpace(e.out))
+}
+
+// svnNotFoundCodes are the codes of the svn errors and warnings reporting
+// that a path, or a revision, does not exist.
+var svnNotFoundCodes = []string{"E160006", "E160013", "W160013", "W170000"}
+
+// svnError returns the error for a failed svn command on the URL u, with the
+// output out.
+func svnError(err error, u string, out []byte) error {
+	for _, code := range svnNotFoundCodes {
+		if bytes.Contains(out, []byte(code+":")) {
+			return &svnNotFoundError{url: u, out: string(out)}
+		}
+	}
+	return errors.Wrap(err, string(out))
+}
+
+// list lists the entries of the directory at path as of revision rev, each
+// with the revision in which it last changed.
+func (s *svnSource) list(ctx context.Context, path, rev string) ([]svnEntry, error) {
+	u := s.svnURL(path, rev)
+	cmd := s.svnCmd(ctx, "list", "--xml", u)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, svnError(err, u, out)
+	}
+
+	var lists struct {
+		Entries []svnEntry `xml:"list>entry"`
+	}
+	if err := xml.Unmarshal(out, &lists); err != nil {
+		return nil, errors.Wrap(err, string(out))
+	}
+	return lists.Entries, nil
+}
+
+// lastChanged returns the revision in which the directory at path last
+// changed, as of revision rev.
+func (s *svnSource) lastChanged(ctx context.Context, path, rev string) (string, error) {
+	u := s.svnURL(path, rev)
+	cmd := s.svnCmd(ctx, "info", "--xml", u)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", svnError(err, u, out)
+	}
+
+	var info struct {
+		Commit struct {
+			Revision string `xml:"revision,attr"`
+		} `xml:"entry>commit"`
+	}
+	if err := xml.Unmarshal(out, &info); err != nil {
+		return "", errors.Wrap(err, string(out))
+	}
+	if info.Commit.Revision == "" {
+		return "", errors.Errorf("svn info reported no revision for %s", s.svnURL(path, rev))
+	}
+	return info.Commit.Revision, nil
+}
+
+// listVersions lists the tags as versions, the branches as branches, and
+// trunk as the default branch. A repository without any of these has only a
+// default branch, its root, which is also named trunk.
+func (s *svnSource) listVersions(ctx context.Context) ([]PairedVersion, error) {
+	root, err := s.list(ctx, ".", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	layout := make(map[string]string)
+	for _, e := range root {
+		switch e.Name {
+		case "trunk", "tags", "branches":
+			if e.Kind == "dir" {
+				layout[e.Name] = e.Commit.Revision
+			}
+		}
+	}
+
+	if len(layout) == 0 {
+		rev, err := s.lastChanged(ctx, ".", "HEAD")
+		if err != nil {
+			return nil, err
+		}
+		return []PairedVersion{newDefaultBranch("trunk").Pair(svnRevision(".", rev))}, nil
+	}
+
+	var vlist []PairedVersion
+	for _, dir := range []string{"tags", "branches"} {
+		if _, has := layout[dir]; !has {
+			continue
+		}
+		entries, err := s.list(ctx, dir, "HEAD")
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if e.Kind != "dir" {
+				continue
+			}
+			r := svnRevision(dir+"/"+e.Name, e.Commit.Revision)
+			if dir == "tags" {
+				vlist = append(vlist, NewVersion(e.Name).Pair(r))
+			} else {
+				vlist = append(vlist, NewBranch(e.Name).Pair(r))
+			}
+		}
+	}
+
+	if rev, has := layout["trunk"]; has {
+		vlist = append(vlist, newDefaultBranch("trunk").Pair(svnRevision("trunk", rev)))
+	}
+	return vlist, nil
+}
+
+// revisionPresentIn reports whether r names a directory of the repository as
+// of the revision in which it last changed.
+func (s *svnSource) revisionPresentIn(r Revision) (bool, error) {
+	if path, rev := splitSvnRevision(r); path == "" || !svnRevisionRE.MatchString(rev) {
+		return false, nil
+	}
+	dr, err := s.disambiguateRevision(context.TODO(), r)
+	if _, ok := err.(*svnNotFoundError); ok {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return dr == r, nil
+}
+
+// disambiguateRevision accepts revisions of the form path@rev, and bare
+// revision numbers, which refer to trunk, or to the root if there was no
+// trunk as of that revision. The revision returned names the directory as of
+// the revision in which it last changed.
+func (s *svnSource) disambiguateRevision(ctx context.Context, r Revision) (Revision, error) {
+	path, rev := splitSvnRevision(r)
+	if !svnRevisionRE.MatchString(rev) {
+		return "", errors.Errorf("%s is not a subversion revision", r)
+	}
+
+	if path == "" {
+		root, err := s.list(ctx, ".", rev)
+		if err != nil {
+			return "", err
+		}
+		path = "."
+		for _, e := range root {
+			if e.Kind == "dir" && e.Name == "trunk" {
+				path = "trunk"
+			}
+		}
+	}
+
+	last, err := s.lastChanged(ctx, path, rev)
+	if err != nil {
+		return "", err
+	}
+	return svnRevision(path, last), nil
+}
+
+func (s *svnSource) getManifestAndLock(ctx context.Context, pr ProjectRoot, r Revision, an ProjectAnalyzer) (Manifest, Lock, error) {
+	dir, err := s.revisionDir(ctx, r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m, l, err := an.DeriveManifestAndLock(dir, pr)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if l != nil && l != Lock(nil) {
+		l = prepLock(l)
+	}
+
+	return prepManifest(m), l, nil
+}
+
+func (s *svnSource) listPackages(ctx context.Context, pr ProjectRoot, r Revision) (pkgtree.PackageTree, error) {
+	dir, err := s.revisionDir(ctx, r)
+	if err != nil {
+		return pkgtree.PackageTree{}, err
+	}
+	return pkgtree.ListPackages(dir, string(pr))
+}
+
+func (s *svnSource) exportRevisionTo(ctx context.Context, r Revision, to string) error {
+	dir, err := s.revisionDir(ctx, r)
+	if err != nil {
+		return err
+	}
+
+	// Only make the parent dir, as CopyDir will balk on trying to write to an
+	// empty but existing dir.
+	if err := os.MkdirAll(filepath.Dir(to), 0777); err != nil {
+		return err
+	}
+	return fs.CopyDir(dir, to)
+}
+
+// revisionDir returns the directory holding the export of r, exporting it
+// with svn export first if necessary.
+func (s *svnSource) revisionDir(ctx context.Context, r Revision) (string, error) {
+	path, rev := splitSvnRevision(r)
+	if path == "" {
+		dr, err := s.disambiguateRevision(ctx, r)
+		if err != nil {
+			return "", err
+		}
+		r = dr
+		path, rev = splitSvnRevision(r)
+	}
+
+	dir := filepath.Join(s.repo.LocalPath(), url.PathEscape(string(r)))
+	if _, err := os.Stat(dir); err == nil {
+		return dir, nil
+	}
+
+	if err := os.MkdirAll(s.repo.LocalPath(), 0777); err != nil {
+		return "", err
+	}
+	tmp, err := ioutil.TempDir(s.repo.LocalPath(), ".export")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmp)
+
+	tree := filepath.Join(tmp, "tree")
+	cmd := s.svnCmd(ctx, "export", "--ignore-externals", s.svnURL(path, rev), tree)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return "", errors.Wrap(err, string(out))
+	}
+	if err := os.Rename(tree, dir); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
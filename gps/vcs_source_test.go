@@ -28,7 +28,7 @@ func TestSlowVcs(t *testing.T) {
 	t.Run("bzr-repo", testBzrRepo)
 	t.Run("bzr-source", testBzrSourceInteractions)
 	t.Run("svn-repo", testSvnRepo)
-	// TODO(sdboyer) svn-source
+	t.Run("svn-source", testSvnSourceInteractions)
 	t.Run("hg-repo", testHgRepo)
 	t.Run("hg-source", testHgSourceInteractions)
 	t.Run("git-repo", testGitRepo)
@@ -564,7 +564,7 @@ func TestGitSourceListVersionsNoHEAD(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Error parsing URL %s: %s", un, err)
 	}
-	mb := maybeGitSource{u}
+	mb := maybeGitSource{url: u}
 
 	ctx := context.Background()
 	isrc, err := mb.try(ctx, cpath)
@@ -592,6 +592,114 @@ func TestGitSourceListVersionsNoHEAD(t *testing.T) {
 	}
 }
 
+func TestGitSourcePartialClone(t *testing.T) {
+	requiresBins(t, "git")
+
+	for _, allowFilter := range []string{"true", "false"} {
+		t.Run("allowFilter="+allowFilter, func(t *testing.T) {
+			h := test.NewHelper(t)
+			defer h.Cleanup()
+			h.TempDir("smcache")
+			cpath := h.Path("smcache")
+
+			// Create a test repo whose first revision's files differ from the
+			// checked out second one's.
+			h.TempDir("repo")
+			repoPath := h.Path("repo")
+			h.RunGit(repoPath, "init")
+			h.RunGit(repoPath, "config", "--local", "user.email", "test@example.com")
+			h.RunGit(repoPath, "config", "--local", "user.name", "Test author")
+			h.RunGit(repoPath, "config", "--local", "uploadpack.allowFilter", allowFilter)
+			h.TempFile("repo/lib.go", "package lib\n")
+			h.RunGit(repoPath, "add", "lib.go")
+			h.RunGit(repoPath, "commit", "--message=first")
+			h.RunGit(repoPath, "tag", "v1.0.0")
+			h.TempFile("repo/lib.go", "package lib\n\nconst X = 1\n")
+			h.RunGit(repoPath, "commit", "--all", "--message=second")
+
+			un := "file://" + filepath.ToSlash(repoPath)
+			u, err := url.Parse(un)
+			if err != nil {
+				t.Fatalf("Error parsing URL %s: %s", un, err)
+			}
+			mb := maybeGitSource{url: u, partial: true}
+
+			ctx := context.Background()
+			isrc, err := mb.try(ctx, cpath)
+			if err != nil {
+				t.Fatalf("Unexpected error while setting up gitSource for test repo: %s", err)
+			}
+			if err := isrc.initLocal(ctx); err != nil {
+				t.Fatalf("Error on cloning git repo: %s", err)
+			}
+
+			// Only a partial clone has objects missing.
+			cmd := exec.Command("git", "rev-list", "--objects", "--missing=print", "--all")
+			cmd.Dir = isrc.localPath()
+			out, err := cmd.CombinedOutput()
+			if err != nil {
+				t.Fatalf("git rev-list failed: %s\n%s", err, out)
+			}
+			if got, want := strings.Contains(string(out), "?"), allowFilter == "true"; got != want {
+				t.Errorf("Expected objects to be missing from the clone: %v, got %v", want, got)
+			}
+
+			pvlist, err := isrc.listVersions(ctx)
+			if err != nil {
+				t.Fatalf("Unexpected error getting version pairs from git repo: %s", err)
+			}
+			var rev Revision
+			for _, pv := range pvlist {
+				if pv.String() == "v1.0.0" {
+					rev = pv.Revision()
+				}
+			}
+			if rev == "" {
+				t.Fatalf("Expected the version v1.0.0, got %v", pvlist)
+			}
+
+			// Offline, the missing files of the first revision cannot be
+			// fetched, though the source is not set to clone partially: the
+			// clone may be left by an earlier run.
+			omb := maybeGitSource{url: u, offline: true}
+			osrc, err := omb.try(ctx, cpath)
+			if err != nil {
+				t.Fatalf("Unexpected error while setting up gitSource for test repo: %s", err)
+			}
+			_, err = osrc.listPackages(ctx, "example.com/lib", rev)
+			if got, want := err != nil, allowFilter == "true"; got != want {
+				t.Errorf("Expected listing packages offline to fail: %v, got %v", want, err)
+			}
+
+			to := filepath.Join(h.Path("."), "export")
+			if err := isrc.exportRevisionTo(ctx, rev, to); err != nil {
+				t.Fatalf("Unexpected error exporting %s: %s", rev, err)
+			}
+			got, err := ioutil.ReadFile(filepath.Join(to, "lib.go"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != "package lib\n" {
+				t.Errorf("Expected the first revision of lib.go to be exported, got %q", got)
+			}
+
+			// Completed, the clone has nothing missing and is not partial.
+			if !isPartialGitClone(ctx, isrc.localPath()) {
+				t.Error("Expected the clone to be configured as partial")
+			}
+			if err := completePartialGitClone(ctx, isrc.localPath()); err != nil {
+				t.Fatalf("Unexpected error completing the partial clone: %s", err)
+			}
+			if missing, err := missingGitObjects(ctx, isrc.localPath(), "--all"); err != nil || len(missing) > 0 {
+				t.Errorf("Expected no objects to be missing, got %q, %v", missing, err)
+			}
+			if isPartialGitClone(ctx, isrc.localPath()) {
+				t.Error("Expected the completed clone not to be partial")
+			}
+		})
+	}
+}
+
 func TestGitSourceListVersionsNoDupes(t *testing.T) {
 	// t.Parallel()
 
@@ -748,6 +856,169 @@ func TestGitSourceAdaptiveCleanup(t *testing.T) {
 	os.RemoveAll(cpath)
 }
 
+func testSvnSourceInteractions(t *testing.T) {
+	t.Parallel()
+
+	// This test is slowish, skip it on -short
+	if testing.Short() {
+		t.Skip("Skipping svn source version fetching test in short mode")
+	}
+	requiresBins(t, "svn", "svnadmin")
+
+	h := test.NewHelper(t)
+	defer h.Cleanup()
+	h.TempDir("smcache")
+	h.TempDir("wc")
+
+	svn := func(args ...string) {
+		t.Helper()
+		if out, err := exec.Command("svn", args...).CombinedOutput(); err != nil {
+			t.Fatalf("svn %s failed: %s\n%s", strings.Join(args, " "), err, out)
+		}
+	}
+
+	// r1 creates the layout, r2 adds to trunk, r3 tags it, r4 changes trunk
+	// and r5 branches it.
+	repo := filepath.Join(h.Path("."), "repo")
+	if out, err := exec.Command("svnadmin", "create", repo).CombinedOutput(); err != nil {
+		t.Fatalf("svnadmin create failed: %s\n%s", err, out)
+	}
+	un := "file://" + filepath.ToSlash(repo)
+	svn("mkdir", "-m", "layout", un+"/trunk", un+"/tags", un+"/branches")
+	wc := filepath.Join(h.Path("wc"), "trunk")
+	svn("checkout", un+"/trunk", wc)
+	h.TempFile("wc/trunk/lib.go", "package lib\n")
+	svn("add", filepath.Join(wc, "lib.go"))
+	svn("commit", "-m", "add lib", wc)
+	svn("copy", "-m", "tag", un+"/trunk", un+"/tags/v1.0.0")
+	h.TempFile("wc/trunk/lib.go", "package lib\n\nconst X = 1\n")
+	svn("commit", "-m", "change lib", wc)
+	svn("copy", "-m", "branch", un+"/trunk", un+"/branches/dev")
+
+	u, err := url.Parse(un)
+	if err != nil {
+		t.Fatalf("Error parsing URL %s: %s", un, err)
+	}
+	mb := maybeSvnSource{url: u}
+
+	ctx := context.Background()
+	isrc, err := mb.try(ctx, h.Path("smcache"))
+	if err != nil {
+		t.Fatalf("Unexpected error while setting up svnSource for test repo: %s", err)
+	}
+	if err := isrc.initLocal(ctx); err != nil {
+		t.Fatalf("Error on initializing svn source: %s", err)
+	}
+	src, ok := isrc.(*svnSource)
+	if !ok {
+		t.Fatalf("Expected an svnSource, got a %T", isrc)
+	}
+
+	pvlist, err := src.listVersions(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error getting version pairs from svn repo: %s", err)
+	}
+	vlist := make([]Version, len(pvlist))
+	for k, v := range pvlist {
+		vlist[k] = v
+	}
+	SortForUpgrade(vlist)
+	evl := []Version{
+		NewVersion("v1.0.0").Pair(Revision("tags/v1.0.0@3")),
+		newDefaultBranch("trunk").Pair(Revision("trunk@4")),
+		NewBranch("dev").Pair(Revision("branches/dev@5")),
+	}
+	if !reflect.DeepEqual(vlist, evl) {
+		t.Errorf("svn version list was not what we expected:\n\t(GOT): %s\n\t(WNT): %s", vlist, evl)
+	}
+
+	for in, want := range map[Revision]Revision{
+		"3":             "trunk@2",
+		"5":             "trunk@4",
+		"trunk@3":       "trunk@2",
+		"tags/v1.0.0@5": "tags/v1.0.0@3",
+	} {
+		got, err := src.disambiguateRevision(ctx, in)
+		if err != nil {
+			t.Errorf("Unexpected error disambiguating %s: %s", in, err)
+		} else if got != want {
+			t.Errorf("Expected %s to disambiguate to %s, got %s", in, want, got)
+		}
+	}
+	if _, err := src.disambiguateRevision(ctx, "tags/v2.0.0@5"); err == nil {
+		t.Error("Expected a missing tag not to disambiguate")
+	}
+
+	for r, want := range map[Revision]bool{
+		"trunk@4": true,
+		"trunk@3": false,
+		"9":       false,
+	} {
+		if got, err := src.revisionPresentIn(r); err != nil || got != want {
+			t.Errorf("Expected %s to be present: %v, got %v, %v", r, want, got, err)
+		}
+	}
+
+	to := filepath.Join(h.Path("."), "export")
+	if err := src.exportRevisionTo(ctx, "tags/v1.0.0@3", to); err != nil {
+		t.Fatalf("Unexpected error exporting tags/v1.0.0@3: %s", err)
+	}
+	got, err := ioutil.ReadFile(filepath.Join(to, "lib.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "package lib\n" {
+		t.Errorf("Expected the tagged lib.go to be exported, got %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(to, ".svn")); err == nil {
+		t.Error("Expected no .svn directory in the export")
+	}
+
+	ptree, err := src.listPackages(ctx, "example.com/lib", "branches/dev@5")
+	if err != nil {
+		t.Fatalf("Unexpected error listing packages: %s", err)
+	}
+	if _, has := ptree.Packages["example.com/lib"]; !has {
+		t.Errorf("Expected the package example.com/lib, got %v", ptree.Packages)
+	}
+
+	// Without the standard layout, the root is the only branch.
+	flat := filepath.Join(h.Path("."), "flat")
+	if out, err := exec.Command("svnadmin", "create", flat).CombinedOutput(); err != nil {
+		t.Fatalf("svnadmin create failed: %s\n%s", err, out)
+	}
+	fun := "file://" + filepath.ToSlash(flat)
+	svn("mkdir", "-m", "add lib", fun+"/lib")
+	fu, err := url.Parse(fun)
+	if err != nil {
+		t.Fatalf("Error parsing URL %s: %s", fun, err)
+	}
+	fsrc, err := maybeSvnSource{url: fu}.try(ctx, h.Path("smcache"))
+	if err != nil {
+		t.Fatalf("Unexpected error while setting up svnSource for test repo: %s", err)
+	}
+	pvlist, err = fsrc.listVersions(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error getting version pairs from svn repo: %s", err)
+	}
+	if want := []PairedVersion{newDefaultBranch("trunk").Pair(Revision(".@1"))}; !reflect.DeepEqual(pvlist, want) {
+		t.Errorf("svn version list was not what we expected:\n\t(GOT): %s\n\t(WNT): %s", pvlist, want)
+	}
+
+	// A repository that cannot be reached is an error, not a missing revision.
+	gu, err := url.Parse("file://" + filepath.ToSlash(filepath.Join(h.Path("."), "gone")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gsrc, err := maybeSvnSource{url: gu}.try(ctx, h.Path("smcache"))
+	if err != nil {
+		t.Fatalf("Unexpected error while setting up svnSource for test repo: %s", err)
+	}
+	if present, err := gsrc.revisionPresentIn("trunk@1"); err == nil || present {
+		t.Errorf("Expected an unreachable repository to be an error, got %v, %v", present, err)
+	}
+}
+
 func Test_bzrSource_exportRevisionTo_removeVcsFiles(t *testing.T) {
 	t.Parallel()
 
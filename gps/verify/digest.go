@@ -156,9 +156,18 @@ type dirWalkClosure struct {
 // is an empty directory, a non-empty directory, an empty file, or a non-empty file.
 //
 // Symbolic links are excluded, as they are not considered valid elements in the
-// definition of a Go module.
+// definition of a Go module. If osDirname itself is a symbolic link, as for a
+// project vendored as a link to its working tree, the tree it links to is
+// hashed.
 func DigestFromDirectory(osDirname string) (VersionedDigest, error) {
 	osDirname = filepath.Clean(osDirname)
+	if fi, err := os.Lstat(osDirname); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+		target, err := filepath.EvalSymlinks(osDirname)
+		if err != nil {
+			return VersionedDigest{}, err
+		}
+		osDirname = target
+	}
 
 	// Create a single hash instance for the entire operation, rather than a new
 	// hash for each node we encounter.
@@ -7,6 +7,7 @@ package verify
 import (
 	"bytes"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
@@ -141,6 +142,35 @@ func TestDigestFromDirectory(t *testing.T) {
 	})
 }
 
+func TestDigestFromDirectoryFollowsLinkedRoot(t *testing.T) {
+	target, err := filepath.Abs(filepath.Join(getTestdataVerifyRoot(t), "launchpad.net/match"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := DigestFromDirectory(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "digest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	link := filepath.Join(dir, "match")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("cannot create symlinks: %s", err)
+	}
+
+	got, err := DigestFromDirectory(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.Digest, want.Digest) {
+		t.Errorf("expected the link to hash as its target:\n(GOT):\n\t%#v\n(WNT):\n\t%#v", got.Digest, want.Digest)
+	}
+}
+
 func TestVerifyDepTree(t *testing.T) {
 	vendorRoot := getTestdataVerifyRoot(t)
 
@@ -20,15 +20,19 @@ type versionQueue struct {
 	lockv, prefv Version
 	fails        []failedVersion
 	b            sourceBridge
-	failed       bool
 	allLoaded    bool
 	adverr       error
+	// conflicts is the set of selected projects responsible for the failures
+	// of the versions tried so far, whether they failed when checked, or led
+	// to a failure after being selected.
+	conflicts conflictSet
 }
 
 func newVersionQueue(id ProjectIdentifier, lockv, prefv Version, b sourceBridge) (*versionQueue, error) {
 	vq := &versionQueue{
-		id: id,
-		b:  b,
+		id:        id,
+		b:         b,
+		conflicts: make(conflictSet),
 	}
 
 	// Lock goes in first, if present
@@ -125,11 +129,6 @@ func (vq *versionQueue) advance(fail error) error {
 		}
 	}
 
-	// We're finally sure that there's something in the queue. Remove the
-	// failure marker, as the current version may have failed, but the next one
-	// hasn't yet
-	vq.failed = false
-
 	// If all have been loaded and the queue is empty, we're definitely out
 	// of things to try. Return empty, though, because vq semantics dictate
 	// that we don't explicitly indicate the end of the queue here.
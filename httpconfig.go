@@ -0,0 +1,103 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/golang/dep/gps"
+	"github.com/pelletier/go-toml"
+	"github.com/pkg/errors"
+)
+
+// rawHTTPConfig is the TOML form of an HTTP config file:
+//
+//	ca = "corp-ca.pem"
+//	cert = "client.pem"
+//	key = "client-key.pem"
+//	proxy = "http://proxy.corp.example.com:3128"
+//	timeout = "30s"
+//
+//	[[header]]
+//	  host = "go.corp.example.com"
+//	  token = "..."
+//
+//	[[header]]
+//	  host = "go.other.example.com"
+//	  name = "X-Api-Key"
+//	  value = "..."
+//	  insecure = true
+type rawHTTPConfig struct {
+	CAFile   string          `toml:"ca"`
+	CertFile string          `toml:"cert"`
+	KeyFile  string          `toml:"key"`
+	Proxy    string          `toml:"proxy"`
+	Timeout  string          `toml:"timeout"`
+	Headers  []rawHTTPHeader `toml:"header"`
+}
+
+type rawHTTPHeader struct {
+	Host     string `toml:"host"`
+	Name     string `toml:"name"`
+	Value    string `toml:"value"`
+	Token    string `toml:"token"`
+	Insecure bool   `toml:"insecure"`
+}
+
+// LoadHTTPConfig reads the configuration of the HTTP client used to retrieve
+// go get metadata from the TOML file at path. Relative file paths in it are
+// relative to the directory containing it.
+func LoadHTTPConfig(path string) (gps.HTTPConfig, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return gps.HTTPConfig{}, errors.Wrap(err, "unable to open HTTP config file")
+	}
+	defer f.Close()
+
+	c, err := readHTTPConfig(f, filepath.Dir(path))
+	return c, errors.Wrapf(err, "unable to load HTTP config from %s", path)
+}
+
+func readHTTPConfig(r io.Reader, dir string) (gps.HTTPConfig, error) {
+	var raw rawHTTPConfig
+	if err := toml.NewDecoder(r).Decode(&raw); err != nil {
+		return gps.HTTPConfig{}, errors.Wrap(err, "unable to parse the HTTP config as TOML")
+	}
+
+	abs := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+	c := gps.HTTPConfig{
+		CAFile:   abs(raw.CAFile),
+		CertFile: abs(raw.CertFile),
+		KeyFile:  abs(raw.KeyFile),
+		Proxy:    raw.Proxy,
+	}
+
+	if raw.Timeout != "" {
+		var err error
+		if c.Timeout, err = time.ParseDuration(raw.Timeout); err != nil {
+			return gps.HTTPConfig{}, errors.Wrapf(err, "invalid %q", "timeout")
+		}
+	}
+
+	for i, rh := range raw.Headers {
+		h := gps.HTTPHeader{Host: rh.Host, Name: rh.Name, Value: rh.Value, Insecure: rh.Insecure}
+		if rh.Token != "" {
+			if rh.Name != "" || rh.Value != "" {
+				return gps.HTTPConfig{}, errors.Errorf("header %d has both a %q and a %q", i+1, "token", "name")
+			}
+			h.Name, h.Value = "Authorization", "Bearer "+rh.Token
+		}
+		c.Headers = append(c.Headers, h)
+	}
+	return c, nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dep
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/dep/gps"
+)
+
+func TestReadHTTPConfig(t *testing.T) {
+	dir := filepath.FromSlash("/etc/dep")
+	abs := filepath.Join(dir, "abs", "ca.pem")
+	c, err := readHTTPConfig(strings.NewReader(`
+ca = '`+abs+`'
+cert = "client.pem"
+key = "keys/client-key.pem"
+proxy = "http://proxy.corp.example.com:3128"
+timeout = "30s"
+
+[[header]]
+  host = "go.corp.example.com"
+  token = "t0ken"
+
+[[header]]
+  host = "go.other.example.com"
+  name = "X-Api-Key"
+  value = "k3y"
+  insecure = true
+`), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := gps.HTTPConfig{
+		CAFile:   abs,
+		CertFile: filepath.Join(dir, "client.pem"),
+		KeyFile:  filepath.Join(dir, "keys", "client-key.pem"),
+		Proxy:    "http://proxy.corp.example.com:3128",
+		Timeout:  30 * time.Second,
+		Headers: []gps.HTTPHeader{
+			{Host: "go.corp.example.com", Name: "Authorization", Value: "Bearer t0ken"},
+			{Host: "go.other.example.com", Name: "X-Api-Key", Value: "k3y", Insecure: true},
+		},
+	}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("unexpected HTTP config:\n\t(GOT): %#v\n\t(WNT): %#v", c, want)
+	}
+
+	bad := []string{
+		"timeout = \"soon\"\n",
+		"[[header]]\n  host = \"go.corp.example.com\"\n  token = \"t0ken\"\n  name = \"X-Api-Key\"\n",
+		"[[header]\n",
+	}
+	for _, s := range bad {
+		if _, err := readHTTPConfig(strings.NewReader(s), dir); err == nil {
+			t.Errorf("expected an error for the HTTP config %q", s)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package license identifies common open source licenses from their text.
+package license
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Unknown is the identifier reported for license texts that match none of the
+// known licenses.
+const Unknown = "UNKNOWN"
+
+// A matcher recognizes a single license by the phrases its text must contain,
+// written in the normalized form produced by normalize.
+type matcher struct {
+	id  string
+	all []string
+}
+
+// matchers is ordered so that licenses whose text mentions another one are
+// tried first; the MPL and EPL, for instance, name the GPL family as secondary
+// licenses. The first match wins.
+var matchers = []matcher{
+	{id: "MPL-2.0", all: []string{"mozilla public license version 2 0"}},
+	{id: "MPL-1.1", all: []string{"mozilla public license version 1 1"}},
+	{id: "EPL-2.0", all: []string{"eclipse public license", "v 2 0"}},
+	{id: "EPL-1.0", all: []string{"eclipse public license", "v 1 0"}},
+	// The full texts of the GPL family all mention each other, so they are
+	// told apart by their titles first, then by their standard notices.
+	{id: "AGPL-3.0", all: []string{"gnu affero general public license version 3 19 november 2007"}},
+	{id: "LGPL-3.0", all: []string{"gnu lesser general public license version 3 29 june 2007"}},
+	{id: "LGPL-2.1", all: []string{"gnu lesser general public license version 2 1 february 1999"}},
+	{id: "LGPL-2.0", all: []string{"gnu library general public license version 2 june 1991"}},
+	{id: "GPL-3.0", all: []string{"gnu general public license version 3 29 june 2007"}},
+	{id: "GPL-2.0", all: []string{"gnu general public license version 2 june 1991"}},
+	{id: "AGPL-3.0", all: []string{"gnu affero general public license as published by the free software foundation either version 3"}},
+	{id: "LGPL-3.0", all: []string{"gnu lesser general public license as published by the free software foundation either version 3"}},
+	{id: "LGPL-2.1", all: []string{"gnu lesser general public license as published by the free software foundation either version 2 1"}},
+	{id: "GPL-3.0", all: []string{"gnu general public license as published by the free software foundation either version 3"}},
+	{id: "GPL-2.0", all: []string{"gnu general public license as published by the free software foundation either version 2"}},
+	{id: "Apache-2.0", all: []string{"apache license", "version 2 0"}},
+	{id: "BSL-1.0", all: []string{"boost software license version 1 0"}},
+	{
+		id: "BSD-4-Clause",
+		all: []string{
+			"redistribution and use in source and binary forms",
+			"all advertising materials mentioning features or use of this software",
+		},
+	},
+	{
+		id: "BSD-3-Clause",
+		all: []string{
+			"redistribution and use in source and binary forms",
+			"may be used to endorse or promote products derived from this software",
+		},
+	},
+	{
+		id:  "BSD-2-Clause",
+		all: []string{"redistribution and use in source and binary forms"},
+	},
+	{
+		id: "MIT",
+		all: []string{
+			"permission is hereby granted free of charge to any person obtaining a copy",
+			"the above copyright notice and this permission notice shall be included",
+		},
+	},
+	{
+		id: "ISC",
+		all: []string{
+			"permission to use copy modify and or distribute this software for any purpose with or without fee is hereby granted",
+			"provided that the above copyright notice and this permission notice appear in all copies",
+		},
+	},
+	{
+		id:  "0BSD",
+		all: []string{"permission to use copy modify and or distribute this software for any purpose with or without fee is hereby granted"},
+	},
+	{
+		id: "Zlib",
+		all: []string{
+			"altered source versions must be plainly marked as such",
+			"this notice may not be removed or altered from any source distribution",
+		},
+	},
+	{id: "Unlicense", all: []string{"this is free and unencumbered software released into the public domain"}},
+	{id: "CC0-1.0", all: []string{"cc0 1 0"}},
+	{id: "WTFPL", all: []string{"do what the fuck you want to public license"}},
+}
+
+// Classify returns the SPDX identifier of the license whose text is in data,
+// or Unknown if it cannot be identified.
+func Classify(data []byte) string {
+	text := normalize(string(data))
+
+outer:
+	for _, m := range matchers {
+		for _, phrase := range m.all {
+			if !strings.Contains(text, phrase) {
+				continue outer
+			}
+		}
+		return m.id
+	}
+	return Unknown
+}
+
+// normalize lowercases s and replaces every run of characters other than
+// letters and digits with a single space, so that phrases can be found
+// regardless of line wrapping, punctuation or comment markers.
+func normalize(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+
+	space := true
+	for _, r := range s {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(unicode.ToLower(r))
+			space = false
+		} else if !space {
+			b.WriteByte(' ')
+			space = true
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
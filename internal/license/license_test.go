@@ -0,0 +1,115 @@
+// Copyright 2019 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package license
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+const mitText = `The MIT License (MIT)
+
+Copyright (c) 2014 Example Authors
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+`
+
+const iscText = `ISC License
+
+Copyright (c) 2013, Example Authors
+
+Permission to use, copy, modify, and/or distribute this software for any
+purpose with or without fee is hereby granted, provided that the above
+copyright notice and this permission notice appear in all copies.
+`
+
+const bsd2Text = `Copyright (c) 2012, Example Authors
+All rights reserved.
+
+Redistribution and use in source and binary forms, with or without
+modification, are permitted provided that the following conditions are met:
+
+* Redistributions of source code must retain the above copyright notice, this
+  list of conditions and the following disclaimer.
+* Redistributions in binary form must reproduce the above copyright notice,
+  this list of conditions and the following disclaimer in the documentation
+  and/or other materials provided with the distribution.
+`
+
+const apacheText = `
+                                 Apache License
+                           Version 2.0, January 2004
+                        http://www.apache.org/licenses/
+
+   TERMS AND CONDITIONS FOR USE, REPRODUCTION, AND DISTRIBUTION
+`
+
+const mplText = `Mozilla Public License Version 2.0
+==================================
+
+1.12. "Secondary License"
+    means either the GNU General Public License, Version 2.0, the GNU Lesser
+    General Public License, Version 2.1, the GNU Affero General Public
+    License, Version 3.0, or any later versions of those licenses.
+`
+
+const gpl3Notice = `    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+`
+
+const gpl3Title = `                    GNU GENERAL PUBLIC LICENSE
+                       Version 3, 29 June 2007
+
+  13. Use with the GNU Affero General Public License.
+
+  ...the GNU Lesser General Public License instead of this License.
+`
+
+func TestClassify(t *testing.T) {
+	bsd3, err := ioutil.ReadFile(filepath.Join("..", "..", "LICENSE"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		text string
+		want string
+	}{
+		"MIT":          {mitText, "MIT"},
+		"ISC":          {iscText, "ISC"},
+		"BSD-2-Clause": {bsd2Text, "BSD-2-Clause"},
+		"BSD-3-Clause": {string(bsd3), "BSD-3-Clause"},
+		"Apache-2.0":   {apacheText, "Apache-2.0"},
+		"MPL-2.0":      {mplText, "MPL-2.0"},
+		"GPL notice":   {gpl3Notice, "GPL-3.0"},
+		"GPL title":    {gpl3Title, "GPL-3.0"},
+		"comment markers": {
+			"// Permission is hereby granted, free of charge, to any person obtaining a copy\n" +
+				"// ... The above copyright notice and this permission notice shall be included\n",
+			"MIT",
+		},
+		"unknown": {"Copyright (c) 2019 Example Authors. All rights reserved.", Unknown},
+		"empty":   {"", Unknown},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := Classify([]byte(tc.text)); got != tc.want {
+				t.Errorf("expected %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ package dep
 import (
 	"bytes"
 	"io"
+	"path/filepath"
 	"sort"
 
 	"github.com/golang/dep/gps"
@@ -22,6 +23,12 @@ const LockName = "Gopkg.lock"
 type Lock struct {
 	SolveMeta SolveMeta
 	P         []gps.LockedProject
+
+	// LocalPaths holds the paths of the working trees on disk that are the
+	// sources of projects overridden by them, as written in the lock and the
+	// manifest. Until they are resolved against the project root, the sources
+	// of those projects are relative paths.
+	LocalPaths map[gps.ProjectRoot]string
 }
 
 // SolveMeta holds metadata about the solving process that created the lock that
@@ -53,6 +60,7 @@ type rawLockedProject struct {
 	Revision  string   `toml:"revision"`
 	Version   string   `toml:"version,omitempty"`
 	Source    string   `toml:"source,omitempty"`
+	Path      string   `toml:"path,omitempty"`
 	Packages  []string `toml:"packages"`
 	PruneOpts string   `toml:"pruneopts"`
 	Digest    string   `toml:"digest"`
@@ -104,6 +112,16 @@ func fromRawLock(raw rawLock) (*Lock, error) {
 			ProjectRoot: gps.ProjectRoot(ld.Name),
 			Source:      ld.Source,
 		}
+		if ld.Path != "" {
+			if ld.Source != "" {
+				return nil, errors.Errorf("lock file specified both a source (%s) and path (%s) for %s", ld.Source, ld.Path, ld.Name)
+			}
+			id.Source = gps.LocalSource(filepath.FromSlash(ld.Path))
+			if l.LocalPaths == nil {
+				l.LocalPaths = make(map[gps.ProjectRoot]string)
+			}
+			l.LocalPaths[id.ProjectRoot] = ld.Path
+		}
 
 		var err error
 		vp := verify.VerifiableProject{
@@ -146,6 +164,37 @@ func (l *Lock) InputImports() []string {
 	return l.SolveMeta.InputImports
 }
 
+// LocalProjects returns the projects in the lock whose source is a working
+// tree on disk, from a local override, mapped to the path of the tree.
+func (l *Lock) LocalProjects() map[gps.ProjectRoot]string {
+	local := make(map[gps.ProjectRoot]string)
+	for _, p := range l.Projects() {
+		if dir, ok := gps.IsLocalSource(p.Ident().Source); ok {
+			local[p.Ident().ProjectRoot] = dir
+		}
+	}
+	return local
+}
+
+// resolveLocalPaths sets the source of each project with a path to the
+// working tree at that path, relative to the project root dir.
+func (l *Lock) resolveLocalPaths(dir string) {
+	for i, lp := range l.P {
+		path, has := l.LocalPaths[lp.Ident().ProjectRoot]
+		if !has {
+			continue
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, filepath.FromSlash(path))
+		}
+		id := lp.Ident()
+		id.Source = gps.LocalSource(filepath.Clean(path))
+		vp := lp.(verify.VerifiableProject)
+		vp.LockedProject = gps.NewLockedProject(id, lp.Version(), lp.Packages())
+		l.P[i] = vp
+	}
+}
+
 // HasProjectWithRoot checks if the lock contains a project with the provided
 // ProjectRoot.
 //
@@ -170,6 +219,13 @@ func (l *Lock) dup() *Lock {
 	copy(l2.SolveMeta.InputImports, l.SolveMeta.InputImports)
 	copy(l2.P, l.P)
 
+	if l.LocalPaths != nil {
+		l2.LocalPaths = make(map[gps.ProjectRoot]string, len(l.LocalPaths))
+		for pr, path := range l.LocalPaths {
+			l2.LocalPaths[pr] = path
+		}
+	}
+
 	return l2
 }
 
@@ -197,6 +253,15 @@ func (l *Lock) toRaw() rawLock {
 			Source:   id.Source,
 			Packages: lp.Packages(),
 		}
+		// Mark projects overridden by a working tree on disk by its path, as
+		// given in the manifest, so that the lock does not depend on where
+		// the project is checked out.
+		if dir, ok := gps.IsLocalSource(id.Source); ok {
+			ld.Source, ld.Path = "", dir
+			if path, has := l.LocalPaths[id.ProjectRoot]; has {
+				ld.Path = path
+			}
+		}
 
 		v := lp.Version()
 		ld.Revision, ld.Branch, ld.Version = gps.VersionComponentStrings(v)
@@ -226,11 +291,13 @@ func (l *Lock) MarshalTOML() ([]byte, error) {
 
 // LockFromSolution converts a gps.Solution to dep's representation of a lock.
 // It makes sure that that the provided prune options are set correctly, as the
-// solver does not use VerifiableProjects for new selections it makes.
+// solver does not use VerifiableProjects for new selections it makes. The
+// paths of local overrides, from the manifest, are recorded for the projects
+// whose source is a working tree on disk.
 //
 // Data is defensively copied wherever necessary to ensure the resulting *Lock
 // shares no memory with the input solution.
-func LockFromSolution(in gps.Solution, prune gps.CascadingPruneOptions) *Lock {
+func LockFromSolution(in gps.Solution, prune gps.CascadingPruneOptions, localPaths map[gps.ProjectRoot]string) *Lock {
 	p := in.Projects()
 
 	l := &Lock{
@@ -245,12 +312,22 @@ func LockFromSolution(in gps.Solution, prune gps.CascadingPruneOptions) *Lock {
 	}
 
 	for _, lp := range p {
+		pr := lp.Ident().ProjectRoot
+		if path, has := localPaths[pr]; has {
+			if _, ok := gps.IsLocalSource(lp.Ident().Source); ok {
+				if l.LocalPaths == nil {
+					l.LocalPaths = make(map[gps.ProjectRoot]string)
+				}
+				l.LocalPaths[pr] = path
+			}
+		}
+
 		if vp, ok := lp.(verify.VerifiableProject); ok {
 			l.P = append(l.P, vp)
 		} else {
 			l.P = append(l.P, verify.VerifiableProject{
 				LockedProject: lp,
-				PruneOpts:     prune.PruneOptionsFor(lp.Ident().ProjectRoot),
+				PruneOpts:     prune.PruneOptionsFor(pr),
 			})
 		}
 	}
@@ -5,6 +5,8 @@
 package dep
 
 import (
+	"bytes"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
@@ -177,3 +179,45 @@ func TestReadLockErrors(t *testing.T) {
 		}
 	}
 }
+
+func TestLockLocalProject(t *testing.T) {
+	root := filepath.Join(string(filepath.Separator)+"src", "app")
+	dir := filepath.Join(string(filepath.Separator)+"src", "lib")
+	l := &Lock{
+		P: []gps.LockedProject{
+			verify.VerifiableProject{
+				LockedProject: gps.NewLockedProject(
+					gps.ProjectIdentifier{ProjectRoot: "github.com/corp/lib", Source: gps.LocalSource(dir)},
+					gps.NewBranch("local").Pair("0a1b2c"),
+					[]string{"."},
+				),
+				PruneOpts: gps.PruneNestedVendorDirs,
+			},
+		},
+		LocalPaths: map[gps.ProjectRoot]string{"github.com/corp/lib": "../lib"},
+	}
+
+	// The path from the manifest, not the source it resolved to, is written.
+	b, err := l.MarshalTOML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(b); !strings.Contains(s, `path = "../lib"`) || strings.Contains(s, "source") {
+		t.Errorf("expected the project to be marked by its path, got:\n%s", s)
+	}
+
+	l2, err := readLock(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(l2.LocalPaths, l.LocalPaths) {
+		t.Errorf("unexpected local paths:\n\t(GOT): %v\n\t(WNT): %v", l2.LocalPaths, l.LocalPaths)
+	}
+	l2.resolveLocalPaths(root)
+	if got := l2.P[0].Ident(); got != l.P[0].Ident() {
+		t.Errorf("expected the project to read back as %v, got %v", l.P[0].Ident(), got)
+	}
+	if want := map[gps.ProjectRoot]string{"github.com/corp/lib": dir}; !reflect.DeepEqual(l2.LocalProjects(), want) {
+		t.Errorf("unexpected local projects:\n\t(GOT): %v\n\t(WNT): %v", l2.LocalProjects(), want)
+	}
+}
@@ -8,9 +8,11 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"path/filepath"
 	"reflect"
 	"regexp"
 	"sort"
+	"strings"
 	"sync"
 
 	"github.com/golang/dep/gps"
@@ -32,6 +34,8 @@ var (
 	errInvalidPrune        = errors.Errorf("%q must be a TOML table of booleans", "prune")
 	errInvalidPruneProject = errors.Errorf("%q must be a TOML array of tables", "prune.project")
 	errInvalidMetadata     = errors.New("metadata should be a TOML table")
+	errInvalidLicense      = errors.Errorf("%q must be a TOML table", "license")
+	errInvalidLicenseAllow = errors.Errorf("%q must be a TOML list of strings", "license.allowed")
 
 	errInvalidProjectRoot = errors.New("ProjectRoot name validation failed")
 
@@ -55,6 +59,15 @@ type Manifest struct {
 	NoVerify []string
 
 	PruneOptions gps.CascadingPruneOptions
+
+	// AllowedLicenses holds the SPDX identifiers from the allowed list in the
+	// [license] table. When it is empty, any license is allowed.
+	AllowedLicenses []string
+
+	// LocalPaths holds the paths given in overrides that use a working tree
+	// on disk as a project's source, as written in the manifest. Until they
+	// are resolved against the project root, the overrides have no source.
+	LocalPaths map[gps.ProjectRoot]string
 }
 
 type rawManifest struct {
@@ -64,6 +77,7 @@ type rawManifest struct {
 	Required     []string        `toml:"required,omitempty"`
 	NoVerify     []string        `toml:"noverify,omitempty"`
 	PruneOptions rawPruneOptions `toml:"prune,omitempty"`
+	License      *rawLicense     `toml:"license,omitempty"`
 }
 
 type rawProject struct {
@@ -72,6 +86,7 @@ type rawProject struct {
 	Revision string `toml:"revision,omitempty"`
 	Version  string `toml:"version,omitempty"`
 	Source   string `toml:"source,omitempty"`
+	Path     string `toml:"path,omitempty"`
 }
 
 type rawPruneOptions struct {
@@ -83,6 +98,10 @@ type rawPruneOptions struct {
 	Projects []map[string]interface{}
 }
 
+type rawLicense struct {
+	Allowed []string `toml:"allowed,omitempty"`
+}
+
 const (
 	pruneOptionUnusedPackages = "unused-packages"
 	pruneOptionGoTests        = "go-tests"
@@ -148,7 +167,7 @@ func validateManifest(s string) ([]error, error) {
 							// Check if the key is valid
 							switch key {
 							case "name":
-							case "branch", "version", "source":
+							case "branch", "version", "source", "path":
 								ruleProvided = true
 							case "revision":
 								ruleProvided = true
@@ -215,6 +234,12 @@ func validateManifest(s string) ([]error, error) {
 			if err != nil {
 				return warns, err
 			}
+		case "license":
+			licenseWarns, err := validateLicense(val)
+			warns = append(warns, licenseWarns...)
+			if err != nil {
+				return warns, err
+			}
 		default:
 			warns = append(warns, fmt.Errorf("unknown field in manifest: %v", prop))
 		}
@@ -223,6 +248,30 @@ func validateManifest(s string) ([]error, error) {
 	return warns, nil
 }
 
+func validateLicense(val interface{}) (warns []error, err error) {
+	table, ok := val.(map[string]interface{})
+	if !ok {
+		return warns, errInvalidLicense
+	}
+
+	for key, value := range table {
+		switch key {
+		case "allowed":
+			rawList, ok := value.([]interface{})
+			if !ok {
+				return warns, errInvalidLicenseAllow
+			}
+			if len(rawList) > 0 && reflect.TypeOf(rawList[0]).Kind() != reflect.String {
+				return warns, errInvalidLicenseAllow
+			}
+		default:
+			warns = append(warns, fmt.Errorf("invalid key %q in %q", key, "license"))
+		}
+	}
+
+	return warns, nil
+}
+
 func validatePruneOptions(val interface{}, root bool) (warns []error, err error) {
 	if reflect.TypeOf(val).Kind() != reflect.Map {
 		return warns, errInvalidPrune
@@ -376,8 +425,14 @@ func fromRawManifest(raw rawManifest, buf *bytes.Buffer) (*Manifest, error) {
 	m.Ignored = raw.Ignored
 	m.Required = raw.Required
 	m.NoVerify = raw.NoVerify
+	if raw.License != nil {
+		m.AllowedLicenses = raw.License.Allowed
+	}
 
 	for i := 0; i < len(raw.Constraints); i++ {
+		if raw.Constraints[i].Path != "" {
+			return nil, errors.Errorf("a path can only be given in an override, not in the constraint on %s", raw.Constraints[i].Name)
+		}
 		name, prj, err := toProject(raw.Constraints[i])
 		if err != nil {
 			return nil, err
@@ -396,6 +451,15 @@ func fromRawManifest(raw rawManifest, buf *bytes.Buffer) (*Manifest, error) {
 		if _, exists := m.Ovr[name]; exists {
 			return nil, errors.Errorf("multiple overrides specified for %s, can only specify one", name)
 		}
+		if path := raw.Overrides[i].Path; path != "" {
+			if prj.Source != "" || prj.Constraint != gps.Any() {
+				return nil, errors.Errorf("the override on %s gives a path, so it can't also give a source, branch, version or revision", name)
+			}
+			if m.LocalPaths == nil {
+				m.LocalPaths = make(map[gps.ProjectRoot]string)
+			}
+			m.LocalPaths[name] = path
+		}
 		m.Ovr[name] = prj
 	}
 
@@ -549,12 +613,20 @@ func (m *Manifest) toRaw() rawManifest {
 	sort.Sort(sortedRawProjects(raw.Constraints))
 
 	for n, prj := range m.Ovr {
+		if path, has := m.LocalPaths[n]; has {
+			raw.Overrides = append(raw.Overrides, rawProject{Name: string(n), Path: path})
+			continue
+		}
 		raw.Overrides = append(raw.Overrides, toRawProject(n, prj))
 	}
 	sort.Sort(sortedRawProjects(raw.Overrides))
 
 	raw.PruneOptions = toRawPruneOptions(m.PruneOptions)
 
+	if len(m.AllowedLicenses) > 0 {
+		raw.License = &rawLicense{Allowed: m.AllowedLicenses}
+	}
+
 	return raw
 }
 
@@ -624,6 +696,19 @@ func (m *Manifest) IgnoredPackages() *pkgtree.IgnoredRuleset {
 	return pkgtree.NewIgnoredRuleset(m.Ignored)
 }
 
+// resolveLocalPaths sets the source of each override with a path to the
+// working tree at that path, relative to the project root dir.
+func (m *Manifest) resolveLocalPaths(dir string) {
+	for n, path := range m.LocalPaths {
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, filepath.FromSlash(path))
+		}
+		pp := m.Ovr[n]
+		pp.Source = gps.LocalSource(filepath.Clean(path))
+		m.Ovr[n] = pp
+	}
+}
+
 // HasConstraintsOn checks if the manifest contains either constraints or
 // overrides on the provided ProjectRoot.
 func (m *Manifest) HasConstraintsOn(root gps.ProjectRoot) bool {
@@ -637,6 +722,52 @@ func (m *Manifest) HasConstraintsOn(root gps.ProjectRoot) bool {
 	return false
 }
 
+// RemoveRulesFor removes every rule the manifest holds for the project at root:
+// its constraint, override and prune options, and any required or noverify
+// entries that fall within it. It reports whether anything was removed.
+func (m *Manifest) RemoveRulesFor(root gps.ProjectRoot) bool {
+	var removed bool
+	if _, has := m.Constraints[root]; has {
+		delete(m.Constraints, root)
+		removed = true
+	}
+	if _, has := m.Ovr[root]; has {
+		delete(m.Ovr, root)
+		delete(m.LocalPaths, root)
+		removed = true
+	}
+	if _, has := m.PruneOptions.PerProjectOptions[root]; has {
+		delete(m.PruneOptions.PerProjectOptions, root)
+		removed = true
+	}
+
+	within := func(path string) bool {
+		return path == string(root) || strings.HasPrefix(path, string(root)+"/")
+	}
+
+	required := m.Required[:0]
+	for _, path := range m.Required {
+		if within(path) {
+			removed = true
+		} else {
+			required = append(required, path)
+		}
+	}
+	m.Required = required
+
+	noverify := m.NoVerify[:0]
+	for _, path := range m.NoVerify {
+		if within(path) {
+			removed = true
+		} else {
+			noverify = append(noverify, path)
+		}
+	}
+	m.NoVerify = noverify
+
+	return removed
+}
+
 // RequiredPackages returns a set of import paths to require.
 func (m *Manifest) RequiredPackages() map[string]bool {
 	if m == nil || m == (*Manifest)(nil) {
@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
@@ -50,6 +51,7 @@ func TestReadManifest(t *testing.T) {
 			DefaultOptions:    gps.PruneNestedVendorDirs | gps.PruneNonGoFiles,
 			PerProjectOptions: make(map[gps.ProjectRoot]gps.PruneOptionSet),
 		},
+		AllowedLicenses: []string{"Apache-2.0", "MIT"},
 	}
 
 	if !reflect.DeepEqual(got.Constraints, want.Constraints) {
@@ -65,6 +67,9 @@ func TestReadManifest(t *testing.T) {
 		t.Error("Valid manifest's prune options did not parse as expected")
 		t.Error(got.PruneOptions, want.PruneOptions)
 	}
+	if !reflect.DeepEqual(got.AllowedLicenses, want.AllowedLicenses) {
+		t.Error("Valid manifest's allowed licenses did not parse as expected")
+	}
 }
 
 func TestWriteManifest(t *testing.T) {
@@ -90,6 +95,7 @@ func TestWriteManifest(t *testing.T) {
 		DefaultOptions:    gps.PruneNestedVendorDirs | gps.PruneNonGoFiles,
 		PerProjectOptions: make(map[gps.ProjectRoot]gps.PruneOptionSet),
 	}
+	m.AllowedLicenses = []string{"Apache-2.0", "MIT"}
 
 	got, err := m.MarshalTOML()
 	if err != nil {
@@ -396,6 +402,43 @@ func TestValidateManifest(t *testing.T) {
 			wantWarn:  []error{errors.New("revision \"8d43f8c0b836\" should not be in abbreviated form")},
 			wantError: nil,
 		},
+		{
+			name: "valid license",
+			tomlString: `
+			[license]
+			  allowed = ["MIT", "BSD-3-Clause"]
+			`,
+			wantWarn:  []error{},
+			wantError: nil,
+		},
+		{
+			name: "invalid license",
+			tomlString: `
+			license = ["MIT"]
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidLicense,
+		},
+		{
+			name: "invalid license allowed list",
+			tomlString: `
+			[license]
+			  allowed = "MIT"
+			`,
+			wantWarn:  []error{},
+			wantError: errInvalidLicenseAllow,
+		},
+		{
+			name: "invalid license fields",
+			tomlString: `
+			[license]
+			  denied = ["GPL-3.0"]
+			`,
+			wantWarn: []error{
+				errors.New("invalid key \"denied\" in \"license\""),
+			},
+			wantError: nil,
+		},
 		{
 			name: "valid prune options",
 			tomlString: `
@@ -811,6 +854,32 @@ func TestToRawPruneOptions_Panic(t *testing.T) {
 	_ = toRawPruneOptions(pruneOptions)
 }
 
+func TestManifestRemoveRulesFor(t *testing.T) {
+	m := NewManifest()
+	m.Constraints["github.com/foo/bar"] = gps.ProjectProperties{Constraint: gps.NewBranch("master")}
+	m.Constraints["github.com/foo/barbaz"] = gps.ProjectProperties{Constraint: gps.NewBranch("master")}
+	m.Ovr["github.com/foo/bar"] = gps.ProjectProperties{Source: "github.com/fork/bar"}
+	m.PruneOptions.PerProjectOptions["github.com/foo/bar"] = gps.PruneOptionSet{GoTests: pvtrue}
+	m.Required = []string{"github.com/foo/bar", "github.com/foo/bar/cmd", "github.com/foo/barbaz"}
+	m.NoVerify = []string{"github.com/foo/bar", "WORKSPACE"}
+
+	if !m.RemoveRulesFor("github.com/foo/bar") {
+		t.Fatal("expected rules to be removed")
+	}
+
+	want := NewManifest()
+	want.Constraints["github.com/foo/barbaz"] = gps.ProjectProperties{Constraint: gps.NewBranch("master")}
+	want.Required = []string{"github.com/foo/barbaz"}
+	want.NoVerify = []string{"WORKSPACE"}
+	if !reflect.DeepEqual(m, want) {
+		t.Fatalf("unexpected manifest after removal:\n\t(GOT): %#v\n\t(WNT): %#v", m, want)
+	}
+
+	if m.RemoveRulesFor("github.com/foo/bar") {
+		t.Error("expected nothing to be removed the second time")
+	}
+}
+
 func containsErr(s []error, e error) bool {
 	for _, a := range s {
 		if a.Error() == e.Error() {
@@ -819,3 +888,45 @@ func containsErr(s []error, e error) bool {
 	}
 	return false
 }
+
+func TestManifestLocalPaths(t *testing.T) {
+	m, _, err := readManifest(strings.NewReader(`
+[[override]]
+  name = "github.com/corp/lib"
+  path = "../lib"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[gps.ProjectRoot]string{"github.com/corp/lib": "../lib"}; !reflect.DeepEqual(m.LocalPaths, want) {
+		t.Errorf("unexpected local paths:\n\t(GOT): %v\n\t(WNT): %v", m.LocalPaths, want)
+	}
+
+	root := filepath.Join(string(filepath.Separator)+"src", "app")
+	m.resolveLocalPaths(root)
+	want := gps.ProjectProperties{
+		Source:     gps.LocalSource(filepath.Join(string(filepath.Separator)+"src", "lib")),
+		Constraint: gps.Any(),
+	}
+	if got := m.Ovr["github.com/corp/lib"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected override:\n\t(GOT): %#v\n\t(WNT): %#v", got, want)
+	}
+
+	// The path, not the source it resolved to, is written back.
+	b, err := m.MarshalTOML()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := string(b); !strings.Contains(s, `path = "../lib"`) || strings.Contains(s, "source") {
+		t.Errorf("expected the override to be written with its path, got:\n%s", s)
+	}
+
+	for _, bad := range []string{
+		"[[constraint]]\n  name = \"github.com/corp/lib\"\n  path = \"../lib\"\n",
+		"[[override]]\n  name = \"github.com/corp/lib\"\n  path = \"../lib\"\n  version = \"1.0.0\"\n",
+	} {
+		if _, _, err := readManifest(strings.NewReader(bad)); err == nil {
+			t.Errorf("expected an error reading:\n%s", bad)
+		}
+	}
+}